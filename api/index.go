@@ -1,46 +1,60 @@
 package api
 
 import (
+	"context"
 	"net/http"
-	"password-manager/controller"
+	"password-manager/config"
 	"password-manager/logger"
-	"password-manager/middleware"
-	"password-manager/service"
+	"password-manager/router"
+	"password-manager/tracing"
+	"sync"
+)
 
-	"github.com/gin-contrib/cors"
-	"github.com/gin-gonic/gin"
+var (
+	engineMu sync.Mutex
+	engine   http.Handler
 )
 
+// Handler is the serverless entrypoint. The engine is built on the first
+// request and reused by every later request served by the same instance.
 func Handler(w http.ResponseWriter, r *http.Request) {
+	handler, err := loadEngine()
+	if err != nil {
+		logger.ErrorLogger.Println(err.Error())
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	handler.ServeHTTP(w, r)
+}
+
+// loadEngine builds the engine unless an earlier request already has. A
+// failure is not kept, so a database that was briefly unreachable does not
+// fail every request until the instance is recycled.
+func loadEngine() (http.Handler, error) {
+	engineMu.Lock()
+	defer engineMu.Unlock()
+
+	if engine != nil {
+		return engine, nil
+	}
+
 	logger.Init()
 
-	gin.SetMode(gin.ReleaseMode)
-	server := gin.Default()
-
-	config := cors.DefaultConfig()
-	config.AllowMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
-	config.AllowOrigins = []string{"https://react-password-manager.vercel.app", "http://localhost:3000"}
-	config.AllowHeaders = []string{"Authorization", "Content-Type"}
-	config.AllowCredentials = true
-	config.ExposeHeaders = []string{"Authorization", "Set-Cookie"}
-	server.Use(cors.New(config))
-
-	authController := controller.NewAuthController(service.NewAuthService())
-	siteController := controller.NewSiteController(service.NewSiteService())
-
-	server.POST("/generate-otp", authController.GenerateOtp)
-	server.POST("/verify-otp", authController.VerifyOtp)
-	server.POST("/sign-up", authController.SignUp)
-	server.POST("/sign-in", authController.SignIn)
-	server.PUT("/forgot-password", authController.ForgotPassword)
-	server.PUT("/reset-password", middleware.TokenAuthMiddleware(), authController.ResetPassword)
-	server.GET("/sign-out", middleware.TokenAuthMiddleware(), authController.SignOut)
-	server.GET("/check-token", middleware.TokenAuthMiddleware(), authController.CheckToken)
-
-	server.POST("/save-site", middleware.TokenAuthMiddleware(), siteController.SaveSite)
-	server.GET("/get-sites", middleware.TokenAuthMiddleware(), siteController.GetSites)
-	server.PATCH("/edit-site", middleware.TokenAuthMiddleware(), siteController.EditSite)
-	server.DELETE("/delete-site", middleware.TokenAuthMiddleware(), siteController.DeleteSite)
-
-	server.ServeHTTP(w, r)
+	settings, err := config.Load()
+	if err != nil {
+		return nil, err
+	}
+	logger.SetLevel(settings.Log.Level)
+	shutdownTracing, err := tracing.Init(router.TracingConfig(settings))
+	if err != nil {
+		return nil, err
+	}
+	routerConfig, err := router.LoadConfig(settings)
+	if err != nil {
+		shutdownTracing(context.Background())
+		return nil, err
+	}
+
+	engine = router.New(routerConfig)
+	return engine, nil
 }
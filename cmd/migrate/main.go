@@ -0,0 +1,29 @@
+package main
+
+import (
+	"context"
+	"password-manager/config"
+	"password-manager/db"
+	"password-manager/logger"
+	"password-manager/router"
+)
+
+func main() {
+	logger.Init()
+
+	settings, err := config.Load()
+	if err != nil {
+		logger.ErrorLogger.Fatalln(err.Error())
+	}
+
+	applied, err := db.RunMigrations(context.Background(), settings.Storage.Backend, router.MongoConfig(settings))
+	for _, migration := range applied {
+		logger.InfoLogger.Printf("Applied migration %d: %s\n", migration.Version, migration.Description)
+	}
+	db.Disconnect(context.Background())
+	if err != nil {
+		logger.ErrorLogger.Fatalln(err.Error())
+	}
+
+	logger.InfoLogger.Printf("Migrations applied: %v\n", len(applied))
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+	"password-manager/config"
+	"password-manager/db"
+	"password-manager/logger"
+	"password-manager/router"
+	"password-manager/vault"
+)
+
+func main() {
+	reEncrypt := flag.Bool("reencrypt", false, "give every user a new data key and re-encrypt their sites with it")
+	flag.Parse()
+
+	logger.Init()
+
+	settings, err := config.Load()
+	if err != nil {
+		logger.ErrorLogger.Fatalln(err.Error())
+	}
+
+	stores, err := db.NewStores(settings.Storage.Backend, router.MongoConfig(settings))
+	if err != nil {
+		logger.ErrorLogger.Fatalln(err.Error())
+	}
+
+	keyRing, err := vault.ParseKeyRing(settings.Keys.MasterKeys, settings.Keys.MasterKeyId)
+	if err != nil {
+		logger.ErrorLogger.Fatalln(err.Error())
+	}
+
+	logger.InfoLogger.Printf("Rotating vault keys to master key %q (re-encrypt: %v)\n", keyRing.ActiveId(), *reEncrypt)
+
+	rotator := vault.NewRotator(keyRing, stores.DataKeys, stores.Sites)
+	report, err := rotator.Run(context.Background(), vault.RotationOptions{ReEncrypt: *reEncrypt}, func(progress vault.RotationProgress) {
+		logger.InfoLogger.Printf("User %v/%v (%v): %v sites re-encrypted\n", progress.UsersDone, progress.UsersTotal, progress.UserId, progress.SitesReEncrypted)
+	})
+	if err != nil {
+		logger.ErrorLogger.Fatalln(err.Error())
+	}
+
+	for _, failure := range report.Failures {
+		logger.Error(context.Background(), "rotation failed", "user", failure.UserId, "key_id", failure.KeyId, "site_id", failure.SiteId, "error", failure.Err)
+	}
+	logger.InfoLogger.Printf("Data keys re-wrapped: %v, data keys skipped: %v, users processed: %v, sites re-encrypted: %v, sites skipped: %v, failures: %v\n",
+		report.KeysRewrapped, report.KeysSkipped, report.UsersProcessed, report.SitesReEncrypted, report.SitesSkipped, len(report.Failures))
+
+	db.Disconnect(context.Background())
+	if len(report.Failures) > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,199 @@
+package config
+
+import (
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Config is the effective configuration of the server and its tools. Every
+// leaf is read from the environment variable named by its env tag, falling
+// back to the config file and then to its default. Secrets can also be read
+// from the file named by <env>_FILE so they never have to sit in the
+// environment.
+type Config struct {
+	Server   Server   `file:"server"`
+	Storage  Storage  `file:"storage"`
+	Mongo    Mongo    `file:"mongo"`
+	Keys     Keys     `file:"keys"`
+	Auth     Auth     `file:"auth"`
+	WebAuthn WebAuthn `file:"webauthn"`
+	Mail     Mail     `file:"mail"`
+	Log      Log      `file:"log"`
+	Tracing  Tracing  `file:"tracing"`
+	Timeouts Timeouts `file:"timeouts"`
+}
+
+type Server struct {
+	Port         int      `file:"port" env:"PORT" default:"8080"`
+	AllowOrigins []string `file:"allowOrigins" env:"CORS_ORIGINS" default:"https://react-password-manager.vercel.app,http://localhost:3000"`
+	// ReadTimeout and WriteTimeout bound reading a request and writing its
+	// response; WriteTimeout must outlast REQUEST_TIMEOUT so a timed out
+	// request still gets its 504.
+	ReadTimeout  time.Duration `file:"readTimeout" env:"SERVER_READ_TIMEOUT" default:"15s"`
+	WriteTimeout time.Duration `file:"writeTimeout" env:"SERVER_WRITE_TIMEOUT" default:"35s"`
+	IdleTimeout  time.Duration `file:"idleTimeout" env:"SERVER_IDLE_TIMEOUT" default:"60s"`
+	// ShutdownTimeout is how long in-flight requests get to finish on SIGTERM.
+	ShutdownTimeout time.Duration `file:"shutdownTimeout" env:"SERVER_SHUTDOWN_TIMEOUT" default:"30s"`
+}
+
+type Storage struct {
+	Backend string `file:"backend" env:"STORAGE_BACKEND" default:"mongo"`
+	// AutoMigrate applies migrations at startup. With it off, cmd/migrate
+	// must have been run first: the server will not start without the
+	// unique index on users.email that keeps sign-ups from racing.
+	AutoMigrate bool `file:"autoMigrate" env:"AUTO_MIGRATE" default:"true"`
+}
+
+type Mongo struct {
+	URI                    string        `file:"uri" env:"MONGO_URI" secret:"true"`
+	Database               string        `file:"database" env:"MONGO_DATABASE" default:"go-password"`
+	MinPoolSize            uint64        `file:"minPoolSize" env:"MONGO_MIN_POOL_SIZE" default:"0"`
+	MaxPoolSize            uint64        `file:"maxPoolSize" env:"MONGO_MAX_POOL_SIZE" default:"20"`
+	MaxConnIdleTime        time.Duration `file:"maxConnIdleTime" env:"MONGO_MAX_CONN_IDLE_TIME" default:"5m"`
+	ConnectTimeout         time.Duration `file:"connectTimeout" env:"MONGO_CONNECT_TIMEOUT" default:"10s"`
+	ServerSelectionTimeout time.Duration `file:"serverSelectionTimeout" env:"MONGO_SERVER_SELECTION_TIMEOUT" default:"10s"`
+}
+
+// Keys are left in their encoded form; vault, signing and service parse them.
+type Keys struct {
+	MasterKeys  string `file:"masterKey" env:"MASTER_KEY" secret:"true"`
+	MasterKeyId string `file:"masterKeyId" env:"MASTER_KEY_ID"`
+	JwtKeys     string `file:"jwtKeys" env:"JWT_KEYS" secret:"true"`
+	JwtKeyId    string `file:"jwtKeyId" env:"JWT_KEY_ID"`
+	OtpKey      string `file:"otpKey" env:"OTP_KEY" secret:"true"`
+}
+
+type Auth struct {
+	AccessTokenTTL  time.Duration `file:"accessTokenTtl" env:"ACCESS_TOKEN_TTL" default:"15m"`
+	RefreshTokenTTL time.Duration `file:"refreshTokenTtl" env:"REFRESH_TOKEN_TTL" default:"720h"`
+	OtpTTL          time.Duration `file:"otpTtl" env:"OTP_TTL" default:"5m"`
+	TotpIssuer      string        `file:"totpIssuer" env:"TOTP_ISSUER" default:"Password Manager"`
+}
+
+type WebAuthn struct {
+	RPID      string   `file:"rpId" env:"WEBAUTHN_RP_ID" default:"localhost"`
+	RPName    string   `file:"rpName" env:"WEBAUTHN_RP_NAME" default:"Password Manager"`
+	RPOrigins []string `file:"rpOrigins" env:"WEBAUTHN_RP_ORIGINS" default:"http://localhost:3000"`
+}
+
+type Mail struct {
+	Host     string `file:"host" env:"SMTP_HOST" default:"smtp.gmail.com"`
+	Port     int    `file:"port" env:"SMTP_PORT" default:"587"`
+	Username string `file:"username" env:"EMAIL"`
+	Password string `file:"password" env:"PASSKEY" secret:"true"`
+}
+
+type Log struct {
+	Level string `file:"level" env:"LOG_LEVEL" default:"info"`
+}
+
+// Tracing exports spans to stdout or an OTLP/HTTP collector. With the none
+// exporter incoming trace context is still passed on to outbound calls.
+type Tracing struct {
+	Exporter    string  `file:"exporter" env:"TRACING_EXPORTER" default:"none"`
+	Endpoint    string  `file:"endpoint" env:"OTEL_EXPORTER_OTLP_ENDPOINT" default:"http://localhost:4318"`
+	ServiceName string  `file:"serviceName" env:"OTEL_SERVICE_NAME" default:"password-manager"`
+	SampleRatio float64 `file:"sampleRatio" env:"TRACING_SAMPLE_RATIO" default:"1"`
+}
+
+// Timeouts bound the work done for a request: the request as a whole, each
+// store operation, each OTP email and each site logo lookup.
+type Timeouts struct {
+	Request     time.Duration `file:"request" env:"REQUEST_TIMEOUT" default:"30s"`
+	Database    time.Duration `file:"database" env:"DB_OPERATION_TIMEOUT" default:"5s"`
+	Mail        time.Duration `file:"mail" env:"SMTP_TIMEOUT" default:"15s"`
+	ImageLookup time.Duration `file:"imageLookup" env:"IMAGE_LOOKUP_TIMEOUT" default:"3s"`
+}
+
+// validate reports every missing or out of range value. The in-memory backend
+// needs no database and generates throwaway keys, so those are only required
+// for Mongo.
+func (config Config) validate() (problems []string) {
+	if config.Server.Port < 1 || config.Server.Port > 65535 {
+		problems = append(problems, "PORT must be between 1 and 65535")
+	}
+	if len(config.Server.AllowOrigins) == 0 {
+		problems = append(problems, "CORS_ORIGINS must list at least one origin")
+	}
+
+	switch config.Storage.Backend {
+	case "memory":
+	case "mongo":
+		required := []struct {
+			key   string
+			value string
+		}{
+			{"MONGO_URI", config.Mongo.URI},
+			{"MASTER_KEY", config.Keys.MasterKeys},
+			{"JWT_KEYS", config.Keys.JwtKeys},
+			{"OTP_KEY", config.Keys.OtpKey},
+			{"EMAIL", config.Mail.Username},
+			{"PASSKEY", config.Mail.Password},
+		}
+		for _, setting := range required {
+			if setting.value == "" {
+				problems = append(problems, setting.key+" is required")
+			}
+		}
+	default:
+		problems = append(problems, "STORAGE_BACKEND must be mongo or memory")
+	}
+
+	if config.Mongo.MaxPoolSize < config.Mongo.MinPoolSize {
+		problems = append(problems, "MONGO_MAX_POOL_SIZE must not be less than MONGO_MIN_POOL_SIZE")
+	}
+	positive := []struct {
+		key   string
+		value time.Duration
+	}{
+		{"SERVER_READ_TIMEOUT", config.Server.ReadTimeout},
+		{"SERVER_WRITE_TIMEOUT", config.Server.WriteTimeout},
+		{"SERVER_IDLE_TIMEOUT", config.Server.IdleTimeout},
+		{"SERVER_SHUTDOWN_TIMEOUT", config.Server.ShutdownTimeout},
+		{"MONGO_CONNECT_TIMEOUT", config.Mongo.ConnectTimeout},
+		{"MONGO_SERVER_SELECTION_TIMEOUT", config.Mongo.ServerSelectionTimeout},
+		{"ACCESS_TOKEN_TTL", config.Auth.AccessTokenTTL},
+		{"REFRESH_TOKEN_TTL", config.Auth.RefreshTokenTTL},
+		{"OTP_TTL", config.Auth.OtpTTL},
+		{"REQUEST_TIMEOUT", config.Timeouts.Request},
+		{"DB_OPERATION_TIMEOUT", config.Timeouts.Database},
+		{"SMTP_TIMEOUT", config.Timeouts.Mail},
+		{"IMAGE_LOOKUP_TIMEOUT", config.Timeouts.ImageLookup},
+	}
+	for _, setting := range positive {
+		if setting.value <= 0 {
+			problems = append(problems, setting.key+" must be a positive duration")
+		}
+	}
+
+	if config.Server.WriteTimeout > 0 && config.Server.WriteTimeout <= config.Timeouts.Request {
+		problems = append(problems, "SERVER_WRITE_TIMEOUT must be longer than REQUEST_TIMEOUT")
+	}
+
+	if len(config.WebAuthn.RPOrigins) == 0 {
+		problems = append(problems, "WEBAUTHN_RP_ORIGINS must list at least one origin")
+	}
+	if config.Mail.Port < 1 || config.Mail.Port > 65535 {
+		problems = append(problems, "SMTP_PORT must be between 1 and 65535")
+	}
+	switch config.Tracing.Exporter {
+	case "none", "stdout":
+	case "otlp":
+		if endpoint, err := url.Parse(config.Tracing.Endpoint); err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+			problems = append(problems, "OTEL_EXPORTER_OTLP_ENDPOINT must be an http or https URL")
+		}
+	default:
+		problems = append(problems, "TRACING_EXPORTER must be none, stdout or otlp")
+	}
+	if config.Tracing.SampleRatio < 0 || config.Tracing.SampleRatio > 1 {
+		problems = append(problems, "TRACING_SAMPLE_RATIO must be between 0 and 1")
+	}
+	switch strings.ToLower(config.Log.Level) {
+	case "debug", "info", "warn", "error":
+	default:
+		problems = append(problems, "LOG_LEVEL must be debug, info, warn or error")
+	}
+
+	return problems
+}
@@ -0,0 +1,244 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// ValidationError lists every problem found while loading, so that one
+// failed start shows everything that needs fixing.
+type ValidationError struct {
+	Problems []string
+}
+
+func (err *ValidationError) Error() string {
+	return "invalid configuration:\n  " + strings.Join(err.Problems, "\n  ")
+}
+
+// Load builds the configuration from defaults, the YAML or TOML file named by
+// CONFIG_FILE if set, and the environment, in increasing order of priority.
+func Load() (Config, error) {
+	var file map[string]any
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		var err error
+		if file, err = readFile(path); err != nil {
+			return Config{}, err
+		}
+	}
+
+	var config Config
+	var problems []string
+	known := map[string]bool{}
+	eachSetting(reflect.ValueOf(&config).Elem(), "", func(field reflect.StructField, value reflect.Value, path string) {
+		known[path] = true
+		raw, source, found, err := lookup(field, file, path)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s could not be read: %v", source, err))
+			return
+		}
+		if !found {
+			return
+		}
+		if err := parse(value, raw); err != nil {
+			problems = append(problems, fmt.Sprintf("%s (from %s) %v", field.Tag.Get("env"), source, err))
+		}
+	})
+	problems = append(problems, unknownKeys(file, "", known)...)
+
+	if len(problems) == 0 {
+		problems = config.validate()
+	}
+	if len(problems) > 0 {
+		return Config{}, &ValidationError{Problems: problems}
+	}
+
+	return config, nil
+}
+
+func readFile(path string) (map[string]any, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	file := map[string]any{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(contents, &file)
+	case ".toml":
+		err = toml.Unmarshal(contents, &file)
+	default:
+		return nil, errors.New("CONFIG_FILE must be a .yaml, .yml or .toml file")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return file, nil
+}
+
+// eachSetting calls fn for every leaf of the struct in value, with its dotted
+// path in the config file.
+func eachSetting(value reflect.Value, prefix string, fn func(field reflect.StructField, value reflect.Value, path string)) {
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		path := prefix + field.Tag.Get("file")
+		if field.Type.Kind() == reflect.Struct {
+			eachSetting(value.Field(i), path+".", fn)
+			continue
+		}
+		fn(field, value.Field(i), path)
+	}
+}
+
+// lookup finds the raw value of a setting: the environment first, then for
+// secrets the file named by <env>_FILE, then the config file, then the
+// default.
+func lookup(field reflect.StructField, file map[string]any, path string) (raw string, source string, found bool, err error) {
+	env := field.Tag.Get("env")
+	if value := os.Getenv(env); value != "" {
+		return value, "environment", true, nil
+	}
+	if field.Tag.Get("secret") == "true" {
+		if secretPath := os.Getenv(env + "_FILE"); secretPath != "" {
+			contents, err := os.ReadFile(secretPath)
+			if err != nil {
+				return "", env + "_FILE", false, err
+			}
+			return strings.TrimSpace(string(contents)), env + "_FILE", true, nil
+		}
+	}
+	if value, ok := fileValue(file, path); ok {
+		return value, "config file", true, nil
+	}
+	if value, ok := field.Tag.Lookup("default"); ok {
+		return value, "default", true, nil
+	}
+	return "", "", false, nil
+}
+
+func fileValue(file map[string]any, path string) (string, bool) {
+	var current any = file
+	for _, key := range strings.Split(path, ".") {
+		section, ok := current.(map[string]any)
+		if !ok {
+			return "", false
+		}
+		if current, ok = section[key]; !ok {
+			return "", false
+		}
+	}
+
+	if list, ok := current.([]any); ok {
+		items := make([]string, len(list))
+		for i, item := range list {
+			items[i] = fmt.Sprint(item)
+		}
+		return strings.Join(items, ","), true
+	}
+	return fmt.Sprint(current), true
+}
+
+func unknownKeys(file map[string]any, prefix string, known map[string]bool) (problems []string) {
+	keys := make([]string, 0, len(file))
+	for key := range file {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		path := prefix + key
+		if section, ok := file[key].(map[string]any); ok {
+			problems = append(problems, unknownKeys(section, path+".", known)...)
+			continue
+		}
+		if !known[path] {
+			problems = append(problems, path+" in the config file is not a known setting")
+		}
+	}
+	return problems
+}
+
+func parse(value reflect.Value, raw string) error {
+	raw = strings.TrimSpace(raw)
+	switch {
+	case value.Type() == durationType:
+		duration, err := time.ParseDuration(raw)
+		if err != nil {
+			return errors.New("must be a duration such as 10s")
+		}
+		value.SetInt(int64(duration))
+	case value.Kind() == reflect.String:
+		value.SetString(raw)
+	case value.Kind() == reflect.Int:
+		number, err := strconv.Atoi(raw)
+		if err != nil {
+			return errors.New("must be an integer")
+		}
+		value.SetInt(int64(number))
+	case value.Kind() == reflect.Float64:
+		number, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return errors.New("must be a number")
+		}
+		value.SetFloat(number)
+	case value.Kind() == reflect.Uint64:
+		number, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			return errors.New("must be a non-negative integer")
+		}
+		value.SetUint(number)
+	case value.Kind() == reflect.Bool:
+		flag, err := strconv.ParseBool(raw)
+		if err != nil {
+			return errors.New("must be true or false")
+		}
+		value.SetBool(flag)
+	case value.Kind() == reflect.Slice:
+		items := []string{}
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		value.Set(reflect.ValueOf(items))
+	}
+	return nil
+}
+
+// String lists the effective configuration as KEY=value lines, with secrets
+// shown only as set or unset, so that it can be logged at startup.
+func (config Config) String() string {
+	var lines []string
+	eachSetting(reflect.ValueOf(config), "", func(field reflect.StructField, value reflect.Value, path string) {
+		lines = append(lines, field.Tag.Get("env")+"="+format(field, value))
+	})
+	return strings.Join(lines, "\n")
+}
+
+func format(field reflect.StructField, value reflect.Value) string {
+	if field.Tag.Get("secret") == "true" {
+		if value.String() == "" {
+			return "(unset)"
+		}
+		return "(redacted)"
+	}
+	switch current := value.Interface().(type) {
+	case []string:
+		return strings.Join(current, ",")
+	default:
+		return fmt.Sprint(current)
+	}
+}
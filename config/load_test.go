@@ -0,0 +1,148 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// clearEnv blanks every setting, its _FILE variant and CONFIG_FILE so that
+// the environment running the tests does not leak in. Blank values count as
+// unset.
+func clearEnv(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	eachSetting(reflect.ValueOf(&Config{}).Elem(), "", func(field reflect.StructField, value reflect.Value, path string) {
+		t.Setenv(field.Tag.Get("env"), "")
+		t.Setenv(field.Tag.Get("env")+"_FILE", "")
+	})
+}
+
+func writeFile(t *testing.T, name string, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(contents), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	configFile := "storage:\n  backend: memory\nserver:\n  port: 9000\nmongo:\n  uri: mongodb://file\n"
+
+	tests := []struct {
+		name     string
+		file     bool
+		env      map[string]string
+		wantPort int
+		wantURI  string
+	}{
+		{name: "defaults", env: map[string]string{"STORAGE_BACKEND": "memory"}, wantPort: 8080},
+		{name: "config file over defaults", file: true, wantPort: 9000, wantURI: "mongodb://file"},
+		{name: "secret file over config file", file: true, env: map[string]string{"MONGO_URI_FILE": "mongodb://secret-file\n"}, wantPort: 9000, wantURI: "mongodb://secret-file"},
+		{name: "only secrets are read from files", file: true, env: map[string]string{"PORT_FILE": "9100"}, wantPort: 9000, wantURI: "mongodb://file"},
+		{name: "environment over everything", file: true, env: map[string]string{"PORT": "9100", "MONGO_URI": "mongodb://env", "MONGO_URI_FILE": "mongodb://secret-file"}, wantPort: 9100, wantURI: "mongodb://env"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			clearEnv(t)
+			if test.file {
+				t.Setenv("CONFIG_FILE", writeFile(t, "config.yaml", configFile))
+			}
+			for key, value := range test.env {
+				if strings.HasSuffix(key, "_FILE") {
+					value = writeFile(t, key, value)
+				}
+				t.Setenv(key, value)
+			}
+
+			config, err := Load()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if config.Server.Port != test.wantPort || config.Mongo.URI != test.wantURI {
+				t.Fatalf("port %d, URI %q; want %d, %q", config.Server.Port, config.Mongo.URI, test.wantPort, test.wantURI)
+			}
+		})
+	}
+}
+
+func TestLoadValidation(t *testing.T) {
+	tests := []struct {
+		name         string
+		file         string
+		env          map[string]string
+		wantProblems []string
+	}{
+		{
+			name: "unreadable values",
+			file: "server:\n  prot: 9000\n",
+			env:  map[string]string{"PORT": "eighty", "SERVER_READ_TIMEOUT": "soon"},
+			wantProblems: []string{
+				"PORT (from environment) must be an integer",
+				"SERVER_READ_TIMEOUT (from environment) must be a duration such as 10s",
+				"server.prot in the config file is not a known setting",
+			},
+		},
+		{
+			name: "invalid values",
+			env:  map[string]string{"REQUEST_TIMEOUT": "40s", "TRACING_SAMPLE_RATIO": "2", "LOG_LEVEL": "loud"},
+			wantProblems: []string{
+				"MONGO_URI is required",
+				"MASTER_KEY is required",
+				"JWT_KEYS is required",
+				"OTP_KEY is required",
+				"EMAIL is required",
+				"PASSKEY is required",
+				"SERVER_WRITE_TIMEOUT must be longer than REQUEST_TIMEOUT",
+				"TRACING_SAMPLE_RATIO must be between 0 and 1",
+				"LOG_LEVEL must be debug, info, warn or error",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			clearEnv(t)
+			if test.file != "" {
+				t.Setenv("CONFIG_FILE", writeFile(t, "config.yaml", test.file))
+			}
+			for key, value := range test.env {
+				t.Setenv(key, value)
+			}
+
+			_, err := Load()
+			var validationError *ValidationError
+			if !errors.As(err, &validationError) {
+				t.Fatalf("Load returned %v, want a ValidationError", err)
+			}
+			if !reflect.DeepEqual(validationError.Problems, test.wantProblems) {
+				t.Fatalf("problems:\n  %s\nwant:\n  %s", strings.Join(validationError.Problems, "\n  "), strings.Join(test.wantProblems, "\n  "))
+			}
+		})
+	}
+}
+
+func TestStringRedactsSecrets(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("STORAGE_BACKEND", "memory")
+	t.Setenv("MONGO_URI", "mongodb://user:hunter2@db")
+	t.Setenv("JWT_KEYS", "jwt-secret-value")
+
+	config, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	printed := config.String()
+	for _, line := range []string{"MONGO_URI=(redacted)", "JWT_KEYS=(redacted)", "PASSKEY=(unset)", "PORT=8080", "STORAGE_BACKEND=memory"} {
+		if !strings.Contains(printed, line+"\n") {
+			t.Errorf("String() does not contain %q", line)
+		}
+	}
+	for _, secret := range []string{"hunter2", "jwt-secret-value"} {
+		if strings.Contains(printed, secret) {
+			t.Errorf("String() prints the secret %q", secret)
+		}
+	}
+}
@@ -1,9 +1,17 @@
 package constants
 
 const (
-	DatabaseName        = "go-password"
-	UsersCollection     = "users"
-	SitesCollection     = "sites"
-	OtpCollection       = "otp"
-	BlacklistCollection = "blacklist"
+	UsersCollection         = "users"
+	SitesCollection         = "sites"
+	OtpCollection           = "otp"
+	BlacklistCollection     = "blacklist"
+	DataKeysCollection      = "dataKeys"
+	VaultItemsCollection    = "vaultItems"
+	RefreshTokensCollection = "refreshTokens"
+	SessionsCollection      = "sessions"
+	PasskeysCollection      = "passkeys"
+	CeremoniesCollection    = "webauthnCeremonies"
+	AttemptsCollection      = "attempts"
+	RateLimitsCollection    = "rateLimits"
+	MigrationsCollection    = "migrations"
 )
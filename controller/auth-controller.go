@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"password-manager/entity"
 	"password-manager/logger"
+	"password-manager/metrics"
 	"password-manager/service"
 	"password-manager/util"
 	"time"
@@ -18,7 +19,17 @@ type AuthController interface {
 	SignIn(ctx *gin.Context)
 	ForgotPassword(ctx *gin.Context)
 	ResetPassword(ctx *gin.Context)
+	RefreshToken(ctx *gin.Context)
 	SignOut(ctx *gin.Context)
+	SignInTotp(ctx *gin.Context)
+	EnrollTotp(ctx *gin.Context)
+	ConfirmTotp(ctx *gin.Context)
+	DisableTotp(ctx *gin.Context)
+	SignInRecoveryCode(ctx *gin.Context)
+	RegenerateRecoveryCodes(ctx *gin.Context)
+	CountRecoveryCodes(ctx *gin.Context)
+	BeginPasskeySignIn(ctx *gin.Context)
+	FinishPasskeySignIn(ctx *gin.Context)
 	CheckToken(ctx *gin.Context)
 }
 
@@ -35,7 +46,7 @@ func NewAuthController(service service.AuthService) AuthController {
 func (controller *authController) GenerateOtp(ctx *gin.Context) {
 	var request entity.GenerateOtpRequest
 	if err := ctx.ShouldBindJSON(&request); err != nil {
-		logger.ErrorLogger.Println(err.Error())
+		logger.Warn(ctx, err.Error())
 		ctx.JSON(http.StatusBadRequest, gin.H{
 			"status":  http.StatusBadRequest,
 			"message": "Email and Type are required and cannot be empty",
@@ -43,10 +54,9 @@ func (controller *authController) GenerateOtp(ctx *gin.Context) {
 		return
 	}
 
-	if !(request.Type == "register" || request.Type == "reset") {
+	if !(request.Type == entity.OtpPurposeRegister || request.Type == entity.OtpPurposeReset) {
 		message := "Type can either be 'register' or 'reset'"
-		logger.ErrorLogger.Println(message)
-		logger.InfoLogger.Println(message)
+		logger.Warn(ctx, message)
 		ctx.JSON(http.StatusBadRequest, gin.H{
 			"status":  http.StatusBadRequest,
 			"message": message,
@@ -54,20 +64,14 @@ func (controller *authController) GenerateOtp(ctx *gin.Context) {
 		return
 	}
 
-	id, expiresAt, err := controller.service.GenerateOtp(request.Email, request.Type)
+	id, expiresAt, err := controller.service.GenerateOtp(ctx.Request.Context(), request.Email, request.Type)
+	metrics.OtpSends.WithLabelValues(request.Type, metrics.Result(err)).Inc()
 	if err != nil {
-		logger.ErrorLogger.Println(err.Error())
-		if customErr, ok := err.(*util.CustomError); ok {
-			logger.InfoLogger.Println(customErr.Message)
-			ctx.JSON(customErr.Status, gin.H{
-				"status":  customErr.Status,
-				"message": customErr.Message,
-			})
-		}
+		respondWithError(ctx, err)
 	} else {
 		parsedTime, _ := time.Parse(time.RFC3339, expiresAt)
 		message := "OTP is generated and sent to mail"
-		logger.InfoLogger.Println(message)
+		logger.Info(ctx, message)
 		cookie := util.GenerateCookie("id", id, int(time.Until(parsedTime).Seconds()))
 		http.SetCookie(ctx.Writer, cookie)
 		ctx.JSON(http.StatusOK, gin.H{
@@ -83,8 +87,7 @@ func (controller *authController) VerifyOtp(ctx *gin.Context) {
 	var request entity.VerifyOtpRequest
 	if err := ctx.ShouldBindJSON(&request); err != nil {
 		message := "Email and OTP are required and cannot be empty"
-		logger.ErrorLogger.Println(err.Error())
-		logger.InfoLogger.Println(message)
+		logger.Warn(ctx, message, "error", err)
 		ctx.JSON(http.StatusBadRequest, gin.H{
 			"status":  http.StatusBadRequest,
 			"message": message,
@@ -95,8 +98,7 @@ func (controller *authController) VerifyOtp(ctx *gin.Context) {
 	id, err := ctx.Cookie("id")
 	if err != nil {
 		message := "OTP not generated"
-		logger.ErrorLogger.Println(err.Error())
-		logger.InfoLogger.Println(message)
+		logger.Warn(ctx, message, "error", err)
 		ctx.JSON(http.StatusBadRequest, gin.H{
 			"status":  http.StatusBadRequest,
 			"message": message,
@@ -104,19 +106,13 @@ func (controller *authController) VerifyOtp(ctx *gin.Context) {
 		return
 	}
 
-	expiresAt, err := controller.service.VerifyOtp(id, request.Email, request.Otp)
+	expiresAt, err := controller.service.VerifyOtp(ctx.Request.Context(), id, request.Email, request.Otp, clientInfo(ctx))
+	metrics.OtpVerifications.WithLabelValues(metrics.Result(err)).Inc()
 	if err != nil {
-		logger.ErrorLogger.Println(err.Error())
-		if customErr, ok := err.(*util.CustomError); ok {
-			logger.InfoLogger.Println(customErr.Message)
-			ctx.JSON(customErr.Status, gin.H{
-				"status":  customErr.Status,
-				"message": customErr.Message,
-			})
-		}
+		respondWithError(ctx, err)
 	} else {
 		message := "OTP is verified"
-		logger.InfoLogger.Println(message)
+		logger.Info(ctx, message)
 		parsedTime, _ := time.Parse(time.RFC3339, expiresAt)
 		cookie := util.GenerateCookie("id", id, int(time.Until(parsedTime).Seconds()))
 		http.SetCookie(ctx.Writer, cookie)
@@ -135,8 +131,7 @@ func (controller *authController) SignUp(ctx *gin.Context) {
 
 	if err != nil {
 		message := "Email and Password are required and cannot be empty"
-		logger.ErrorLogger.Println(err.Error())
-		logger.InfoLogger.Println(message)
+		logger.Warn(ctx, message, "error", err)
 		ctx.JSON(http.StatusBadRequest, gin.H{
 			"status":  http.StatusBadRequest,
 			"message": message,
@@ -147,8 +142,7 @@ func (controller *authController) SignUp(ctx *gin.Context) {
 	id, err := ctx.Cookie("id")
 	if err != nil {
 		message := "Email not verified"
-		logger.ErrorLogger.Println(err.Error())
-		logger.InfoLogger.Println(message)
+		logger.Warn(ctx, message, "error", err)
 		ctx.JSON(http.StatusBadRequest, gin.H{
 			"status":  http.StatusBadRequest,
 			"message": message,
@@ -156,19 +150,12 @@ func (controller *authController) SignUp(ctx *gin.Context) {
 		return
 	}
 
-	err = controller.service.SignUp(id, request.Email, request.Password)
+	err = controller.service.SignUp(ctx.Request.Context(), id, request.Email, request.Password)
 	if err != nil {
-		logger.ErrorLogger.Println(err.Error())
-		if customErr, ok := err.(*util.CustomError); ok {
-			logger.InfoLogger.Println(customErr.Message)
-			ctx.JSON(customErr.Status, gin.H{
-				"status":  customErr.Status,
-				"message": customErr.Message,
-			})
-		}
+		respondWithError(ctx, err)
 	} else {
 		message := "User successfully registered"
-		logger.InfoLogger.Println(message)
+		logger.Info(ctx, message)
 		cookie := util.GenerateCookie("id", id, -1)
 		http.SetCookie(ctx.Writer, cookie)
 		ctx.JSON(http.StatusOK, gin.H{
@@ -182,8 +169,7 @@ func (controller *authController) SignIn(ctx *gin.Context) {
 	var request entity.AuthRequest
 	if err := ctx.ShouldBindJSON(&request); err != nil {
 		message := "Email and Password are required and cannot be empty"
-		logger.ErrorLogger.Println(err.Error())
-		logger.InfoLogger.Println(message)
+		logger.Warn(ctx, message, "error", err)
 		ctx.JSON(http.StatusBadRequest, gin.H{
 			"status":  http.StatusBadRequest,
 			"message": message,
@@ -191,25 +177,55 @@ func (controller *authController) SignIn(ctx *gin.Context) {
 		return
 	}
 
-	token, err := controller.service.SignIn(request.Email, request.Password)
+	result, err := controller.service.SignIn(ctx.Request.Context(), request.Email, request.Password, clientInfo(ctx))
+	countSignIn("password", result, err)
 	if err != nil {
-		logger.ErrorLogger.Println(err.Error())
-		if customErr, ok := err.(*util.CustomError); ok {
-			logger.InfoLogger.Println(customErr.Message)
-			ctx.JSON(customErr.Status, gin.H{
-				"status":  customErr.Status,
-				"message": customErr.Message,
-			})
-		}
+		respondWithError(ctx, err)
 	} else {
-		message := "Sign in successful"
-		logger.InfoLogger.Println(message)
-		ctx.Header("Authorization", "Bearer "+token)
+		respondWithSignIn(ctx, result)
+	}
+}
+
+// countSignIn records the outcome of a sign-in step. A step that still needs
+// a second factor counts as a challenge.
+func countSignIn(method string, result entity.SignInResult, err error) {
+	outcome := metrics.Result(err)
+	if err == nil && result.Challenge != nil {
+		outcome = "challenge"
+	}
+	metrics.SignIns.WithLabelValues(method, outcome).Inc()
+}
+
+// respondWithSignIn answers either step of sign-in, with the tokens on
+// success or the challenge when a second factor is still needed.
+func respondWithSignIn(ctx *gin.Context, result entity.SignInResult) {
+	if result.Challenge != nil {
+		message := "Two-factor authentication required"
+		logger.Info(ctx, message)
 		ctx.JSON(http.StatusOK, gin.H{
-			"status":  http.StatusOK,
-			"message": message,
+			"status":             http.StatusOK,
+			"message":            message,
+			"twoFactorRequired":  true,
+			"challengeToken":     result.Challenge.Token,
+			"challengeExpiresAt": result.Challenge.ExpiresAt.Format(time.RFC3339),
+			"methods":            result.Challenge.Methods,
 		})
+		return
+	}
+
+	message := "Sign in successful"
+	logger.Info(ctx, message)
+	response := gin.H{
+		"status":           http.StatusOK,
+		"message":          message,
+		"refreshToken":     result.Tokens.RefreshToken,
+		"refreshExpiresAt": result.Tokens.RefreshExpiresAt.Format(time.RFC3339),
+	}
+	if result.Kdf != nil {
+		response["kdf"] = result.Kdf
 	}
+	ctx.Header("Authorization", "Bearer "+result.Tokens.AccessToken)
+	ctx.JSON(http.StatusOK, response)
 }
 
 func (controller *authController) ForgotPassword(ctx *gin.Context) {
@@ -218,8 +234,7 @@ func (controller *authController) ForgotPassword(ctx *gin.Context) {
 
 	if err != nil {
 		message := "Email and Password are required and cannot be empty"
-		logger.ErrorLogger.Println(err.Error())
-		logger.InfoLogger.Println(message)
+		logger.Warn(ctx, message, "error", err)
 		ctx.JSON(http.StatusBadRequest, gin.H{
 			"status":  http.StatusBadRequest,
 			"message": message,
@@ -230,8 +245,7 @@ func (controller *authController) ForgotPassword(ctx *gin.Context) {
 	id, err := ctx.Cookie("id")
 	if err != nil {
 		message := "Email not verified"
-		logger.ErrorLogger.Println(err.Error())
-		logger.InfoLogger.Println(message)
+		logger.Warn(ctx, message, "error", err)
 		ctx.JSON(http.StatusBadRequest, gin.H{
 			"status":  http.StatusBadRequest,
 			"message": message,
@@ -239,19 +253,12 @@ func (controller *authController) ForgotPassword(ctx *gin.Context) {
 		return
 	}
 
-	err = controller.service.ForgotPassword(id, request.Email, request.Password)
+	err = controller.service.ForgotPassword(ctx.Request.Context(), id, request.Email, request.Password)
 	if err != nil {
-		logger.ErrorLogger.Println(err.Error())
-		if customErr, ok := err.(*util.CustomError); ok {
-			logger.InfoLogger.Println(customErr.Message)
-			ctx.JSON(customErr.Status, gin.H{
-				"status":  customErr.Status,
-				"message": customErr.Message,
-			})
-		}
+		respondWithError(ctx, err)
 	} else {
 		message := "Password reset successful"
-		logger.InfoLogger.Println(message)
+		logger.Info(ctx, message)
 		cookie := util.GenerateCookie("id", id, -1)
 		http.SetCookie(ctx.Writer, cookie)
 		ctx.JSON(http.StatusOK, gin.H{
@@ -267,8 +274,7 @@ func (controller *authController) ResetPassword(ctx *gin.Context) {
 
 	if err != nil {
 		message := "Password and New Password are required and cannot be empty"
-		logger.ErrorLogger.Println(err.Error())
-		logger.InfoLogger.Println(message)
+		logger.Warn(ctx, message, "error", err)
 		ctx.JSON(http.StatusBadRequest, gin.H{
 			"status":  http.StatusBadRequest,
 			"message": message,
@@ -277,44 +283,64 @@ func (controller *authController) ResetPassword(ctx *gin.Context) {
 	}
 
 	userId, _ := ctx.Get("userId")
-	token, err := controller.service.ResetPassword(userId.(string), request.OldPassword, request.NewPassword)
+	tokens, err := controller.service.ResetPassword(ctx.Request.Context(), userId.(string), request.OldPassword, request.NewPassword, clientInfo(ctx))
 	if err != nil {
-		logger.ErrorLogger.Println(err.Error())
-		if customErr, ok := err.(*util.CustomError); ok {
-			logger.InfoLogger.Println(customErr.Message)
-			ctx.JSON(customErr.Status, gin.H{
-				"status":  customErr.Status,
-				"message": customErr.Message,
-			})
-		}
+		respondWithError(ctx, err)
 	} else {
 		message := "Password reset successful"
-		ctx.Header("Authorization", "Bearer "+token)
+		ctx.Header("Authorization", "Bearer "+tokens.AccessToken)
 		ctx.JSON(http.StatusOK, gin.H{
-			"status":  http.StatusOK,
+			"status":           http.StatusOK,
+			"message":          message,
+			"refreshToken":     tokens.RefreshToken,
+			"refreshExpiresAt": tokens.RefreshExpiresAt.Format(time.RFC3339),
+		})
+	}
+}
+
+func (controller *authController) RefreshToken(ctx *gin.Context) {
+	var request entity.RefreshTokenRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		message := "Refresh Token is required and cannot be empty"
+		logger.Warn(ctx, message, "error", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
 			"message": message,
 		})
+		return
+	}
+
+	tokens, err := controller.service.RefreshToken(ctx.Request.Context(), request.RefreshToken, clientInfo(ctx))
+	if err != nil {
+		status, body := errorResponse(ctx, err)
+		body["sessionTimedOut"] = status == http.StatusUnauthorized
+		ctx.JSON(status, body)
+	} else {
+		message := "Token refreshed successfully"
+		logger.Info(ctx, message)
+		ctx.Header("Authorization", "Bearer "+tokens.AccessToken)
+		ctx.JSON(http.StatusOK, gin.H{
+			"status":           http.StatusOK,
+			"message":          message,
+			"refreshToken":     tokens.RefreshToken,
+			"refreshExpiresAt": tokens.RefreshExpiresAt.Format(time.RFC3339),
+		})
 	}
 }
 
 func (controller *authController) SignOut(ctx *gin.Context) {
 	token, _ := ctx.Get("token")
 	expirationTime, _ := ctx.Get("expirationTime")
+	userId, _ := ctx.Get("userId")
+	sessionId := ctx.GetString("sessionId")
 
-	err := controller.service.SignOut(token.(string), expirationTime.(time.Time))
+	err := controller.service.SignOut(ctx.Request.Context(), token.(string), expirationTime.(time.Time), userId.(string), sessionId)
 
 	if err != nil {
-		logger.ErrorLogger.Println(err.Error())
-		if customErr, ok := err.(*util.CustomError); ok {
-			logger.InfoLogger.Println(customErr.Message)
-			ctx.JSON(customErr.Status, gin.H{
-				"status":  customErr.Status,
-				"message": customErr.Message,
-			})
-		}
+		respondWithError(ctx, err)
 	} else {
 		message := "Sign out successful"
-		logger.InfoLogger.Println(message)
+		logger.Info(ctx, message)
 		ctx.JSON(http.StatusOK, gin.H{
 			"status":  http.StatusOK,
 			"message": message,
@@ -324,9 +350,16 @@ func (controller *authController) SignOut(ctx *gin.Context) {
 
 func (controller *authController) CheckToken(ctx *gin.Context) {
 	message := "Token is valid"
-	logger.InfoLogger.Println(message)
+	logger.Info(ctx, message)
 	ctx.JSON(http.StatusOK, gin.H{
 		"status":  http.StatusOK,
 		"message": message,
 	})
 }
+
+func clientInfo(ctx *gin.Context) entity.ClientInfo {
+	return entity.ClientInfo{
+		UserAgent: ctx.Request.UserAgent(),
+		IP:        ctx.ClientIP(),
+	}
+}
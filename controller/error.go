@@ -0,0 +1,56 @@
+package controller
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"password-manager/logger"
+	"password-manager/util"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// respondWithError logs err and sends its status and message. Errors that
+// are not a CustomError are reported as a 500 without their details.
+func respondWithError(ctx *gin.Context, err error) {
+	status, body := errorResponse(ctx, err)
+	ctx.JSON(status, body)
+}
+
+// errorResponse logs err and builds the response for it, for handlers that
+// add their own fields to the body.
+func errorResponse(ctx *gin.Context, err error) (int, gin.H) {
+	logError(ctx, err)
+	customErr, ok := err.(*util.CustomError)
+	if !ok {
+		customErr = &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError}
+	}
+	setRetryAfter(ctx, customErr)
+	return customErr.Status, gin.H{
+		"status":  customErr.Status,
+		"message": customErr.Message,
+	}
+}
+
+// logError logs client errors as warnings and everything else as errors,
+// with the cause the client is not shown. Services and stores return errors
+// without logging them, so this is the one line written for a failure.
+func logError(ctx *gin.Context, err error) {
+	var args []any
+	if cause := errors.Unwrap(err); cause != nil {
+		args = append(args, "error", cause)
+	}
+	if customErr, ok := err.(*util.CustomError); ok && customErr.Status < http.StatusInternalServerError {
+		logger.Warn(ctx, customErr.Message, args...)
+		return
+	}
+	logger.Error(ctx, err.Error(), args...)
+}
+
+// setRetryAfter tells throttled clients how many seconds to wait.
+func setRetryAfter(ctx *gin.Context, err *util.CustomError) {
+	if err.RetryAfter > 0 {
+		ctx.Header("Retry-After", strconv.Itoa(int(math.Ceil(err.RetryAfter.Seconds()))))
+	}
+}
@@ -0,0 +1,79 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"password-manager/db"
+	"password-manager/logger"
+	"password-manager/signing"
+	"password-manager/util"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// readinessTimeout keeps a slow database from hanging the probe.
+const readinessTimeout = 2 * time.Second
+
+type HealthController interface {
+	Healthz(ctx *gin.Context)
+	Readyz(ctx *gin.Context)
+}
+
+type healthController struct {
+	store db.HealthStore
+	mail  util.MailConfig
+	keys  *signing.KeySet
+}
+
+func NewHealthController(store db.HealthStore, mail util.MailConfig, keys *signing.KeySet) HealthController {
+	return &healthController{
+		store: store,
+		mail:  mail,
+		keys:  keys,
+	}
+}
+
+// Healthz reports that the process is up and serving.
+func (controller *healthController) Healthz(ctx *gin.Context) {
+	ctx.Header("Cache-Control", "no-store")
+	ctx.JSON(http.StatusOK, gin.H{
+		"status":  http.StatusOK,
+		"message": "OK",
+	})
+}
+
+// Readyz reports whether the server can handle traffic: the store answers a
+// ping, the mailer has an account to send OTPs from and signing keys are
+// loaded.
+func (controller *healthController) Readyz(ctx *gin.Context) {
+	ready := true
+	checks := gin.H{"store": "ok", "mailer": "ok", "signingKeys": "ok"}
+
+	pingCtx, cancel := context.WithTimeout(ctx.Request.Context(), readinessTimeout)
+	defer cancel()
+	if err := controller.store.Ping(pingCtx); err != nil {
+		logger.Warn(ctx, "readiness check failed", "check", "store", "error", err)
+		checks["store"] = "unreachable"
+		ready = false
+	}
+	if controller.mail.Host == "" || controller.mail.Username == "" || controller.mail.Password == "" {
+		checks["mailer"] = "not configured"
+		ready = false
+	}
+	if controller.keys == nil || controller.keys.ActiveId() == "" {
+		checks["signingKeys"] = "not loaded"
+		ready = false
+	}
+
+	status, message := http.StatusOK, "Ready"
+	if !ready {
+		status, message = http.StatusServiceUnavailable, "Not ready"
+	}
+	ctx.Header("Cache-Control", "no-store")
+	ctx.JSON(status, gin.H{
+		"status":  status,
+		"message": message,
+		"checks":  checks,
+	})
+}
@@ -0,0 +1,27 @@
+package controller
+
+import (
+	"net/http"
+	"password-manager/signing"
+
+	"github.com/gin-gonic/gin"
+)
+
+type KeyController interface {
+	JWKS(ctx *gin.Context)
+}
+
+type keyController struct {
+	keys *signing.KeySet
+}
+
+func NewKeyController(keys *signing.KeySet) KeyController {
+	return &keyController{
+		keys: keys,
+	}
+}
+
+func (controller *keyController) JWKS(ctx *gin.Context) {
+	ctx.Header("Cache-Control", "public, max-age=300")
+	ctx.JSON(http.StatusOK, controller.keys.JWKS())
+}
@@ -0,0 +1,213 @@
+package controller
+
+import (
+	"net/http"
+	"password-manager/entity"
+	"password-manager/logger"
+	"password-manager/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PasskeyController interface {
+	BeginRegistration(ctx *gin.Context)
+	FinishRegistration(ctx *gin.Context)
+	GetPasskeys(ctx *gin.Context)
+	RenamePasskey(ctx *gin.Context)
+	DeletePasskey(ctx *gin.Context)
+}
+
+type passkeyController struct {
+	service service.PasskeyService
+}
+
+func NewPasskeyController(service service.PasskeyService) PasskeyController {
+	return &passkeyController{
+		service: service,
+	}
+}
+
+func (controller *passkeyController) BeginRegistration(ctx *gin.Context) {
+	var request entity.PasswordRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		message := "Password is required and cannot be empty"
+		logger.Warn(ctx, message, "error", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": message,
+		})
+		return
+	}
+
+	userId, _ := ctx.Get("userId")
+
+	ceremonyId, options, err := controller.service.BeginRegistration(ctx.Request.Context(), userId.(string), request.Password)
+	if err != nil {
+		respondWithError(ctx, err)
+		return
+	}
+
+	message := "Passkey registration started"
+	logger.Info(ctx, message)
+	ctx.JSON(http.StatusOK, gin.H{
+		"status":     http.StatusOK,
+		"message":    message,
+		"ceremonyId": ceremonyId,
+		"options":    options,
+	})
+}
+
+func (controller *passkeyController) FinishRegistration(ctx *gin.Context) {
+	var request entity.PasskeyFinishRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		message := "Ceremony Id and Credential are required and cannot be empty"
+		logger.Warn(ctx, message, "error", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": message,
+		})
+		return
+	}
+
+	userId, _ := ctx.Get("userId")
+
+	passkey, err := controller.service.FinishRegistration(ctx.Request.Context(), userId.(string), request.CeremonyId, request.Name, request.Credential)
+	if err != nil {
+		respondWithError(ctx, err)
+		return
+	}
+
+	message := "Passkey registered successfully"
+	logger.Info(ctx, message)
+	ctx.JSON(http.StatusCreated, gin.H{
+		"status":  http.StatusCreated,
+		"message": message,
+		"passkey": passkey,
+	})
+}
+
+func (controller *passkeyController) GetPasskeys(ctx *gin.Context) {
+	userId, _ := ctx.Get("userId")
+
+	passkeys, err := controller.service.GetPasskeys(ctx.Request.Context(), userId.(string))
+	if err != nil {
+		respondWithError(ctx, err)
+		return
+	}
+
+	message := "Passkeys fetched successfully"
+	logger.Info(ctx, message)
+	ctx.JSON(http.StatusOK, gin.H{
+		"status":   http.StatusOK,
+		"message":  message,
+		"passkeys": passkeys,
+	})
+}
+
+func (controller *passkeyController) RenamePasskey(ctx *gin.Context) {
+	var request entity.RenamePasskeyRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		message := "Name is required and cannot be empty"
+		logger.Warn(ctx, message, "error", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": message,
+		})
+		return
+	}
+
+	userId, _ := ctx.Get("userId")
+
+	if err := controller.service.RenamePasskey(ctx.Request.Context(), userId.(string), ctx.Param("id"), request.Name); err != nil {
+		respondWithError(ctx, err)
+		return
+	}
+
+	message := "Passkey renamed successfully"
+	logger.Info(ctx, message)
+	ctx.JSON(http.StatusOK, gin.H{
+		"status":  http.StatusOK,
+		"message": message,
+	})
+}
+
+func (controller *passkeyController) DeletePasskey(ctx *gin.Context) {
+	var request entity.PasswordRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		message := "Password is required and cannot be empty"
+		logger.Warn(ctx, message, "error", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": message,
+		})
+		return
+	}
+
+	userId, _ := ctx.Get("userId")
+
+	if err := controller.service.DeletePasskey(ctx.Request.Context(), userId.(string), ctx.Param("id"), request.Password); err != nil {
+		respondWithError(ctx, err)
+		return
+	}
+
+	message := "Passkey deleted successfully"
+	logger.Info(ctx, message)
+	ctx.JSON(http.StatusOK, gin.H{
+		"status":  http.StatusOK,
+		"message": message,
+	})
+}
+
+// BeginPasskeySignIn accepts an optional challenge token; without one the
+// request starts a passwordless sign-in.
+func (controller *authController) BeginPasskeySignIn(ctx *gin.Context) {
+	var request entity.PasskeyBeginRequest
+	if ctx.Request.ContentLength != 0 {
+		if err := ctx.ShouldBindJSON(&request); err != nil {
+			message := "Request body must be valid JSON"
+			logger.Warn(ctx, message, "error", err)
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"status":  http.StatusBadRequest,
+				"message": message,
+			})
+			return
+		}
+	}
+
+	ceremonyId, options, err := controller.service.BeginPasskeySignIn(ctx.Request.Context(), request.ChallengeToken)
+	if err != nil {
+		respondWithError(ctx, err)
+		return
+	}
+
+	message := "Passkey sign in started"
+	logger.Info(ctx, message)
+	ctx.JSON(http.StatusOK, gin.H{
+		"status":     http.StatusOK,
+		"message":    message,
+		"ceremonyId": ceremonyId,
+		"options":    options,
+	})
+}
+
+func (controller *authController) FinishPasskeySignIn(ctx *gin.Context) {
+	var request entity.PasskeyFinishRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		message := "Ceremony Id and Credential are required and cannot be empty"
+		logger.Warn(ctx, message, "error", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": message,
+		})
+		return
+	}
+
+	result, err := controller.service.FinishPasskeySignIn(ctx.Request.Context(), request.CeremonyId, request.Credential, clientInfo(ctx))
+	countSignIn("passkey", result, err)
+	if err != nil {
+		respondWithError(ctx, err)
+		return
+	}
+
+	respondWithSignIn(ctx, result)
+}
@@ -0,0 +1,77 @@
+package controller
+
+import (
+	"net/http"
+	"password-manager/logger"
+	"password-manager/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SessionController interface {
+	GetSessions(ctx *gin.Context)
+	RevokeSession(ctx *gin.Context)
+	RevokeOtherSessions(ctx *gin.Context)
+}
+
+type sessionController struct {
+	service service.SessionService
+}
+
+func NewSessionController(service service.SessionService) SessionController {
+	return &sessionController{
+		service: service,
+	}
+}
+
+func (controller *sessionController) GetSessions(ctx *gin.Context) {
+	userId, _ := ctx.Get("userId")
+
+	sessions, err := controller.service.GetSessions(ctx.Request.Context(), userId.(string), ctx.GetString("sessionId"))
+	if err != nil {
+		respondWithError(ctx, err)
+		return
+	}
+
+	message := "Sessions fetched successfully"
+	logger.Info(ctx, message)
+	ctx.JSON(http.StatusOK, gin.H{
+		"status":   http.StatusOK,
+		"message":  message,
+		"sessions": sessions,
+	})
+}
+
+func (controller *sessionController) RevokeSession(ctx *gin.Context) {
+	userId, _ := ctx.Get("userId")
+
+	if err := controller.service.RevokeSession(ctx.Request.Context(), userId.(string), ctx.Param("id")); err != nil {
+		respondWithError(ctx, err)
+		return
+	}
+
+	message := "Session revoked successfully"
+	logger.Info(ctx, message)
+	ctx.JSON(http.StatusOK, gin.H{
+		"status":  http.StatusOK,
+		"message": message,
+	})
+}
+
+func (controller *sessionController) RevokeOtherSessions(ctx *gin.Context) {
+	userId, _ := ctx.Get("userId")
+
+	count, err := controller.service.RevokeOtherSessions(ctx.Request.Context(), userId.(string), ctx.GetString("sessionId"))
+	if err != nil {
+		respondWithError(ctx, err)
+		return
+	}
+
+	message := "Other sessions revoked successfully"
+	logger.Info(ctx, message)
+	ctx.JSON(http.StatusOK, gin.H{
+		"status":  http.StatusOK,
+		"message": message,
+		"revoked": count,
+	})
+}
@@ -5,14 +5,15 @@ import (
 	"password-manager/entity"
 	"password-manager/logger"
 	"password-manager/service"
-	"password-manager/util"
 
 	"github.com/gin-gonic/gin"
 )
 
 type SiteController interface {
 	SaveSite(ctx *gin.Context)
+	CreateSite(ctx *gin.Context)
 	GetSites(ctx *gin.Context)
+	GetSite(ctx *gin.Context)
 	EditSite(ctx *gin.Context)
 	DeleteSite(ctx *gin.Context)
 }
@@ -27,75 +28,110 @@ func NewSiteController(service service.SiteService) SiteController {
 	}
 }
 
+// SaveSite answers the legacy /save-site route, which clients expect to
+// return 200.
 func (controller *siteController) SaveSite(ctx *gin.Context) {
+	newSite, saved := controller.saveSite(ctx)
+	if !saved {
+		return
+	}
+
+	message := "Site saved successfully"
+	logger.Info(ctx, message)
+	ctx.JSON(http.StatusOK, gin.H{
+		"status":  http.StatusOK,
+		"message": message,
+		"site":    newSite,
+	})
+}
+
+func (controller *siteController) CreateSite(ctx *gin.Context) {
+	newSite, saved := controller.saveSite(ctx)
+	if !saved {
+		return
+	}
+
+	message := "Site saved successfully"
+	logger.Info(ctx, message)
+	ctx.Header("Location", "/v1/sites/"+newSite.Id)
+	ctx.JSON(http.StatusCreated, gin.H{
+		"status":  http.StatusCreated,
+		"message": message,
+		"site":    newSite,
+	})
+}
+
+// saveSite binds and saves the site in the request, responding itself when
+// that fails.
+func (controller *siteController) saveSite(ctx *gin.Context) (entity.Site, bool) {
 	var site entity.NewSiteRequest
 	err := ctx.ShouldBindJSON(&site)
 	if err != nil {
 		message := "URL, Name, Sector, Username and Password are required and cannot be empty"
-		logger.ErrorLogger.Println(err.Error())
-		logger.InfoLogger.Println(message)
+		logger.Warn(ctx, message, "error", err)
 		ctx.JSON(http.StatusBadRequest, gin.H{
 			"status":  http.StatusBadRequest,
 			"message": message,
 		})
-		return
+		return entity.Site{}, false
 	}
 
 	userId, _ := ctx.Get("userId")
 
-	newSite, err := controller.service.SaveSite(userId.(string), site)
+	newSite, err := controller.service.SaveSite(ctx.Request.Context(), userId.(string), site)
+	if err != nil {
+		respondWithError(ctx, err)
+		return entity.Site{}, false
+	}
 
+	return newSite, true
+}
+
+func (controller *siteController) GetSites(ctx *gin.Context) {
+	userId, _ := ctx.Get("userId")
+
+	sites, err := controller.service.GetSites(ctx.Request.Context(), userId.(string))
 	if err != nil {
-		logger.ErrorLogger.Println(err.Error())
-		if customErr, ok := err.(*util.CustomError); ok {
-			logger.InfoLogger.Println(customErr.Message)
-			ctx.JSON(customErr.Status, gin.H{
-				"status":  customErr.Status,
-				"message": customErr.Message,
-			})
-		}
+		respondWithError(ctx, err)
 	} else {
-		message := "Site saved successfully"
-		logger.InfoLogger.Println(message)
+		message := "Sites fetched successfully"
+		logger.Info(ctx, message)
 		ctx.JSON(http.StatusOK, gin.H{
 			"status":  http.StatusOK,
 			"message": message,
-			"site":    newSite,
+			"sites":   sites,
 		})
 	}
 }
 
-func (controller *siteController) GetSites(ctx *gin.Context) {
+func (controller *siteController) GetSite(ctx *gin.Context) {
 	userId, _ := ctx.Get("userId")
 
-	sites, err := controller.service.GetSites(userId.(string))
+	site, err := controller.service.GetSite(ctx.Request.Context(), userId.(string), ctx.Param("id"))
 	if err != nil {
-		logger.ErrorLogger.Println(err.Error())
-		if customErr, ok := err.(*util.CustomError); ok {
-			logger.InfoLogger.Println(customErr.Message)
-			ctx.JSON(customErr.Status, gin.H{
-				"status":  customErr.Status,
-				"message": customErr.Message,
-			})
-		}
+		respondWithError(ctx, err)
 	} else {
-		message := "Sites fetched successfully"
-		logger.InfoLogger.Println(message)
+		message := "Site fetched successfully"
+		logger.Info(ctx, message)
 		ctx.JSON(http.StatusOK, gin.H{
 			"status":  http.StatusOK,
 			"message": message,
-			"sites":   sites,
+			"site":    site,
 		})
 	}
 }
 
+// EditSite takes the site id from the path, or from the body on the legacy
+// /edit-site route.
 func (controller *siteController) EditSite(ctx *gin.Context) {
 	var site entity.EditSiteRequest
 	err := ctx.ShouldBindJSON(&site)
-	if err != nil {
+	if siteId := ctx.Param("id"); siteId != "" {
+		site.Id = siteId
+	}
+	if err != nil || site.Id == "" {
 		message := "Site Id is required and cannot be empty"
-		logger.ErrorLogger.Println(err.Error())
-		logger.InfoLogger.Println(message)
+		logger.Warn(ctx, message, "error", err)
 		ctx.JSON(http.StatusBadRequest, gin.H{
 			"status":  http.StatusBadRequest,
 			"message": message,
@@ -105,20 +141,13 @@ func (controller *siteController) EditSite(ctx *gin.Context) {
 
 	userId, _ := ctx.Get("userId")
 
-	resultSite, err := controller.service.EditSite(userId.(string), site.Id, site)
+	resultSite, err := controller.service.EditSite(ctx.Request.Context(), userId.(string), site.Id, site)
 
 	if err != nil {
-		logger.ErrorLogger.Println(err.Error())
-		if customErr, ok := err.(*util.CustomError); ok {
-			logger.InfoLogger.Println(customErr.Message)
-			ctx.JSON(customErr.Status, gin.H{
-				"status":  customErr.Status,
-				"message": customErr.Message,
-			})
-		}
+		respondWithError(ctx, err)
 	} else {
 		message := "Site updated successfully"
-		logger.InfoLogger.Println(message)
+		logger.Info(ctx, message)
 		ctx.JSON(http.StatusOK, gin.H{
 			"status":  http.StatusOK,
 			"message": message,
@@ -127,13 +156,17 @@ func (controller *siteController) EditSite(ctx *gin.Context) {
 	}
 }
 
+// DeleteSite takes the site id from the path, or from the id query parameter
+// on the legacy /delete-site route.
 func (controller *siteController) DeleteSite(ctx *gin.Context) {
-	siteId := ctx.Query("id")
+	siteId := ctx.Param("id")
+	if siteId == "" {
+		siteId = ctx.Query("id")
+	}
 
 	if siteId == "" {
 		message := "Site Id is required and cannot be empty"
-		logger.ErrorLogger.Println(message)
-		logger.InfoLogger.Println(message)
+		logger.Warn(ctx, message)
 		ctx.JSON(http.StatusBadRequest, gin.H{
 			"status":  http.StatusBadRequest,
 			"message": message,
@@ -143,20 +176,13 @@ func (controller *siteController) DeleteSite(ctx *gin.Context) {
 
 	userId, _ := ctx.Get("userId")
 
-	err := controller.service.DeleteSite(userId.(string), siteId)
+	err := controller.service.DeleteSite(ctx.Request.Context(), userId.(string), siteId)
 
 	if err != nil {
-		logger.ErrorLogger.Println(err.Error())
-		if customErr, ok := err.(*util.CustomError); ok {
-			logger.InfoLogger.Println(customErr.Message)
-			ctx.JSON(customErr.Status, gin.H{
-				"status":  customErr.Status,
-				"message": customErr.Message,
-			})
-		}
+		respondWithError(ctx, err)
 	} else {
 		message := "Site deleted successfully"
-		logger.InfoLogger.Println(message)
+		logger.Info(ctx, message)
 		ctx.JSON(http.StatusOK, gin.H{
 			"status":  http.StatusOK,
 			"message": message,
@@ -0,0 +1,186 @@
+package controller
+
+import (
+	"net/http"
+	"password-manager/entity"
+	"password-manager/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (controller *authController) SignInTotp(ctx *gin.Context) {
+	var request entity.SignInTotpRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		message := "Challenge Token and Code are required and cannot be empty"
+		logger.Warn(ctx, message, "error", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": message,
+		})
+		return
+	}
+
+	result, err := controller.service.SignInTotp(ctx.Request.Context(), request.ChallengeToken, request.Code, clientInfo(ctx))
+	countSignIn("totp", result, err)
+	if err != nil {
+		respondWithError(ctx, err)
+		return
+	}
+
+	respondWithSignIn(ctx, result)
+}
+
+func (controller *authController) EnrollTotp(ctx *gin.Context) {
+	var request entity.EnrollTotpRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		message := "Password is required and cannot be empty"
+		logger.Warn(ctx, message, "error", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": message,
+		})
+		return
+	}
+
+	userId, _ := ctx.Get("userId")
+
+	secret, uri, err := controller.service.EnrollTotp(ctx.Request.Context(), userId.(string), request.Password, request.Code)
+	if err != nil {
+		respondWithError(ctx, err)
+		return
+	}
+
+	message := "TOTP enrollment started"
+	logger.Info(ctx, message)
+	ctx.JSON(http.StatusOK, gin.H{
+		"status":  http.StatusOK,
+		"message": message,
+		"secret":  secret,
+		"uri":     uri,
+	})
+}
+
+func (controller *authController) ConfirmTotp(ctx *gin.Context) {
+	var request entity.TotpCodeRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		message := "Code is required and cannot be empty"
+		logger.Warn(ctx, message, "error", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": message,
+		})
+		return
+	}
+
+	userId, _ := ctx.Get("userId")
+
+	recoveryCodes, err := controller.service.ConfirmTotp(ctx.Request.Context(), userId.(string), request.Code)
+	if err != nil {
+		respondWithError(ctx, err)
+		return
+	}
+
+	message := "TOTP enabled successfully"
+	logger.Info(ctx, message)
+	ctx.JSON(http.StatusOK, gin.H{
+		"status":        http.StatusOK,
+		"message":       message,
+		"recoveryCodes": recoveryCodes,
+	})
+}
+
+func (controller *authController) DisableTotp(ctx *gin.Context) {
+	var request entity.DisableTotpRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		message := "Password and Code are required and cannot be empty"
+		logger.Warn(ctx, message, "error", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": message,
+		})
+		return
+	}
+
+	userId, _ := ctx.Get("userId")
+
+	if err := controller.service.DisableTotp(ctx.Request.Context(), userId.(string), request.Password, request.Code); err != nil {
+		respondWithError(ctx, err)
+		return
+	}
+
+	message := "TOTP disabled successfully"
+	logger.Info(ctx, message)
+	ctx.JSON(http.StatusOK, gin.H{
+		"status":  http.StatusOK,
+		"message": message,
+	})
+}
+
+func (controller *authController) SignInRecoveryCode(ctx *gin.Context) {
+	var request entity.SignInRecoveryRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		message := "Challenge Token and Recovery Code are required and cannot be empty"
+		logger.Warn(ctx, message, "error", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": message,
+		})
+		return
+	}
+
+	result, err := controller.service.SignInRecoveryCode(ctx.Request.Context(), request.ChallengeToken, request.RecoveryCode, clientInfo(ctx))
+	countSignIn("recovery_code", result, err)
+	if err != nil {
+		respondWithError(ctx, err)
+		return
+	}
+
+	respondWithSignIn(ctx, result)
+}
+
+func (controller *authController) RegenerateRecoveryCodes(ctx *gin.Context) {
+	var request entity.PasswordRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		message := "Password is required and cannot be empty"
+		logger.Warn(ctx, message, "error", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": message,
+		})
+		return
+	}
+
+	userId, _ := ctx.Get("userId")
+
+	recoveryCodes, err := controller.service.RegenerateRecoveryCodes(ctx.Request.Context(), userId.(string), request.Password)
+	if err != nil {
+		respondWithError(ctx, err)
+		return
+	}
+
+	message := "Recovery codes regenerated successfully"
+	logger.Info(ctx, message)
+	ctx.JSON(http.StatusOK, gin.H{
+		"status":        http.StatusOK,
+		"message":       message,
+		"recoveryCodes": recoveryCodes,
+	})
+}
+
+func (controller *authController) CountRecoveryCodes(ctx *gin.Context) {
+	userId, _ := ctx.Get("userId")
+
+	remaining, err := controller.service.CountRecoveryCodes(ctx.Request.Context(), userId.(string))
+	if err != nil {
+		respondWithError(ctx, err)
+		return
+	}
+
+	message := "Recovery codes fetched successfully"
+	logger.Info(ctx, message)
+	ctx.JSON(http.StatusOK, gin.H{
+		"status":    http.StatusOK,
+		"message":   message,
+		"remaining": remaining,
+	})
+}
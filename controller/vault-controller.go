@@ -0,0 +1,188 @@
+package controller
+
+import (
+	"net/http"
+	"password-manager/entity"
+	"password-manager/logger"
+	"password-manager/service"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type VaultController interface {
+	GetKdfParams(ctx *gin.Context)
+	SetKdfParams(ctx *gin.Context)
+	SyncItems(ctx *gin.Context)
+	GetItem(ctx *gin.Context)
+	PutItem(ctx *gin.Context)
+	DeleteItem(ctx *gin.Context)
+}
+
+type vaultController struct {
+	service service.VaultService
+}
+
+func NewVaultController(service service.VaultService) VaultController {
+	return &vaultController{
+		service: service,
+	}
+}
+
+func (controller *vaultController) GetKdfParams(ctx *gin.Context) {
+	userId, _ := ctx.Get("userId")
+
+	params, err := controller.service.GetKdfParams(ctx.Request.Context(), userId.(string))
+	if err != nil {
+		respondWithError(ctx, err)
+		return
+	}
+
+	message := "KDF parameters fetched successfully"
+	logger.Info(ctx, message)
+	ctx.JSON(http.StatusOK, gin.H{
+		"status":  http.StatusOK,
+		"message": message,
+		"kdf":     params,
+	})
+}
+
+func (controller *vaultController) SetKdfParams(ctx *gin.Context) {
+	var request entity.SetKdfParamsRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		message := "Algorithm, Salt, Iterations and Revision are required and cannot be empty"
+		logger.Warn(ctx, message, "error", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": message,
+		})
+		return
+	}
+
+	userId, _ := ctx.Get("userId")
+
+	params, err := controller.service.SetKdfParams(ctx.Request.Context(), userId.(string), request.KdfParams, *request.Revision, request.Password)
+	if err != nil {
+		respondWithError(ctx, err)
+		return
+	}
+
+	message := "KDF parameters saved successfully"
+	logger.Info(ctx, message)
+	ctx.JSON(http.StatusOK, gin.H{
+		"status":  http.StatusOK,
+		"message": message,
+		"kdf":     params,
+	})
+}
+
+func (controller *vaultController) SyncItems(ctx *gin.Context) {
+	var since time.Time
+	if value := ctx.Query("since"); value != "" {
+		parsed, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			message := "Since must be an RFC 3339 timestamp"
+			logger.Warn(ctx, message, "error", err)
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"status":  http.StatusBadRequest,
+				"message": message,
+			})
+			return
+		}
+		since = parsed
+	}
+
+	userId, _ := ctx.Get("userId")
+
+	items, serverTime, err := controller.service.SyncItems(ctx.Request.Context(), userId.(string), since)
+	if err != nil {
+		respondWithError(ctx, err)
+		return
+	}
+
+	message := "Items fetched successfully"
+	logger.Info(ctx, message)
+	ctx.JSON(http.StatusOK, gin.H{
+		"status":     http.StatusOK,
+		"message":    message,
+		"items":      items,
+		"serverTime": serverTime.Format(time.RFC3339Nano),
+	})
+}
+
+func (controller *vaultController) GetItem(ctx *gin.Context) {
+	userId, _ := ctx.Get("userId")
+
+	item, err := controller.service.GetItem(ctx.Request.Context(), userId.(string), ctx.Param("id"))
+	if err != nil {
+		respondWithError(ctx, err)
+		return
+	}
+
+	message := "Item fetched successfully"
+	logger.Info(ctx, message)
+	ctx.JSON(http.StatusOK, gin.H{
+		"status":  http.StatusOK,
+		"message": message,
+		"item":    item,
+	})
+}
+
+func (controller *vaultController) PutItem(ctx *gin.Context) {
+	var request entity.PutVaultItemRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		message := "Revision and Blob are required and cannot be empty"
+		logger.Warn(ctx, message, "error", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": message,
+		})
+		return
+	}
+
+	userId, _ := ctx.Get("userId")
+
+	item, err := controller.service.PutItem(ctx.Request.Context(), userId.(string), ctx.Param("id"), *request.Revision, request.Blob)
+	if err != nil {
+		respondWithError(ctx, err)
+		return
+	}
+
+	message := "Item saved successfully"
+	logger.Info(ctx, message)
+	ctx.JSON(http.StatusOK, gin.H{
+		"status":  http.StatusOK,
+		"message": message,
+		"item":    item,
+	})
+}
+
+func (controller *vaultController) DeleteItem(ctx *gin.Context) {
+	revision, err := strconv.ParseInt(ctx.Query("revision"), 10, 64)
+	if err != nil {
+		message := "Revision is required and must be a number"
+		logger.Warn(ctx, message, "error", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": message,
+		})
+		return
+	}
+
+	userId, _ := ctx.Get("userId")
+
+	item, err := controller.service.DeleteItem(ctx.Request.Context(), userId.(string), ctx.Param("id"), revision)
+	if err != nil {
+		respondWithError(ctx, err)
+		return
+	}
+
+	message := "Item deleted successfully"
+	logger.Info(ctx, message)
+	ctx.JSON(http.StatusOK, gin.H{
+		"status":  http.StatusOK,
+		"message": message,
+		"item":    item,
+	})
+}
@@ -0,0 +1,95 @@
+package db
+
+import (
+	"context"
+	"net/http"
+	"password-manager/constants"
+	"password-manager/entity"
+	"password-manager/util"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoAttemptStore struct {
+	database *mongo.Database
+}
+
+func NewMongoAttemptStore(database *mongo.Database) AttemptStore {
+	return &mongoAttemptStore{
+		database: database,
+	}
+}
+
+func (store *mongoAttemptStore) GetAttempts(ctx context.Context, key string) (counter entity.AttemptCounter, err error) {
+	ctx, done := instrument(ctx, "attempts.GetAttempts")
+	defer done(&err)
+
+	attemptsCollection := store.database.Collection(constants.AttemptsCollection)
+
+	err = attemptsCollection.FindOne(ctx, bson.M{"key": key, "expireAt": bson.M{"$gt": time.Now().UTC()}}).Decode(&counter)
+	if err == mongo.ErrNoDocuments {
+		return entity.AttemptCounter{Key: key}, nil
+	}
+	if err != nil {
+		return entity.AttemptCounter{}, &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
+	}
+
+	return counter, nil
+}
+
+// RecordFailure increments the counter in a single pipeline update, starting
+// again from one when the previous counter has expired.
+func (store *mongoAttemptStore) RecordFailure(ctx context.Context, key string, now time.Time, window time.Duration) (counter entity.AttemptCounter, err error) {
+	ctx, done := instrument(ctx, "attempts.RecordFailure")
+	defer done(&err)
+
+	attemptsCollection := store.database.Collection(constants.AttemptsCollection)
+
+	now = now.UTC()
+	live := bson.M{"$gt": bson.A{"$expireAt", now}}
+	update := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"failures":     bson.M{"$cond": bson.A{live, bson.M{"$add": bson.A{"$failures", 1}}, 1}},
+			"blockedUntil": bson.M{"$cond": bson.A{live, "$blockedUntil", time.Time{}}},
+			"expireAt":     bson.M{"$cond": bson.A{live, bson.M{"$max": bson.A{"$blockedUntil", now.Add(window)}}, now.Add(window)}},
+		}}},
+	}
+	options := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	err = attemptsCollection.FindOneAndUpdate(ctx, bson.M{"key": key}, update, options).Decode(&counter)
+	if err != nil {
+		return entity.AttemptCounter{}, &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
+	}
+
+	return counter, nil
+}
+
+func (store *mongoAttemptStore) BlockAttempts(ctx context.Context, key string, until time.Time) (err error) {
+	ctx, done := instrument(ctx, "attempts.BlockAttempts")
+	defer done(&err)
+
+	attemptsCollection := store.database.Collection(constants.AttemptsCollection)
+
+	update := bson.M{"$max": bson.M{"blockedUntil": until.UTC(), "expireAt": until.UTC()}}
+	if _, err = attemptsCollection.UpdateOne(ctx, bson.M{"key": key}, update); err != nil {
+		return &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
+	}
+
+	return nil
+}
+
+func (store *mongoAttemptStore) ResetAttempts(ctx context.Context, key string) (err error) {
+	ctx, done := instrument(ctx, "attempts.ResetAttempts")
+	defer done(&err)
+
+	attemptsCollection := store.database.Collection(constants.AttemptsCollection)
+
+	if _, err = attemptsCollection.DeleteOne(ctx, bson.M{"key": key}); err != nil {
+		return &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
+	}
+
+	return nil
+}
@@ -4,199 +4,318 @@ import (
 	"context"
 	"net/http"
 	"password-manager/constants"
-	"password-manager/logger"
+	"password-manager/entity"
 	"password-manager/util"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-func RegisterUser(email string, password string) (userId string, err error) {
-	client, err := DbSetup()
-	if err != nil {
-		logger.ErrorLogger.Println(err.Error())
-		return "", &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError}
+// ErrEmailTaken is returned by RegisterUser when the email already has an
+// account.
+var ErrEmailTaken = &util.CustomError{Message: "Email is already registered", Status: http.StatusConflict}
+
+type mongoUserStore struct {
+	database *mongo.Database
+}
+
+func NewMongoUserStore(database *mongo.Database) UserStore {
+	return &mongoUserStore{
+		database: database,
 	}
-	defer client.Disconnect(context.Background())
+}
+
+func (store *mongoUserStore) RegisterUser(ctx context.Context, email string, password string) (userId string, err error) {
+	ctx, done := instrument(ctx, "users.RegisterUser")
+	defer done(&err)
 
-	usersCollection := client.Database(constants.DatabaseName).Collection(constants.UsersCollection)
+	usersCollection := store.database.Collection(constants.UsersCollection)
 
-	insertResult, err := usersCollection.InsertOne(context.Background(), bson.M{"email": email, "password": password, "passwordSetAt": time.Now().UTC().Format(time.RFC3339)})
+	// The upsert only inserts when no user has the email. Two upserts racing
+	// can both miss, so the unique index on email settles that case.
+	filter := bson.M{"email": email}
+	update := bson.M{"$setOnInsert": bson.M{"email": email, "password": password, "passwordSetAt": time.Now().UTC().Format(time.RFC3339)}}
+	result, err := usersCollection.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) || (err == nil && result.UpsertedID == nil) {
+		return "", ErrEmailTaken
+	}
 	if err != nil {
-		logger.ErrorLogger.Println(err.Error())
-		return "", &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError}
+		return "", &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
 	}
 
-	return insertResult.InsertedID.(primitive.ObjectID).Hex(), nil
+	return result.UpsertedID.(primitive.ObjectID).Hex(), nil
 }
 
-func ResetPassword(email string, password string) (passwordSetAt string, err error) {
-	client, err := DbSetup()
-	if err != nil {
-		logger.ErrorLogger.Println(err.Error())
-		return "", &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError}
-	}
-	defer client.Disconnect(context.Background())
+func (store *mongoUserStore) ResetPassword(ctx context.Context, email string, password string) (passwordSetAt string, err error) {
+	ctx, done := instrument(ctx, "users.ResetPassword")
+	defer done(&err)
 
-	usersCollection := client.Database(constants.DatabaseName).Collection(constants.UsersCollection)
+	usersCollection := store.database.Collection(constants.UsersCollection)
 
 	timestamp := time.Now().UTC().Format(time.RFC3339)
 	filter := bson.M{"email": email}
 	update := bson.M{"$set": bson.M{"password": password, "passwordSetAt": timestamp}}
-	_, err = usersCollection.UpdateOne(context.Background(), filter, update)
+	_, err = usersCollection.UpdateOne(ctx, filter, update)
 	if err != nil {
-		logger.ErrorLogger.Println(err.Error())
-		return "", &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError}
+		return "", &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
 	}
 
 	return timestamp, nil
 }
 
-func CheckPasswordReset(userId string) (passwordSetAt string, err error) {
-	client, err := DbSetup()
-	if err != nil {
-		logger.ErrorLogger.Println(err.Error())
-		return "", &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError}
-	}
-	defer client.Disconnect(context.Background())
+func (store *mongoUserStore) CheckPasswordReset(ctx context.Context, userId string) (passwordSetAt string, err error) {
+	ctx, done := instrument(ctx, "users.CheckPasswordReset")
+	defer done(&err)
 
-	usersCollection := client.Database(constants.DatabaseName).Collection(constants.UsersCollection)
+	usersCollection := store.database.Collection(constants.UsersCollection)
 
 	userObjId, err := primitive.ObjectIDFromHex(userId)
 	if err != nil {
-		logger.ErrorLogger.Println(err.Error())
-		return "", &util.CustomError{Message: "Invalid User Id", Status: http.StatusBadRequest}
+		return "", &util.CustomError{Message: "Invalid User Id", Status: http.StatusBadRequest, Cause: err}
 	}
 
-	result := usersCollection.FindOne(context.Background(), bson.M{"_id": userObjId})
-
-	var user bson.M
-	result.Decode(&user)
-
-	return user["passwordSetAt"].(string), nil
-}
-
-func CheckUserRegistered(email string) (status bool, err error) {
-	client, err := DbSetup()
+	var user entity.User
+	err = usersCollection.FindOne(ctx, bson.M{"_id": userObjId}).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		return "", &util.CustomError{Message: "User not found", Status: http.StatusUnauthorized}
+	}
 	if err != nil {
-		logger.ErrorLogger.Println(err.Error())
-		return false, &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError}
+		return "", &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
 	}
-	defer client.Disconnect(context.Background())
 
-	usersCollection := client.Database(constants.DatabaseName).Collection(constants.UsersCollection)
+	return user.PasswordSetAt, nil
+}
 
-	result := usersCollection.FindOne(context.Background(), bson.M{"email": email})
+func (store *mongoUserStore) CheckUserRegistered(ctx context.Context, email string) (status bool, err error) {
+	ctx, done := instrument(ctx, "users.CheckUserRegistered")
+	defer done(&err)
 
-	var user bson.M
-	result.Decode(&user)
+	usersCollection := store.database.Collection(constants.UsersCollection)
 
-	if user == nil {
+	err = usersCollection.FindOne(ctx, bson.M{"email": email}).Err()
+	if err == mongo.ErrNoDocuments {
 		return false, nil
 	}
+	if err != nil {
+		return false, &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
+	}
 
 	return true, nil
 }
 
-func CheckUserCredentials(email string, password string) (status bool, userId string, passwordSetAt string, err error) {
-	client, err := DbSetup()
+func (store *mongoUserStore) FindUserByEmail(ctx context.Context, email string) (user entity.User, found bool, err error) {
+	ctx, done := instrument(ctx, "users.FindUserByEmail")
+	defer done(&err)
+
+	usersCollection := store.database.Collection(constants.UsersCollection)
+
+	err = usersCollection.FindOne(ctx, bson.M{"email": email}).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		return entity.User{}, false, nil
+	}
 	if err != nil {
-		logger.ErrorLogger.Println(err.Error())
-		return false, "", "", &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError}
+		return entity.User{}, false, &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
 	}
-	defer client.Disconnect(context.Background())
 
-	usersCollection := client.Database(constants.DatabaseName).Collection(constants.UsersCollection)
+	return user, true, nil
+}
 
-	result := usersCollection.FindOne(context.Background(), bson.M{"email": email, "password": password})
+func (store *mongoUserStore) FindUserById(ctx context.Context, userId string) (user entity.User, found bool, err error) {
+	ctx, done := instrument(ctx, "users.FindUserById")
+	defer done(&err)
 
-	var user bson.M
-	result.Decode(&user)
+	usersCollection := store.database.Collection(constants.UsersCollection)
 
-	if user == nil {
-		return false, "", "", nil
+	userObjId, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return entity.User{}, false, &util.CustomError{Message: "Invalid User Id", Status: http.StatusBadRequest, Cause: err}
 	}
 
-	return true, user["_id"].(primitive.ObjectID).Hex(), user["passwordSetAt"].(string), nil
+	err = usersCollection.FindOne(ctx, bson.M{"_id": userObjId}).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		return entity.User{}, false, nil
+	}
+	if err != nil {
+		return entity.User{}, false, &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
+	}
+
+	return user, true, nil
 }
 
-func CheckUserCredentialsWithId(userId string, password string) (email string, err error) {
-	client, err := DbSetup()
+func (store *mongoUserStore) RehashPassword(ctx context.Context, userId string, oldPassword string, newPassword string) (err error) {
+	ctx, done := instrument(ctx, "users.RehashPassword")
+	defer done(&err)
+
+	usersCollection := store.database.Collection(constants.UsersCollection)
+
+	userObjId, err := primitive.ObjectIDFromHex(userId)
 	if err != nil {
-		logger.ErrorLogger.Println(err.Error())
-		return "", &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError}
+		return &util.CustomError{Message: "Invalid User Id", Status: http.StatusBadRequest, Cause: err}
+	}
+
+	filter := bson.M{"_id": userObjId, "password": oldPassword}
+	update := bson.M{"$set": bson.M{"password": newPassword}}
+	if _, err = usersCollection.UpdateOne(ctx, filter, update); err != nil {
+		return &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
 	}
-	defer client.Disconnect(context.Background())
 
-	usersCollection := client.Database(constants.DatabaseName).Collection(constants.UsersCollection)
+	return nil
+}
+
+func (store *mongoUserStore) SetKdfParams(ctx context.Context, userId string, params entity.KdfParams, expectedRevision int64) (written bool, err error) {
+	ctx, done := instrument(ctx, "users.SetKdfParams")
+	defer done(&err)
+
+	usersCollection := store.database.Collection(constants.UsersCollection)
 
 	userObjId, err := primitive.ObjectIDFromHex(userId)
 	if err != nil {
-		logger.ErrorLogger.Println(err.Error())
-		return "", &util.CustomError{Message: "Invalid User Id", Status: http.StatusBadRequest}
+		return false, &util.CustomError{Message: "Invalid User Id", Status: http.StatusBadRequest, Cause: err}
 	}
 
-	result := usersCollection.FindOne(context.Background(), bson.M{"_id": userObjId, "password": password})
+	filter := bson.M{"_id": userObjId, "kdf.revision": expectedRevision}
+	if expectedRevision == 0 {
+		filter = bson.M{"_id": userObjId, "kdf": bson.M{"$exists": false}}
+	}
+	result, err := usersCollection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"kdf": params}})
+	if err != nil {
+		return false, &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
+	}
 
-	var user bson.M
-	result.Decode(&user)
+	return result.MatchedCount == 1, nil
+}
+
+func (store *mongoUserStore) SetTotp(ctx context.Context, userId string, totp *entity.TotpSettings) (err error) {
+	ctx, done := instrument(ctx, "users.SetTotp")
+	defer done(&err)
+
+	usersCollection := store.database.Collection(constants.UsersCollection)
 
-	if user == nil {
-		return "", nil
+	userObjId, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return &util.CustomError{Message: "Invalid User Id", Status: http.StatusBadRequest, Cause: err}
 	}
 
-	return user["email"].(string), nil
+	update := bson.M{"$set": bson.M{"totp": totp}}
+	if totp == nil {
+		update = bson.M{"$unset": bson.M{"totp": ""}}
+	}
+	if _, err = usersCollection.UpdateOne(ctx, bson.M{"_id": userObjId}, update); err != nil {
+		return &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
+	}
+
+	return nil
 }
 
-func BlacklistToken(token string, expirationTime time.Time) (err error) {
-	client, err := DbSetup()
+// UseTotpStep records step as the last accepted code, refusing it when that
+// step or a later one has already been used.
+func (store *mongoUserStore) UseTotpStep(ctx context.Context, userId string, step int64) (accepted bool, err error) {
+	ctx, done := instrument(ctx, "users.UseTotpStep")
+	defer done(&err)
+
+	usersCollection := store.database.Collection(constants.UsersCollection)
+
+	userObjId, err := primitive.ObjectIDFromHex(userId)
 	if err != nil {
-		logger.ErrorLogger.Println(err.Error())
-		return &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError}
+		return false, &util.CustomError{Message: "Invalid User Id", Status: http.StatusBadRequest, Cause: err}
 	}
-	defer client.Disconnect(context.Background())
 
-	blacklistCollection := client.Database(constants.DatabaseName).Collection(constants.BlacklistCollection)
+	filter := bson.M{"_id": userObjId, "totp.enabled": true, "totp.lastUsedStep": bson.M{"$lt": step}}
+	update := bson.M{"$set": bson.M{"totp.lastUsedStep": step}}
+	result, err := usersCollection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
+	}
+
+	return result.ModifiedCount == 1, nil
+}
+
+func (store *mongoUserStore) SetRecoveryCodes(ctx context.Context, userId string, codeHashes []string) (err error) {
+	ctx, done := instrument(ctx, "users.SetRecoveryCodes")
+	defer done(&err)
 
-	// index := mongo.IndexModel{
-	// 	Keys:    bson.M{"expireAt": 1},
-	// 	Options: options.Index().SetExpireAfterSeconds(0),
-	// }
+	usersCollection := store.database.Collection(constants.UsersCollection)
 
-	// _, err = blacklistCollection.Indexes().CreateOne(context.Background(), index)
-	// if err != nil {
-	// 	logger.ErrorLogger.Println(err.Error())
-	// 	return &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError}
-	// }
+	userObjId, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return &util.CustomError{Message: "Invalid User Id", Status: http.StatusBadRequest, Cause: err}
+	}
 
-	if _, err = blacklistCollection.InsertOne(context.Background(), bson.M{"token": token, "expireAt": expirationTime}); err != nil {
-		logger.ErrorLogger.Println(err.Error())
-		return &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError}
+	update := bson.M{"$set": bson.M{"recoveryCodes": codeHashes}}
+	if len(codeHashes) == 0 {
+		update = bson.M{"$unset": bson.M{"recoveryCodes": ""}}
+	}
+	if _, err = usersCollection.UpdateOne(ctx, bson.M{"_id": userObjId}, update); err != nil {
+		return &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
 	}
 
 	return nil
 }
 
-func CheckBlacklist(token string) (blacklisted bool, err error) {
+// UseRecoveryCode removes codeHash from the user's remaining codes. The
+// filter and $pull run as one update, so a code can only be spent once.
+func (store *mongoUserStore) UseRecoveryCode(ctx context.Context, userId string, codeHash string) (used bool, err error) {
+	ctx, done := instrument(ctx, "users.UseRecoveryCode")
+	defer done(&err)
 
-	client, err := DbSetup()
+	usersCollection := store.database.Collection(constants.UsersCollection)
+
+	userObjId, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return false, &util.CustomError{Message: "Invalid User Id", Status: http.StatusBadRequest, Cause: err}
+	}
+
+	filter := bson.M{"_id": userObjId, "recoveryCodes": codeHash}
+	update := bson.M{"$pull": bson.M{"recoveryCodes": codeHash}}
+	result, err := usersCollection.UpdateOne(ctx, filter, update)
 	if err != nil {
-		logger.ErrorLogger.Println(err.Error())
-		return false, &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError}
+		return false, &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
+	}
+
+	return result.ModifiedCount == 1, nil
+}
+
+type mongoTokenBlacklist struct {
+	database *mongo.Database
+}
+
+func NewMongoTokenBlacklist(database *mongo.Database) TokenBlacklist {
+	return &mongoTokenBlacklist{
+		database: database,
+	}
+}
+
+func (store *mongoTokenBlacklist) BlacklistToken(ctx context.Context, token string, expirationTime time.Time) (err error) {
+	ctx, done := instrument(ctx, "blacklist.BlacklistToken")
+	defer done(&err)
+
+	blacklistCollection := store.database.Collection(constants.BlacklistCollection)
+
+	if _, err = blacklistCollection.InsertOne(ctx, bson.M{"token": token, "expireAt": expirationTime}); err != nil {
+		return &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
 	}
-	defer client.Disconnect(context.Background())
 
-	blacklistCollection := client.Database(constants.DatabaseName).Collection(constants.BlacklistCollection)
+	return nil
+}
 
-	result := blacklistCollection.FindOne(context.Background(), bson.M{"token": token})
+func (store *mongoTokenBlacklist) CheckBlacklist(ctx context.Context, token string) (blacklisted bool, err error) {
+	ctx, done := instrument(ctx, "blacklist.CheckBlacklist")
+	defer done(&err)
 
-	var blacklist bson.M
-	result.Decode(&blacklist)
+	blacklistCollection := store.database.Collection(constants.BlacklistCollection)
 
-	if blacklist == nil {
+	// A failed lookup is an error, never "not blacklisted", so a signed-out
+	// token is not accepted because the database was slow.
+	err = blacklistCollection.FindOne(ctx, bson.M{"token": token}).Err()
+	if err == mongo.ErrNoDocuments {
 		return false, nil
 	}
+	if err != nil {
+		return false, &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
+	}
 
 	return true, nil
 }
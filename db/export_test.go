@@ -0,0 +1,5 @@
+package db
+
+// Instrument lets the external tests wrap memory stores the way the Mongo
+// stores are wrapped.
+var Instrument = instrument
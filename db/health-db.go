@@ -0,0 +1,32 @@
+package db
+
+import (
+	"context"
+	"net/http"
+	"password-manager/util"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+type mongoHealthStore struct {
+	database *mongo.Database
+}
+
+func NewMongoHealthStore(database *mongo.Database) HealthStore {
+	return &mongoHealthStore{
+		database: database,
+	}
+}
+
+func (store *mongoHealthStore) Ping(ctx context.Context) (err error) {
+	ctx, done := instrument(ctx, "health.Ping")
+	defer done(&err)
+
+	err = store.database.Client().Ping(ctx, readpref.Primary())
+	if err != nil {
+		return &util.CustomError{Message: "Database unreachable", Status: http.StatusServiceUnavailable, Cause: err}
+	}
+
+	return nil
+}
@@ -2,26 +2,81 @@ package db
 
 import (
 	"context"
-	"os"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.opentelemetry.io/contrib/instrumentation/go.mongodb.org/mongo-driver/mongo/otelmongo"
 )
 
-func DbSetup() (client *mongo.Client, err error) {
-	connectionString := "mongodb+srv://" + os.Getenv("MONGO_USERNAME") + ":" + os.Getenv("MONGO_PASSWORD") + "@cluster1.5jqwhvz.mongodb.net/?retryWrites=true&w=majority"
-	clientOptions := options.Client().ApplyURI(connectionString)
+type MongoConfig struct {
+	URI                    string
+	Database               string
+	MinPoolSize            uint64
+	MaxPoolSize            uint64
+	MaxConnIdleTime        time.Duration
+	ConnectTimeout         time.Duration
+	ServerSelectionTimeout time.Duration
+	// OperationTimeout bounds each store operation on top of the caller's
+	// own deadline.
+	OperationTimeout time.Duration
+}
+
+var (
+	clientMu     sync.Mutex
+	sharedClient *mongo.Client
+	// operationTimeout is set by Connect and read by every store operation.
+	operationTimeout atomic.Int64
+)
+
+// Connect returns the process-wide client, creating it on first use. A failed
+// attempt is not cached so a later call can retry.
+func Connect(config MongoConfig) (client *mongo.Client, err error) {
+	clientMu.Lock()
+	defer clientMu.Unlock()
+
+	if sharedClient != nil {
+		return sharedClient, nil
+	}
+
+	clientOptions := options.Client().
+		ApplyURI(config.URI).
+		SetMinPoolSize(config.MinPoolSize).
+		SetMaxPoolSize(config.MaxPoolSize).
+		SetMaxConnIdleTime(config.MaxConnIdleTime).
+		SetConnectTimeout(config.ConnectTimeout).
+		SetServerSelectionTimeout(config.ServerSelectionTimeout).
+		// Commands carry password and OTP hashes, so they stay out of spans.
+		SetMonitor(otelmongo.NewMonitor(otelmongo.WithCommandAttributeDisabled(true)))
 
-	// Establish a connection to the MongoDB server.
-	client, err = mongo.Connect(context.Background(), clientOptions)
+	ctx, cancel := context.WithTimeout(context.Background(), config.ConnectTimeout)
+	defer cancel()
+
+	client, err = mongo.Connect(ctx, clientOptions)
 	if err != nil {
 		return nil, err
 	}
 
-	err = client.Ping(context.Background(), nil)
-	if err != nil {
+	if err = client.Ping(ctx, nil); err != nil {
+		client.Disconnect(context.Background())
 		return nil, err
 	}
 
-	return client, nil
+	operationTimeout.Store(int64(config.OperationTimeout))
+	sharedClient = client
+	return sharedClient, nil
+}
+
+func Disconnect(ctx context.Context) (err error) {
+	clientMu.Lock()
+	defer clientMu.Unlock()
+
+	if sharedClient == nil {
+		return nil
+	}
+	err = sharedClient.Disconnect(ctx)
+	sharedClient = nil
+	return err
 }
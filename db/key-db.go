@@ -0,0 +1,109 @@
+package db
+
+import (
+	"context"
+	"net/http"
+	"password-manager/constants"
+	"password-manager/entity"
+	"password-manager/util"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoDataKeyStore struct {
+	database *mongo.Database
+}
+
+func NewMongoDataKeyStore(database *mongo.Database) DataKeyStore {
+	return &mongoDataKeyStore{
+		database: database,
+	}
+}
+
+func (store *mongoDataKeyStore) GetDataKey(ctx context.Context, userId string, keyId string) (key entity.DataKey, found bool, err error) {
+	ctx, done := instrument(ctx, "dataKeys.GetDataKey")
+	defer done(&err)
+
+	dataKeysCollection := store.database.Collection(constants.DataKeysCollection)
+
+	err = dataKeysCollection.FindOne(ctx, bson.M{"userId": userId, "keyId": keyId}).Decode(&key)
+	if err == mongo.ErrNoDocuments {
+		return entity.DataKey{}, false, nil
+	}
+	if err != nil {
+		return entity.DataKey{}, false, &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
+	}
+
+	return key, true, nil
+}
+
+func (store *mongoDataKeyStore) GetActiveDataKey(ctx context.Context, userId string) (key entity.DataKey, found bool, err error) {
+	ctx, done := instrument(ctx, "dataKeys.GetActiveDataKey")
+	defer done(&err)
+
+	dataKeysCollection := store.database.Collection(constants.DataKeysCollection)
+
+	options := options.FindOne().SetSort(bson.D{{Key: "createdAt", Value: -1}})
+	err = dataKeysCollection.FindOne(ctx, bson.M{"userId": userId}, options).Decode(&key)
+	if err == mongo.ErrNoDocuments {
+		return entity.DataKey{}, false, nil
+	}
+	if err != nil {
+		return entity.DataKey{}, false, &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
+	}
+
+	return key, true, nil
+}
+
+func (store *mongoDataKeyStore) CreateDataKey(ctx context.Context, key entity.DataKey) (err error) {
+	ctx, done := instrument(ctx, "dataKeys.CreateDataKey")
+	defer done(&err)
+
+	dataKeysCollection := store.database.Collection(constants.DataKeysCollection)
+
+	if _, err = dataKeysCollection.InsertOne(ctx, key); err != nil {
+		return &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
+	}
+
+	return nil
+}
+
+func (store *mongoDataKeyStore) ListDataKeysNotWrappedBy(ctx context.Context, masterKeyId string) (keys []entity.DataKey, err error) {
+	ctx, done := instrument(ctx, "dataKeys.ListDataKeysNotWrappedBy")
+	defer done(&err)
+
+	dataKeysCollection := store.database.Collection(constants.DataKeysCollection)
+
+	cursor, err := dataKeysCollection.Find(ctx, bson.M{"masterKeyId": bson.M{"$ne": masterKeyId}})
+	if err != nil {
+		return []entity.DataKey{}, &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
+	}
+
+	keys = []entity.DataKey{}
+	if err = cursor.All(ctx, &keys); err != nil {
+		return []entity.DataKey{}, &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
+	}
+
+	return keys, nil
+}
+
+func (store *mongoDataKeyStore) RewrapDataKey(ctx context.Context, key entity.DataKey, previousMasterKeyId string) (updated bool, err error) {
+	ctx, done := instrument(ctx, "dataKeys.RewrapDataKey")
+	defer done(&err)
+
+	dataKeysCollection := store.database.Collection(constants.DataKeysCollection)
+
+	filter := bson.M{"userId": key.UserId, "keyId": key.Id, "masterKeyId": previousMasterKeyId}
+	update := bson.M{"$set": bson.M{
+		"wrappedKey":  key.WrappedKey,
+		"masterKeyId": key.MasterKeyId,
+	}}
+	result, err := dataKeysCollection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
+	}
+
+	return result.ModifiedCount == 1, nil
+}
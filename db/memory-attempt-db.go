@@ -0,0 +1,77 @@
+package db
+
+import (
+	"context"
+	"password-manager/entity"
+	"sync"
+	"time"
+)
+
+type memoryAttemptStore struct {
+	mu       sync.Mutex
+	counters map[string]*entity.AttemptCounter
+}
+
+func NewMemoryAttemptStore() AttemptStore {
+	return &memoryAttemptStore{
+		counters: map[string]*entity.AttemptCounter{},
+	}
+}
+
+func (store *memoryAttemptStore) GetAttempts(ctx context.Context, key string) (counter entity.AttemptCounter, err error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	existing, ok := store.counters[key]
+	if !ok || !existing.ExpiresAt.After(time.Now()) {
+		return entity.AttemptCounter{Key: key}, nil
+	}
+	return *existing, nil
+}
+
+func (store *memoryAttemptStore) RecordFailure(ctx context.Context, key string, now time.Time, window time.Duration) (counter entity.AttemptCounter, err error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	for id, existing := range store.counters {
+		if !existing.ExpiresAt.After(now) {
+			delete(store.counters, id)
+		}
+	}
+
+	existing, ok := store.counters[key]
+	if !ok {
+		existing = &entity.AttemptCounter{Key: key}
+		store.counters[key] = existing
+	}
+	existing.Failures++
+	existing.ExpiresAt = now.Add(window)
+	if existing.BlockedUntil.After(existing.ExpiresAt) {
+		existing.ExpiresAt = existing.BlockedUntil
+	}
+
+	return *existing, nil
+}
+
+func (store *memoryAttemptStore) BlockAttempts(ctx context.Context, key string, until time.Time) (err error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if existing, ok := store.counters[key]; ok {
+		if until.After(existing.BlockedUntil) {
+			existing.BlockedUntil = until
+		}
+		if until.After(existing.ExpiresAt) {
+			existing.ExpiresAt = until
+		}
+	}
+	return nil
+}
+
+func (store *memoryAttemptStore) ResetAttempts(ctx context.Context, key string) (err error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	delete(store.counters, key)
+	return nil
+}
@@ -0,0 +1,283 @@
+package db
+
+import (
+	"context"
+	"net/http"
+	"password-manager/entity"
+	"password-manager/util"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type memoryUser struct {
+	id            string
+	email         string
+	password      string
+	passwordSetAt string
+	kdf           *entity.KdfParams
+	totp          *entity.TotpSettings
+	recoveryCodes []string
+}
+
+func (user *memoryUser) toEntity() entity.User {
+	var totp *entity.TotpSettings
+	if user.totp != nil {
+		settings := *user.totp
+		totp = &settings
+	}
+
+	return entity.User{
+		Id:            user.id,
+		Email:         user.email,
+		Password:      user.password,
+		PasswordSetAt: user.passwordSetAt,
+		Kdf:           user.kdf,
+		Totp:          totp,
+		RecoveryCodes: append([]string(nil), user.recoveryCodes...),
+	}
+}
+
+type memoryUserStore struct {
+	mu    sync.RWMutex
+	users map[string]*memoryUser
+}
+
+func NewMemoryUserStore() UserStore {
+	return &memoryUserStore{
+		users: map[string]*memoryUser{},
+	}
+}
+
+func (store *memoryUserStore) findByEmail(email string) *memoryUser {
+	for _, user := range store.users {
+		if user.email == email {
+			return user
+		}
+	}
+	return nil
+}
+
+func (store *memoryUserStore) RegisterUser(ctx context.Context, email string, password string) (userId string, err error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if store.findByEmail(email) != nil {
+		return "", ErrEmailTaken
+	}
+
+	user := &memoryUser{
+		id:            primitive.NewObjectID().Hex(),
+		email:         email,
+		password:      password,
+		passwordSetAt: time.Now().UTC().Format(time.RFC3339),
+	}
+	store.users[user.id] = user
+
+	return user.id, nil
+}
+
+func (store *memoryUserStore) ResetPassword(ctx context.Context, email string, password string) (passwordSetAt string, err error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	timestamp := time.Now().UTC().Format(time.RFC3339)
+	if user := store.findByEmail(email); user != nil {
+		user.password = password
+		user.passwordSetAt = timestamp
+	}
+
+	return timestamp, nil
+}
+
+func (store *memoryUserStore) CheckPasswordReset(ctx context.Context, userId string) (passwordSetAt string, err error) {
+	if _, err = primitive.ObjectIDFromHex(userId); err != nil {
+		return "", &util.CustomError{Message: "Invalid User Id", Status: http.StatusBadRequest}
+	}
+
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	user, ok := store.users[userId]
+	if !ok {
+		return "", &util.CustomError{Message: "User not found", Status: http.StatusUnauthorized}
+	}
+
+	return user.passwordSetAt, nil
+}
+
+func (store *memoryUserStore) CheckUserRegistered(ctx context.Context, email string) (status bool, err error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	return store.findByEmail(email) != nil, nil
+}
+
+func (store *memoryUserStore) FindUserByEmail(ctx context.Context, email string) (user entity.User, found bool, err error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	record := store.findByEmail(email)
+	if record == nil {
+		return entity.User{}, false, nil
+	}
+
+	return record.toEntity(), true, nil
+}
+
+func (store *memoryUserStore) FindUserById(ctx context.Context, userId string) (user entity.User, found bool, err error) {
+	if _, err = primitive.ObjectIDFromHex(userId); err != nil {
+		return entity.User{}, false, &util.CustomError{Message: "Invalid User Id", Status: http.StatusBadRequest}
+	}
+
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	record, ok := store.users[userId]
+	if !ok {
+		return entity.User{}, false, nil
+	}
+
+	return record.toEntity(), true, nil
+}
+
+func (store *memoryUserStore) RehashPassword(ctx context.Context, userId string, oldPassword string, newPassword string) (err error) {
+	if _, err = primitive.ObjectIDFromHex(userId); err != nil {
+		return &util.CustomError{Message: "Invalid User Id", Status: http.StatusBadRequest}
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if record, ok := store.users[userId]; ok && record.password == oldPassword {
+		record.password = newPassword
+	}
+
+	return nil
+}
+
+func (store *memoryUserStore) SetKdfParams(ctx context.Context, userId string, params entity.KdfParams, expectedRevision int64) (written bool, err error) {
+	if _, err = primitive.ObjectIDFromHex(userId); err != nil {
+		return false, &util.CustomError{Message: "Invalid User Id", Status: http.StatusBadRequest}
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	record, ok := store.users[userId]
+	if !ok || (expectedRevision == 0 && record.kdf != nil) || (expectedRevision != 0 && (record.kdf == nil || record.kdf.Revision != expectedRevision)) {
+		return false, nil
+	}
+	record.kdf = &params
+
+	return true, nil
+}
+
+func (store *memoryUserStore) SetTotp(ctx context.Context, userId string, totp *entity.TotpSettings) (err error) {
+	if _, err = primitive.ObjectIDFromHex(userId); err != nil {
+		return &util.CustomError{Message: "Invalid User Id", Status: http.StatusBadRequest}
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if record, ok := store.users[userId]; ok {
+		if totp == nil {
+			record.totp = nil
+		} else {
+			settings := *totp
+			record.totp = &settings
+		}
+	}
+
+	return nil
+}
+
+func (store *memoryUserStore) UseTotpStep(ctx context.Context, userId string, step int64) (accepted bool, err error) {
+	if _, err = primitive.ObjectIDFromHex(userId); err != nil {
+		return false, &util.CustomError{Message: "Invalid User Id", Status: http.StatusBadRequest}
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	record, ok := store.users[userId]
+	if !ok || record.totp == nil || !record.totp.Enabled || record.totp.LastUsedStep >= step {
+		return false, nil
+	}
+	record.totp.LastUsedStep = step
+
+	return true, nil
+}
+
+func (store *memoryUserStore) SetRecoveryCodes(ctx context.Context, userId string, codeHashes []string) (err error) {
+	if _, err = primitive.ObjectIDFromHex(userId); err != nil {
+		return &util.CustomError{Message: "Invalid User Id", Status: http.StatusBadRequest}
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if record, ok := store.users[userId]; ok {
+		record.recoveryCodes = append([]string(nil), codeHashes...)
+	}
+
+	return nil
+}
+
+func (store *memoryUserStore) UseRecoveryCode(ctx context.Context, userId string, codeHash string) (used bool, err error) {
+	if _, err = primitive.ObjectIDFromHex(userId); err != nil {
+		return false, &util.CustomError{Message: "Invalid User Id", Status: http.StatusBadRequest}
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	record, ok := store.users[userId]
+	if !ok {
+		return false, nil
+	}
+	for i, hash := range record.recoveryCodes {
+		if hash == codeHash {
+			record.recoveryCodes = append(record.recoveryCodes[:i:i], record.recoveryCodes[i+1:]...)
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+type memoryTokenBlacklist struct {
+	mu     sync.RWMutex
+	tokens map[string]time.Time
+}
+
+func NewMemoryTokenBlacklist() TokenBlacklist {
+	return &memoryTokenBlacklist{
+		tokens: map[string]time.Time{},
+	}
+}
+
+func (store *memoryTokenBlacklist) BlacklistToken(ctx context.Context, token string, expirationTime time.Time) (err error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	now := time.Now()
+	for t, expireAt := range store.tokens {
+		if expireAt.Before(now) {
+			delete(store.tokens, t)
+		}
+	}
+	store.tokens[token] = expirationTime
+
+	return nil
+}
+
+func (store *memoryTokenBlacklist) CheckBlacklist(ctx context.Context, token string) (blacklisted bool, err error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	_, blacklisted = store.tokens[token]
+	return blacklisted, nil
+}
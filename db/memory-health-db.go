@@ -0,0 +1,13 @@
+package db
+
+import "context"
+
+type memoryHealthStore struct{}
+
+func NewMemoryHealthStore() HealthStore {
+	return &memoryHealthStore{}
+}
+
+func (store *memoryHealthStore) Ping(ctx context.Context) (err error) {
+	return nil
+}
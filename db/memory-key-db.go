@@ -0,0 +1,75 @@
+package db
+
+import (
+	"context"
+	"password-manager/entity"
+	"sync"
+)
+
+type memoryDataKeyStore struct {
+	mu   sync.RWMutex
+	keys []entity.DataKey
+}
+
+func NewMemoryDataKeyStore() DataKeyStore {
+	return &memoryDataKeyStore{}
+}
+
+func (store *memoryDataKeyStore) GetDataKey(ctx context.Context, userId string, keyId string) (key entity.DataKey, found bool, err error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	for _, key := range store.keys {
+		if key.UserId == userId && key.Id == keyId {
+			return key, true, nil
+		}
+	}
+	return entity.DataKey{}, false, nil
+}
+
+func (store *memoryDataKeyStore) GetActiveDataKey(ctx context.Context, userId string) (key entity.DataKey, found bool, err error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	for _, candidate := range store.keys {
+		if candidate.UserId == userId && (!found || candidate.CreatedAt.After(key.CreatedAt)) {
+			key, found = candidate, true
+		}
+	}
+	return key, found, nil
+}
+
+func (store *memoryDataKeyStore) CreateDataKey(ctx context.Context, key entity.DataKey) (err error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	store.keys = append(store.keys, key)
+	return nil
+}
+
+func (store *memoryDataKeyStore) ListDataKeysNotWrappedBy(ctx context.Context, masterKeyId string) (keys []entity.DataKey, err error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	keys = []entity.DataKey{}
+	for _, key := range store.keys {
+		if key.MasterKeyId != masterKeyId {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+func (store *memoryDataKeyStore) RewrapDataKey(ctx context.Context, key entity.DataKey, previousMasterKeyId string) (updated bool, err error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	for i, existing := range store.keys {
+		if existing.UserId == key.UserId && existing.Id == key.Id && existing.MasterKeyId == previousMasterKeyId {
+			store.keys[i].WrappedKey = key.WrappedKey
+			store.keys[i].MasterKeyId = key.MasterKeyId
+			return true, nil
+		}
+	}
+	return false, nil
+}
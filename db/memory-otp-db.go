@@ -0,0 +1,132 @@
+package db
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"password-manager/util"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type memoryOtp struct {
+	id       string
+	email    string
+	purpose  string
+	otpHash  string
+	verified bool
+	attempts int
+	expireAt time.Time
+}
+
+type memoryOtpStore struct {
+	mu   sync.Mutex
+	otps map[string]*memoryOtp
+}
+
+func NewMemoryOtpStore() OtpStore {
+	return &memoryOtpStore{
+		otps: map[string]*memoryOtp{},
+	}
+}
+
+func (store *memoryOtpStore) GenerateOtp(ctx context.Context, email string, purpose string, otpHash string, lifetime time.Duration) (id string, expiresAt string, err error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	expireTime := time.Now().UTC().Add(lifetime).Truncate(time.Second)
+	record, ok := store.otps[purpose+":"+email]
+	if !ok {
+		record = &memoryOtp{
+			id:      primitive.NewObjectID().Hex(),
+			email:   email,
+			purpose: purpose,
+		}
+		store.otps[purpose+":"+email] = record
+	}
+	record.otpHash = otpHash
+	record.verified = false
+	record.attempts = 0
+	record.expireAt = expireTime
+
+	return record.id, expireTime.Format(time.RFC3339), nil
+}
+
+func (store *memoryOtpStore) VerifyOtp(ctx context.Context, dbId string, email string, otpHash string) (err error) {
+	if _, err = primitive.ObjectIDFromHex(dbId); err != nil {
+		return &util.CustomError{Message: "Invalid Id", Status: http.StatusBadRequest}
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	record := store.find(dbId, email)
+	if record == nil {
+		return &util.CustomError{Message: "Invalid OTP", Status: http.StatusBadRequest}
+	}
+	if record.attempts >= maxOtpAttempts {
+		return errOtpInvalidated
+	}
+	record.attempts++
+
+	if subtle.ConstantTimeCompare([]byte(record.otpHash), []byte(otpHash)) != 1 {
+		if record.attempts >= maxOtpAttempts {
+			return errOtpInvalidated
+		}
+		return &util.CustomError{Message: "Invalid OTP", Status: http.StatusBadRequest}
+	}
+
+	return nil
+}
+
+func (store *memoryOtpStore) OtpVerified(ctx context.Context, dbId string, email string, lifetime time.Duration) (expiresAt string, err error) {
+	if _, err = primitive.ObjectIDFromHex(dbId); err != nil {
+		return "", &util.CustomError{Message: "Invalid Id", Status: http.StatusBadRequest}
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	expireTime := time.Now().UTC().Add(lifetime).Truncate(time.Second)
+	if record := store.find(dbId, email); record != nil {
+		record.verified = true
+		record.expireAt = expireTime
+	}
+
+	return expireTime.Format(time.RFC3339), nil
+}
+
+func (store *memoryOtpStore) UseVerifiedOtp(ctx context.Context, dbId string, email string, purpose string) (used bool, err error) {
+	if _, err = primitive.ObjectIDFromHex(dbId); err != nil {
+		return false, &util.CustomError{Message: "Invalid Id", Status: http.StatusBadRequest}
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	record := store.find(dbId, email)
+	if record == nil || record.purpose != purpose || !record.verified {
+		return false, nil
+	}
+	delete(store.otps, purpose+":"+email)
+
+	return true, nil
+}
+
+// find returns the unexpired OTP with dbId sent to email. Callers must hold
+// the lock.
+func (store *memoryOtpStore) find(dbId string, email string) *memoryOtp {
+	now := time.Now()
+	for key, record := range store.otps {
+		if !record.expireAt.After(now) {
+			delete(store.otps, key)
+			continue
+		}
+		if record.id == dbId && record.email == email {
+			return record
+		}
+	}
+	return nil
+}
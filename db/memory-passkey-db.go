@@ -0,0 +1,121 @@
+package db
+
+import (
+	"context"
+	"password-manager/entity"
+	"sort"
+	"sync"
+	"time"
+)
+
+type memoryPasskeyStore struct {
+	mu         sync.RWMutex
+	passkeys   map[string]*entity.Passkey
+	ceremonies map[string]entity.WebAuthnCeremony
+}
+
+func NewMemoryPasskeyStore() PasskeyStore {
+	return &memoryPasskeyStore{
+		passkeys:   map[string]*entity.Passkey{},
+		ceremonies: map[string]entity.WebAuthnCeremony{},
+	}
+}
+
+func (store *memoryPasskeyStore) CreatePasskey(ctx context.Context, passkey entity.Passkey) (err error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	store.passkeys[passkey.Id] = &passkey
+	return nil
+}
+
+func (store *memoryPasskeyStore) GetPasskeys(ctx context.Context, userId string) (passkeys []entity.Passkey, err error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	passkeys = []entity.Passkey{}
+	for _, passkey := range store.passkeys {
+		if passkey.UserId == userId {
+			passkeys = append(passkeys, *passkey)
+		}
+	}
+	sort.Slice(passkeys, func(i, j int) bool {
+		return passkeys[i].CreatedAt.Before(passkeys[j].CreatedAt)
+	})
+
+	return passkeys, nil
+}
+
+func (store *memoryPasskeyStore) FindPasskey(ctx context.Context, credentialId string) (passkey entity.Passkey, found bool, err error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	existing, found := store.passkeys[credentialId]
+	if !found {
+		return entity.Passkey{}, false, nil
+	}
+	return *existing, true, nil
+}
+
+func (store *memoryPasskeyStore) RenamePasskey(ctx context.Context, userId string, credentialId string, name string) (found bool, err error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	passkey, ok := store.passkeys[credentialId]
+	if !ok || passkey.UserId != userId {
+		return false, nil
+	}
+	passkey.Name = name
+
+	return true, nil
+}
+
+func (store *memoryPasskeyStore) DeletePasskey(ctx context.Context, userId string, credentialId string) (found bool, err error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	passkey, ok := store.passkeys[credentialId]
+	if !ok || passkey.UserId != userId {
+		return false, nil
+	}
+	delete(store.passkeys, credentialId)
+
+	return true, nil
+}
+
+func (store *memoryPasskeyStore) UpdatePasskeyUsage(ctx context.Context, credentialId string, signCount uint32, backupState bool, usedAt time.Time) (err error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if passkey, ok := store.passkeys[credentialId]; ok {
+		passkey.SignCount = signCount
+		passkey.BackupState = backupState
+		passkey.LastUsedAt = &usedAt
+	}
+	return nil
+}
+
+func (store *memoryPasskeyStore) SaveCeremony(ctx context.Context, ceremony entity.WebAuthnCeremony) (err error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	now := time.Now()
+	for id, existing := range store.ceremonies {
+		if existing.ExpiresAt.Before(now) {
+			delete(store.ceremonies, id)
+		}
+	}
+	store.ceremonies[ceremony.Id] = ceremony
+
+	return nil
+}
+
+func (store *memoryPasskeyStore) TakeCeremony(ctx context.Context, ceremonyId string) (ceremony entity.WebAuthnCeremony, found bool, err error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	ceremony, found = store.ceremonies[ceremonyId]
+	delete(store.ceremonies, ceremonyId)
+
+	return ceremony, found, nil
+}
@@ -0,0 +1,56 @@
+package db
+
+import (
+	"context"
+	"math"
+	"password-manager/entity"
+	"sync"
+	"time"
+)
+
+type memoryRateLimitStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*entity.RateBucket
+	lastSweep time.Time
+}
+
+// sweepInterval bounds how often expired buckets are dropped, since
+// TakeToken runs on every limited request.
+const sweepInterval = time.Minute
+
+func NewMemoryRateLimitStore() RateLimitStore {
+	return &memoryRateLimitStore{
+		buckets: map[string]*entity.RateBucket{},
+	}
+}
+
+func (store *memoryRateLimitStore) TakeToken(ctx context.Context, key string, capacity int, refill time.Duration, now time.Time) (bucket entity.RateBucket, err error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if now.Sub(store.lastSweep) >= sweepInterval {
+		for id, existing := range store.buckets {
+			if !existing.ExpiresAt.After(now) {
+				delete(store.buckets, id)
+			}
+		}
+		store.lastSweep = now
+	}
+
+	existing, ok := store.buckets[key]
+	if !ok || !existing.ExpiresAt.After(now) {
+		existing = &entity.RateBucket{Key: key, Tokens: float64(capacity), UpdatedAt: now}
+		store.buckets[key] = existing
+	}
+	if elapsed := now.Sub(existing.UpdatedAt); elapsed > 0 {
+		existing.Tokens = math.Min(float64(capacity), existing.Tokens+float64(elapsed)/float64(refill))
+		existing.UpdatedAt = now
+	}
+	existing.Allowed = existing.Tokens >= 1
+	if existing.Allowed {
+		existing.Tokens--
+	}
+	existing.ExpiresAt = now.Add(refill * time.Duration(capacity))
+
+	return *existing, nil
+}
@@ -0,0 +1,64 @@
+package db
+
+import (
+	"context"
+	"password-manager/entity"
+	"sync"
+	"time"
+)
+
+type memoryRefreshTokenStore struct {
+	mu     sync.Mutex
+	tokens map[string]*entity.RefreshToken
+}
+
+func NewMemoryRefreshTokenStore() RefreshTokenStore {
+	return &memoryRefreshTokenStore{
+		tokens: map[string]*entity.RefreshToken{},
+	}
+}
+
+func (store *memoryRefreshTokenStore) CreateRefreshToken(ctx context.Context, token entity.RefreshToken) (err error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	now := time.Now()
+	for hash, existing := range store.tokens {
+		if existing.ExpiresAt.Before(now) {
+			delete(store.tokens, hash)
+		}
+	}
+	store.tokens[token.TokenHash] = &token
+
+	return nil
+}
+
+func (store *memoryRefreshTokenStore) UseRefreshToken(ctx context.Context, tokenHash string, usedAt time.Time) (token entity.RefreshToken, found bool, err error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	existing, ok := store.tokens[tokenHash]
+	if !ok {
+		return entity.RefreshToken{}, false, nil
+	}
+
+	token = *existing
+	if existing.UsedAt == nil {
+		existing.UsedAt = &usedAt
+	}
+
+	return token, true, nil
+}
+
+func (store *memoryRefreshTokenStore) RevokeRefreshTokenFamily(ctx context.Context, familyId string) (err error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	for _, token := range store.tokens {
+		if token.FamilyId == familyId {
+			token.Revoked = true
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,112 @@
+package db
+
+import (
+	"context"
+	"password-manager/entity"
+	"sort"
+	"sync"
+	"time"
+)
+
+type memorySessionStore struct {
+	mu       sync.RWMutex
+	sessions map[string]*entity.Session
+}
+
+func NewMemorySessionStore() SessionStore {
+	return &memorySessionStore{
+		sessions: map[string]*entity.Session{},
+	}
+}
+
+func (store *memorySessionStore) CreateSession(ctx context.Context, session entity.Session) (err error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	now := time.Now()
+	for id, existing := range store.sessions {
+		if existing.ExpiresAt.Before(now) {
+			delete(store.sessions, id)
+		}
+	}
+	store.sessions[session.Id] = &session
+
+	return nil
+}
+
+func (store *memorySessionStore) GetSession(ctx context.Context, sessionId string) (session entity.Session, found bool, err error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	existing, found := store.sessions[sessionId]
+	if !found {
+		return entity.Session{}, false, nil
+	}
+	return *existing, true, nil
+}
+
+func (store *memorySessionStore) ListSessions(ctx context.Context, userId string, now time.Time) (sessions []entity.Session, err error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	sessions = []entity.Session{}
+	for _, session := range store.sessions {
+		if session.UserId == userId && !session.Revoked && session.ExpiresAt.After(now) {
+			sessions = append(sessions, *session)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastSeenAt.After(sessions[j].LastSeenAt)
+	})
+
+	return sessions, nil
+}
+
+func (store *memorySessionStore) TouchSession(ctx context.Context, sessionId string, lastSeenAt time.Time, ip string) (err error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if session, ok := store.sessions[sessionId]; ok {
+		session.LastSeenAt = lastSeenAt
+		session.IP = ip
+	}
+	return nil
+}
+
+func (store *memorySessionStore) ExtendSession(ctx context.Context, sessionId string, expiresAt time.Time) (err error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if session, ok := store.sessions[sessionId]; ok {
+		session.ExpiresAt = expiresAt
+	}
+	return nil
+}
+
+func (store *memorySessionStore) RevokeSession(ctx context.Context, userId string, sessionId string) (revoked bool, err error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	session, ok := store.sessions[sessionId]
+	if !ok || session.UserId != userId || session.Revoked {
+		return false, nil
+	}
+	session.Revoked = true
+
+	return true, nil
+}
+
+func (store *memorySessionStore) RevokeOtherSessions(ctx context.Context, userId string, keepSessionId string) (sessionIds []string, err error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	sessionIds = []string{}
+	for _, session := range store.sessions {
+		if session.UserId == userId && session.Id != keepSessionId && !session.Revoked {
+			session.Revoked = true
+			sessionIds = append(sessionIds, session.Id)
+		}
+	}
+
+	return sessionIds, nil
+}
@@ -0,0 +1,177 @@
+package db
+
+import (
+	"context"
+	"net/http"
+	"password-manager/entity"
+	"password-manager/util"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type memorySite struct {
+	userId    string
+	oldUserId string
+	site      entity.Site
+}
+
+type memorySiteStore struct {
+	mu    sync.RWMutex
+	order []string
+	sites map[string]*memorySite
+}
+
+func NewMemorySiteStore() SiteStore {
+	return &memorySiteStore{
+		sites: map[string]*memorySite{},
+	}
+}
+
+func (store *memorySiteStore) SaveSite(ctx context.Context, userId string, site entity.Site) (id string, err error) {
+	if _, err = primitive.ObjectIDFromHex(userId); err != nil {
+		return "", &util.CustomError{Message: "Invalid User Id", Status: http.StatusBadRequest}
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	site.Id = primitive.NewObjectID().Hex()
+	store.sites[site.Id] = &memorySite{userId: userId, site: site}
+	store.order = append(store.order, site.Id)
+
+	return site.Id, nil
+}
+
+func (store *memorySiteStore) GetSites(ctx context.Context, userId string) (sites []entity.Site, err error) {
+	if _, err = primitive.ObjectIDFromHex(userId); err != nil {
+		return []entity.Site{}, &util.CustomError{Message: "Invalid User Id", Status: http.StatusBadRequest}
+	}
+
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	sites = []entity.Site{}
+	for _, id := range store.order {
+		if record := store.sites[id]; record.userId == userId {
+			sites = append(sites, record.site)
+		}
+	}
+
+	return sites, nil
+}
+
+func (store *memorySiteStore) GetSite(ctx context.Context, userId string, siteId string) (site entity.Site, err error) {
+	if _, err = primitive.ObjectIDFromHex(userId); err != nil {
+		return entity.Site{}, &util.CustomError{Message: "Invalid User Id", Status: http.StatusBadRequest}
+	}
+	if _, err = primitive.ObjectIDFromHex(siteId); err != nil {
+		return entity.Site{}, &util.CustomError{Message: "Invalid Site Id", Status: http.StatusBadRequest}
+	}
+
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	record, ok := store.sites[siteId]
+	if !ok || record.userId != userId {
+		return entity.Site{}, &util.CustomError{Message: "Site not found", Status: http.StatusBadRequest}
+	}
+
+	return record.site, nil
+}
+
+func (store *memorySiteStore) EditSite(ctx context.Context, siteId string, site entity.Site) (updatedSite entity.Site, err error) {
+	if _, err = primitive.ObjectIDFromHex(siteId); err != nil {
+		return entity.Site{}, &util.CustomError{Message: "Invalid Site Id", Status: http.StatusBadRequest}
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	record, ok := store.sites[siteId]
+	if !ok {
+		return entity.Site{}, &util.CustomError{Message: "Site not found", Status: http.StatusBadRequest}
+	}
+	site.Id = siteId
+	record.site = site
+
+	return record.site, nil
+}
+
+func (store *memorySiteStore) DeleteSite(ctx context.Context, userId string, siteId string) (err error) {
+	if _, err = primitive.ObjectIDFromHex(userId); err != nil {
+		return &util.CustomError{Message: "Invalid User Id", Status: http.StatusBadRequest}
+	}
+	if _, err = primitive.ObjectIDFromHex(siteId); err != nil {
+		return &util.CustomError{Message: "Invalid Site Id", Status: http.StatusBadRequest}
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if record, ok := store.sites[siteId]; ok && record.userId == userId {
+		record.userId = ""
+		record.oldUserId = userId
+	}
+
+	return nil
+}
+
+func (store *memorySiteStore) ListSiteOwners(ctx context.Context) (userIds []string, err error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	seen := map[string]bool{}
+	userIds = []string{}
+	for _, id := range store.order {
+		record := store.sites[id]
+		for _, owner := range []string{record.userId, record.oldUserId} {
+			if owner != "" && !seen[owner] {
+				seen[owner] = true
+				userIds = append(userIds, owner)
+			}
+		}
+	}
+
+	return userIds, nil
+}
+
+func (store *memorySiteStore) GetAllSites(ctx context.Context, userId string) (sites []entity.Site, err error) {
+	if _, err = primitive.ObjectIDFromHex(userId); err != nil {
+		return []entity.Site{}, &util.CustomError{Message: "Invalid User Id", Status: http.StatusBadRequest}
+	}
+
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	sites = []entity.Site{}
+	for _, id := range store.order {
+		if record := store.sites[id]; record.userId == userId || record.oldUserId == userId {
+			sites = append(sites, record.site)
+		}
+	}
+
+	return sites, nil
+}
+
+func (store *memorySiteStore) ReEncryptSite(ctx context.Context, siteId string, previous entity.Site, site entity.Site) (updated bool, err error) {
+	if _, err = primitive.ObjectIDFromHex(siteId); err != nil {
+		return false, &util.CustomError{Message: "Invalid Site Id", Status: http.StatusBadRequest}
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	record, ok := store.sites[siteId]
+	if !ok || record.site.KeyId != previous.KeyId || record.site.Username != previous.Username ||
+		record.site.Password != previous.Password || record.site.Notes != previous.Notes {
+		return false, nil
+	}
+
+	record.site.Username = site.Username
+	record.site.Password = site.Password
+	record.site.Notes = site.Notes
+	record.site.KeyId = site.KeyId
+
+	return true, nil
+}
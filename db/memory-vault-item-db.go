@@ -0,0 +1,80 @@
+package db
+
+import (
+	"context"
+	"net/http"
+	"password-manager/entity"
+	"password-manager/util"
+	"sort"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type memoryVaultItemStore struct {
+	mu    sync.RWMutex
+	items map[string]map[string]entity.VaultItem
+}
+
+func NewMemoryVaultItemStore() VaultItemStore {
+	return &memoryVaultItemStore{
+		items: map[string]map[string]entity.VaultItem{},
+	}
+}
+
+func (store *memoryVaultItemStore) GetItems(ctx context.Context, userId string, updatedAfter time.Time) (items []entity.VaultItem, err error) {
+	if _, err = primitive.ObjectIDFromHex(userId); err != nil {
+		return []entity.VaultItem{}, &util.CustomError{Message: "Invalid User Id", Status: http.StatusBadRequest}
+	}
+
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	items = []entity.VaultItem{}
+	for _, item := range store.items[userId] {
+		if item.UpdatedAt.After(updatedAfter) {
+			items = append(items, item)
+		}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].UpdatedAt.Before(items[j].UpdatedAt)
+	})
+
+	return items, nil
+}
+
+func (store *memoryVaultItemStore) GetItem(ctx context.Context, userId string, itemId string) (item entity.VaultItem, found bool, err error) {
+	if _, err = primitive.ObjectIDFromHex(userId); err != nil {
+		return entity.VaultItem{}, false, &util.CustomError{Message: "Invalid User Id", Status: http.StatusBadRequest}
+	}
+
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	item, found = store.items[userId][itemId]
+	return item, found, nil
+}
+
+func (store *memoryVaultItemStore) PutItem(ctx context.Context, userId string, item entity.VaultItem, expectedRevision int64) (written bool, err error) {
+	if _, err = primitive.ObjectIDFromHex(userId); err != nil {
+		return false, &util.CustomError{Message: "Invalid User Id", Status: http.StatusBadRequest}
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	userItems, ok := store.items[userId]
+	if !ok {
+		userItems = map[string]entity.VaultItem{}
+		store.items[userId] = userItems
+	}
+
+	existing, found := userItems[item.Id]
+	if (expectedRevision == 0 && found) || (expectedRevision != 0 && (!found || existing.Revision != expectedRevision)) {
+		return false, nil
+	}
+	userItems[item.Id] = item
+
+	return true, nil
+}
@@ -0,0 +1,57 @@
+package db
+
+import (
+	"context"
+	"net/http"
+	"password-manager/metrics"
+	"password-manager/util"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("password-manager/db")
+
+// statusClientClosedRequest marks operations abandoned because the client
+// went away; nobody reads the response, but logs and metrics do.
+const statusClientClosedRequest = 499
+
+// instrument starts a span for a Mongo store operation, bounded by the
+// configured operation timeout. The returned func ends it and records the
+// operation's latency, counting it as an error unless it succeeded or was
+// rejected as a client error. Failures caused by the context ending are
+// reported as a timeout or a cancelled request rather than a server error.
+// Used at the top of each operation:
+//
+//	ctx, done := instrument(ctx, "sites.SaveSite")
+//	defer done(&err)
+func instrument(ctx context.Context, operation string) (context.Context, func(*error)) {
+	start := time.Now()
+	cancel := context.CancelFunc(func() {})
+	if timeout := time.Duration(operationTimeout.Load()); timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	}
+	ctx, span := tracer.Start(ctx, operation, trace.WithSpanKind(trace.SpanKindClient))
+	return ctx, func(err *error) {
+		defer cancel()
+		defer span.End()
+		metrics.DbOperationDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+		if *err == nil {
+			return
+		}
+		switch ctx.Err() {
+		case context.DeadlineExceeded:
+			*err = &util.CustomError{Message: "Request timed out", Status: http.StatusGatewayTimeout}
+		case context.Canceled:
+			*err = &util.CustomError{Message: "Request cancelled", Status: statusClientClosedRequest}
+		}
+		if customErr, ok := (*err).(*util.CustomError); ok && customErr.Status < http.StatusInternalServerError {
+			return
+		}
+		metrics.DbOperationErrors.WithLabelValues(operation).Inc()
+		span.RecordError(*err)
+		span.SetStatus(codes.Error, (*err).Error())
+	}
+}
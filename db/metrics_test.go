@@ -0,0 +1,113 @@
+package db_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"password-manager/db"
+	"password-manager/entity"
+	"password-manager/logger"
+	"password-manager/router"
+	"password-manager/service"
+	"password-manager/signing"
+	"password-manager/util"
+	"strings"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestMain(m *testing.M) {
+	logger.Init()
+	os.Exit(m.Run())
+}
+
+// instrumentedUsers runs FindUserByEmail through instrument, as the Mongo
+// store does.
+type instrumentedUsers struct {
+	db.UserStore
+}
+
+func (store instrumentedUsers) FindUserByEmail(ctx context.Context, email string) (user entity.User, found bool, err error) {
+	ctx, done := db.Instrument(ctx, "users.FindUserByEmail")
+	defer done(&err)
+
+	return store.UserStore.FindUserByEmail(ctx, email)
+}
+
+func TestSignInSpansShareOneTrace(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { provider.Shutdown(context.Background()) })
+
+	defaultParams := util.PasswordHashParams
+	util.PasswordHashParams = util.Argon2Params{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+	t.Cleanup(func() { util.PasswordHashParams = defaultParams })
+
+	stores := db.NewMemoryStores()
+	stores.Users = instrumentedUsers{stores.Users}
+	passwordHash, err := util.HashPassword("correct horse battery staple")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = stores.Users.RegisterUser(context.Background(), "testuser@example.com", passwordHash); err != nil {
+		t.Fatal(err)
+	}
+	keys, err := signing.NewEphemeralKeySet()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	engine := router.New(router.Config{
+		ServiceName:    "password-manager",
+		AllowOrigins:   []string{"http://localhost:3000"},
+		Stores:         stores,
+		Keys:           keys,
+		Auth:           service.AuthSettings{Lifetimes: service.TokenLifetimes{AccessToken: time.Minute, RefreshToken: time.Hour}},
+		RequestTimeout: time.Second * 5,
+	})
+
+	body := `{"email":"testuser@example.com","password":"correct horse battery staple"}`
+	request := httptest.NewRequest(http.MethodPost, "/v1/auth/sign-in", strings.NewReader(body))
+	request.Header.Set("Content-Type", "application/json")
+	response := httptest.NewRecorder()
+	engine.ServeHTTP(response, request)
+	if response.Code != http.StatusOK {
+		t.Fatalf("sign in: status %d, body %s", response.Code, response.Body)
+	}
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+	server, serviceSpan, store := spans["/v1/auth/sign-in"], spans["AuthService.SignIn"], spans["users.FindUserByEmail"]
+	if server == nil || serviceSpan == nil || store == nil {
+		t.Fatalf("missing spans, recorded %v", names(recorder.Ended()))
+	}
+
+	traceId := server.SpanContext().TraceID()
+	for _, span := range []sdktrace.ReadOnlySpan{serviceSpan, store} {
+		if span.SpanContext().TraceID() != traceId {
+			t.Errorf("%s is in trace %s, want %s", span.Name(), span.SpanContext().TraceID(), traceId)
+		}
+	}
+	if serviceSpan.Parent().SpanID() != server.SpanContext().SpanID() {
+		t.Errorf("AuthService.SignIn is not a child of the request span")
+	}
+	if store.Parent().SpanID() != serviceSpan.SpanContext().SpanID() {
+		t.Errorf("users.FindUserByEmail is not a child of AuthService.SignIn")
+	}
+}
+
+func names(spans []sdktrace.ReadOnlySpan) []string {
+	names := make([]string, 0, len(spans))
+	for _, span := range spans {
+		names = append(names, span.Name())
+	}
+	return names
+}
@@ -0,0 +1,116 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"password-manager/constants"
+	"password-manager/logger"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Migration is one versioned change to the Mongo schema. Up must be safe to
+// run twice: instances starting together may both apply a migration before
+// either has recorded it.
+type Migration struct {
+	Version     int
+	Description string
+	Up          func(ctx context.Context, database *mongo.Database) error
+}
+
+type appliedMigration struct {
+	Version     int       `bson:"_id"`
+	Description string    `bson:"description"`
+	AppliedAt   time.Time `bson:"appliedAt"`
+}
+
+// Migrate applies, in version order, every migration not yet recorded in the
+// migrations collection and returns the ones it applied.
+func Migrate(ctx context.Context, database *mongo.Database) (applied []Migration, err error) {
+	migrationsCollection := database.Collection(constants.MigrationsCollection)
+
+	cursor, err := migrationsCollection.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	var records []appliedMigration
+	if err = cursor.All(ctx, &records); err != nil {
+		return nil, err
+	}
+	done := map[int]bool{}
+	for _, record := range records {
+		done[record.Version] = true
+	}
+
+	for _, migration := range migrations {
+		if done[migration.Version] {
+			continue
+		}
+
+		logger.Info(ctx, "applying migration", "version", migration.Version, "description", migration.Description)
+		if err = migration.Up(ctx, database); err != nil {
+			return applied, fmt.Errorf("migration %d (%s): %w", migration.Version, migration.Description, err)
+		}
+
+		record := bson.M{"$setOnInsert": bson.M{"description": migration.Description, "appliedAt": time.Now().UTC()}}
+		if _, err = migrationsCollection.UpdateOne(ctx, bson.M{"_id": migration.Version}, record, options.Update().SetUpsert(true)); err != nil {
+			return applied, err
+		}
+		applied = append(applied, migration)
+	}
+
+	return applied, nil
+}
+
+// RunMigrations migrates the database the backend would use. The in-memory
+// backend has no schema, so it is a no-op there.
+func RunMigrations(ctx context.Context, backend string, config MongoConfig) (applied []Migration, err error) {
+	if backend == "memory" {
+		return nil, nil
+	}
+
+	client, err := Connect(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return Migrate(ctx, client.Database(config.Database))
+}
+
+// ErrMissingEmailIndex is returned by CheckSchema when users.email has no
+// unique index. RegisterUser relies on it to turn away a second account for
+// an email when two sign-ups race.
+var ErrMissingEmailIndex = errors.New("users.email has no unique index; run cmd/migrate or enable AUTO_MIGRATE")
+
+// CheckSchema reports indexes the stores need for correctness that are
+// missing, for deployments that migrate separately from starting the server.
+// The in-memory backend has no schema, so it always passes.
+func CheckSchema(ctx context.Context, backend string, config MongoConfig) error {
+	if backend == "memory" {
+		return nil
+	}
+
+	client, err := Connect(config)
+	if err != nil {
+		return err
+	}
+
+	specs, err := client.Database(config.Database).Collection(constants.UsersCollection).Indexes().ListSpecifications(ctx)
+	if err != nil {
+		return err
+	}
+	for _, spec := range specs {
+		keys, err := spec.KeysDocument.Elements()
+		if err != nil {
+			return err
+		}
+		if len(keys) == 1 && keys[0].Key() == "email" && spec.Unique != nil && *spec.Unique {
+			return nil
+		}
+	}
+	return ErrMissingEmailIndex
+}
@@ -0,0 +1,196 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"password-manager/constants"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// migrations must stay in version order. Never edit or renumber one that has
+// shipped; add a new one instead.
+var migrations = []Migration{
+	{
+		Version:     1,
+		Description: "Convert string expireAt timestamps to dates",
+		Up:          convertExpiryStrings,
+	},
+	{
+		Version:     2,
+		Description: "Remove OTPs stored in cleartext",
+		Up:          removeCleartextOtps,
+	},
+	{
+		Version:     3,
+		Description: "Create TTL indexes on expireAt",
+		Up:          createTtlIndexes,
+	},
+	{
+		Version:     4,
+		Description: "Create unique and lookup indexes",
+		Up:          createLookupIndexes,
+	},
+	{
+		Version:     5,
+		Description: "Number existing KDF parameters as revision 1",
+		Up:          numberKdfRevisions,
+	},
+}
+
+// TTL indexes ignore strings, so older otp and blacklist documents were never
+// cleaned up. Unparseable values become the current time and expire at once.
+func convertExpiryStrings(ctx context.Context, database *mongo.Database) error {
+	now := time.Now().UTC()
+	update := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"expireAt": bson.M{"$dateFromString": bson.M{"dateString": "$expireAt", "onError": now}},
+		}}},
+	}
+
+	for _, name := range []string{constants.OtpCollection, constants.BlacklistCollection} {
+		filter := bson.M{"expireAt": bson.M{"$type": "string"}}
+		if _, err := database.Collection(name).UpdateMany(ctx, filter, update); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// OTPs from before codes were HMACed can no longer be verified.
+func removeCleartextOtps(ctx context.Context, database *mongo.Database) error {
+	_, err := database.Collection(constants.OtpCollection).DeleteMany(ctx, bson.M{"otpHash": bson.M{"$exists": false}})
+	return err
+}
+
+func createTtlIndexes(ctx context.Context, database *mongo.Database) error {
+	collections := []string{
+		constants.OtpCollection,
+		constants.BlacklistCollection,
+		constants.RefreshTokensCollection,
+		constants.SessionsCollection,
+		constants.CeremoniesCollection,
+		constants.AttemptsCollection,
+		constants.RateLimitsCollection,
+	}
+
+	index := mongo.IndexModel{
+		Keys:    bson.D{{Key: "expireAt", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	}
+	for _, name := range collections {
+		if _, err := database.Collection(name).Indexes().CreateOne(ctx, index); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createLookupIndexes checks for duplicate emails first: the unique email
+// index cannot be built over them, and only an operator can decide which
+// account to keep.
+func createLookupIndexes(ctx context.Context, database *mongo.Database) error {
+	if err := checkDuplicateEmails(ctx, database); err != nil {
+		return err
+	}
+
+	unique := options.Index().SetUnique(true)
+	indexes := map[string][]mongo.IndexModel{
+		constants.UsersCollection: {
+			{Keys: bson.D{{Key: "email", Value: 1}}, Options: unique},
+		},
+		constants.SitesCollection: {
+			{Keys: bson.D{{Key: "userId", Value: 1}}},
+		},
+		constants.OtpCollection: {
+			{Keys: bson.D{{Key: "email", Value: 1}, {Key: "purpose", Value: 1}}, Options: unique},
+		},
+		constants.BlacklistCollection: {
+			{Keys: bson.D{{Key: "token", Value: 1}}},
+		},
+		constants.DataKeysCollection: {
+			{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "keyId", Value: 1}}, Options: unique},
+		},
+		constants.VaultItemsCollection: {
+			{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "itemId", Value: 1}}, Options: unique},
+			{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "updatedAt", Value: 1}}},
+		},
+		constants.RefreshTokensCollection: {
+			{Keys: bson.D{{Key: "tokenHash", Value: 1}}, Options: unique},
+			{Keys: bson.D{{Key: "familyId", Value: 1}}},
+		},
+		constants.SessionsCollection: {
+			{Keys: bson.D{{Key: "sessionId", Value: 1}}, Options: unique},
+			{Keys: bson.D{{Key: "userId", Value: 1}}},
+		},
+		constants.PasskeysCollection: {
+			{Keys: bson.D{{Key: "credentialId", Value: 1}}, Options: unique},
+			{Keys: bson.D{{Key: "userId", Value: 1}}},
+		},
+		constants.CeremoniesCollection: {
+			{Keys: bson.D{{Key: "ceremonyId", Value: 1}}, Options: unique},
+		},
+		constants.AttemptsCollection: {
+			{Keys: bson.D{{Key: "key", Value: 1}}, Options: unique},
+		},
+		constants.RateLimitsCollection: {
+			{Keys: bson.D{{Key: "key", Value: 1}}, Options: unique},
+		},
+	}
+
+	for name, models := range indexes {
+		if _, err := database.Collection(name).Indexes().CreateMany(ctx, models); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// maxReportedDuplicates keeps the error readable on a badly affected database.
+const maxReportedDuplicates = 20
+
+// checkDuplicateEmails fails, listing the emails, when more than one user
+// shares an email.
+func checkDuplicateEmails(ctx context.Context, database *mongo.Database) error {
+	pipeline := mongo.Pipeline{
+		{{Key: "$group", Value: bson.M{"_id": "$email", "count": bson.M{"$sum": 1}}}},
+		{{Key: "$match", Value: bson.M{"count": bson.M{"$gt": 1}}}},
+		{{Key: "$sort", Value: bson.M{"_id": 1}}},
+	}
+	cursor, err := database.Collection(constants.UsersCollection).Aggregate(ctx, pipeline)
+	if err != nil {
+		return err
+	}
+	var duplicates []struct {
+		Email string `bson:"_id"`
+		Count int    `bson:"count"`
+	}
+	if err = cursor.All(ctx, &duplicates); err != nil {
+		return err
+	}
+	if len(duplicates) == 0 {
+		return nil
+	}
+
+	listed := []string{}
+	for i, duplicate := range duplicates {
+		if i == maxReportedDuplicates {
+			listed = append(listed, fmt.Sprintf("and %d more", len(duplicates)-i))
+			break
+		}
+		listed = append(listed, fmt.Sprintf("%s (%d accounts)", duplicate.Email, duplicate.Count))
+	}
+	return fmt.Errorf("%d emails belong to more than one user, so users.email cannot be made unique; merge or remove the extra accounts and migrate again: %s", len(duplicates), strings.Join(listed, ", "))
+}
+
+// KDF parameters saved before revisions existed become revision 1, so their
+// owners can replace them with an expected revision like any other client.
+func numberKdfRevisions(ctx context.Context, database *mongo.Database) error {
+	filter := bson.M{"kdf": bson.M{"$exists": true}, "kdf.revision": bson.M{"$exists": false}}
+	_, err := database.Collection(constants.UsersCollection).UpdateMany(ctx, filter, bson.M{"$set": bson.M{"kdf.revision": 1}})
+	return err
+}
@@ -2,238 +2,160 @@ package db
 
 import (
 	"context"
+	"crypto/subtle"
 	"net/http"
 	"password-manager/constants"
-	"password-manager/logger"
 	"password-manager/util"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-func GenerateOtp(email string, otp string) (id string, expiresAt string, err error) {
-	client, err := DbSetup()
-	if err != nil {
-		logger.ErrorLogger.Println(err.Error())
-		return "", "", &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError}
-	}
-	defer client.Disconnect(context.Background())
-
-	otpCollection := client.Database(constants.DatabaseName).Collection(constants.OtpCollection)
-
-	// index := mongo.IndexModel{
-	// 	Keys:    bson.M{"expireAt": 1},
-	// 	Options: options.Index().SetExpireAfterSeconds(0),
-	// }
-
-	// _, err = otpCollection.Indexes().CreateOne(context.Background(), index)
-	// if err != nil {
-	// 	logger.ErrorLogger.Println(err.Error())
-	// 	return "", time.Time{}, &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError}
-	// }
-
-	expireTime := time.Now().UTC().Add(time.Minute * 5).Format(time.RFC3339)
-	document := bson.M{
-		"email":    email,
-		"otp":      otp,
-		"verified": false,
-		"expireAt": expireTime,
-	}
+// maxOtpAttempts is the number of guesses allowed against one OTP. After that
+// it is invalidated and a new one has to be requested.
+const maxOtpAttempts = 5
 
-	result, err := otpCollection.InsertOne(context.Background(), document)
-	if err != nil {
-		logger.ErrorLogger.Println(err.Error())
-		return "", "", &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError}
-	}
+var errOtpInvalidated = &util.CustomError{Message: "Too many wrong attempts, request a new OTP", Status: http.StatusTooManyRequests}
 
-	return result.InsertedID.(primitive.ObjectID).Hex(), expireTime, nil
+type mongoOtpStore struct {
+	database *mongo.Database
 }
 
-func ReGenerateOtp(email string, otp string) (expiresAt string, err error) {
-	client, err := DbSetup()
-	if err != nil {
-		logger.ErrorLogger.Println(err.Error())
-		return "", &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError}
+func NewMongoOtpStore(database *mongo.Database) OtpStore {
+	return &mongoOtpStore{
+		database: database,
 	}
-	defer client.Disconnect(context.Background())
+}
+
+// GenerateOtp replaces any pending OTP for the same email and purpose, keeping
+// its id so that a resent code works with the id the client already has.
+func (store *mongoOtpStore) GenerateOtp(ctx context.Context, email string, purpose string, otpHash string, lifetime time.Duration) (id string, expiresAt string, err error) {
+	ctx, done := instrument(ctx, "otp.GenerateOtp")
+	defer done(&err)
 
-	otpCollection := client.Database(constants.DatabaseName).Collection(constants.OtpCollection)
+	otpCollection := store.database.Collection(constants.OtpCollection)
 
-	expireTime := time.Now().UTC().Add(time.Minute * 5).Format(time.RFC3339)
-	filter := bson.M{"email": email}
+	expireTime := time.Now().UTC().Add(lifetime).Truncate(time.Second)
+	filter := bson.M{"email": email, "purpose": purpose}
 	update := bson.M{
 		"$set": bson.M{
-			"otp":      otp,
+			"otpHash":  otpHash,
 			"verified": false,
+			"attempts": 0,
 			"expireAt": expireTime,
 		},
 	}
+	options := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
 
-	_, err = otpCollection.UpdateOne(context.Background(), filter, update)
+	var otpDocument struct {
+		Id primitive.ObjectID `bson:"_id"`
+	}
+	err = otpCollection.FindOneAndUpdate(ctx, filter, update, options).Decode(&otpDocument)
 	if err != nil {
-		logger.ErrorLogger.Println(err.Error())
-		return "", &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError}
+		return "", "", &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
 	}
 
-	return expireTime, nil
+	return otpDocument.Id.Hex(), expireTime.Format(time.RFC3339), nil
 }
 
-func VerifyOtp(dbId string, email string, otp string) (err error) {
-	client, err := DbSetup()
-	if err != nil {
-		logger.ErrorLogger.Println(err.Error())
-		return &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError}
-	}
-	defer client.Disconnect(context.Background())
+func (store *mongoOtpStore) VerifyOtp(ctx context.Context, dbId string, email string, otpHash string) (err error) {
+	ctx, done := instrument(ctx, "otp.VerifyOtp")
+	defer done(&err)
 
-	otpCollection := client.Database(constants.DatabaseName).Collection(constants.OtpCollection)
+	otpCollection := store.database.Collection(constants.OtpCollection)
 
 	objId, err := primitive.ObjectIDFromHex(dbId)
 	if err != nil {
-		logger.ErrorLogger.Println(err.Error())
-		return &util.CustomError{Message: "Invalid Id", Status: http.StatusBadRequest}
-	}
-
-	result := otpCollection.FindOne(context.Background(), bson.M{"_id": objId, "email": email, "otp": otp})
-
-	var otpDocument bson.M
-	result.Decode(&otpDocument)
-
-	if otpDocument == nil {
+		return &util.CustomError{Message: "Invalid Id", Status: http.StatusBadRequest, Cause: err}
+	}
+
+	// Each guess uses up an attempt before it is compared, so concurrent
+	// guesses cannot exceed the limit.
+	now := time.Now().UTC()
+	live := bson.M{"_id": objId, "email": email, "expireAt": bson.M{"$gt": now}}
+	filter := bson.M{"_id": objId, "email": email, "expireAt": bson.M{"$gt": now}, "attempts": bson.M{"$not": bson.M{"$gte": maxOtpAttempts}}}
+	update := bson.M{"$inc": bson.M{"attempts": 1}}
+
+	var otpDocument struct {
+		OtpHash  string `bson:"otpHash"`
+		Attempts int    `bson:"attempts"`
+	}
+	err = otpCollection.FindOneAndUpdate(ctx, filter, update).Decode(&otpDocument)
+	if err == mongo.ErrNoDocuments {
+		count, err := otpCollection.CountDocuments(ctx, live)
+		if err != nil {
+			return &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
+		}
+		if count > 0 {
+			return errOtpInvalidated
+		}
 		return &util.CustomError{Message: "Invalid OTP", Status: http.StatusBadRequest}
-	} else {
-		return nil
 	}
-}
-
-func OtpVerified(email string) (expiresAt string, err error) {
-	client, err := DbSetup()
 	if err != nil {
-		logger.ErrorLogger.Println(err.Error())
-		return "", &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError}
-	}
-	defer client.Disconnect(context.Background())
-
-	otpCollection := client.Database(constants.DatabaseName).Collection(constants.OtpCollection)
-
-	expireTime := time.Now().UTC().Add(time.Minute * 5).Format(time.RFC3339)
-	filter := bson.M{"email": email}
-	update := bson.M{
-		"$set": bson.M{
-			"verified": true,
-			"expireAt": expireTime,
-		},
+		return &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
 	}
 
-	if _, err = otpCollection.UpdateOne(context.Background(), filter, update); err != nil {
-		logger.ErrorLogger.Println(err.Error())
-		return "", &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError}
+	if subtle.ConstantTimeCompare([]byte(otpDocument.OtpHash), []byte(otpHash)) != 1 {
+		if otpDocument.Attempts+1 >= maxOtpAttempts {
+			return errOtpInvalidated
+		}
+		return &util.CustomError{Message: "Invalid OTP", Status: http.StatusBadRequest}
 	}
 
-	return expireTime, nil
+	return nil
 }
 
-func CheckOtpGenerated(email string) (id string, err error) {
-	client, err := DbSetup()
-	if err != nil {
-		logger.ErrorLogger.Println(err.Error())
-		return "", &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError}
-	}
-	defer client.Disconnect(context.Background())
-
-	otpCollection := client.Database(constants.DatabaseName).Collection(constants.OtpCollection)
-
-	result := otpCollection.FindOne(context.Background(), bson.M{"email": email})
+func (store *mongoOtpStore) OtpVerified(ctx context.Context, dbId string, email string, lifetime time.Duration) (expiresAt string, err error) {
+	ctx, done := instrument(ctx, "otp.OtpVerified")
+	defer done(&err)
 
-	var otpDocument bson.M
-	result.Decode(&otpDocument)
-
-	if otpDocument == nil {
-		return "", nil
-	} else {
-		return otpDocument["_id"].(primitive.ObjectID).Hex(), nil
-	}
-}
+	otpCollection := store.database.Collection(constants.OtpCollection)
 
-func CheckOtp(email string, otp string) (err error) {
-	client, err := DbSetup()
+	objId, err := primitive.ObjectIDFromHex(dbId)
 	if err != nil {
-		logger.ErrorLogger.Println(err.Error())
-		return &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError}
+		return "", &util.CustomError{Message: "Invalid Id", Status: http.StatusBadRequest, Cause: err}
 	}
-	defer client.Disconnect(context.Background())
 
-	otpCollection := client.Database(constants.DatabaseName).Collection(constants.OtpCollection)
-
-	expireTime := time.Now().UTC().Add(time.Minute * 5).Format(time.RFC3339)
-	document := bson.M{
-		"email":    email,
-		"otp":      otp,
-		"expireAt": expireTime,
+	expireTime := time.Now().UTC().Add(lifetime).Truncate(time.Second)
+	filter := bson.M{"_id": objId, "email": email}
+	update := bson.M{
+		"$set": bson.M{
+			"verified": true,
+			"expireAt": expireTime,
+		},
 	}
 
-	_, err = otpCollection.InsertOne(context.Background(), document)
-	if err != nil {
-		logger.ErrorLogger.Println(err.Error())
-		return &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError}
+	if _, err = otpCollection.UpdateOne(ctx, filter, update); err != nil {
+		return "", &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
 	}
 
-	return nil
+	return expireTime.Format(time.RFC3339), nil
 }
 
-func CheckUserVerified(id string, email string) (status bool, err error) {
-	client, err := DbSetup()
-	if err != nil {
-		logger.ErrorLogger.Println(err.Error())
-		return false, &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError}
-	}
-	defer client.Disconnect(context.Background())
+// UseVerifiedOtp deletes a verified, unexpired OTP for purpose and reports
+// whether there was one, so each verification allows exactly one action.
+func (store *mongoOtpStore) UseVerifiedOtp(ctx context.Context, dbId string, email string, purpose string) (used bool, err error) {
+	ctx, done := instrument(ctx, "otp.UseVerifiedOtp")
+	defer done(&err)
 
-	otpCollection := client.Database(constants.DatabaseName).Collection(constants.OtpCollection)
+	otpCollection := store.database.Collection(constants.OtpCollection)
 
-	objId, err := primitive.ObjectIDFromHex(id)
+	objId, err := primitive.ObjectIDFromHex(dbId)
 	if err != nil {
-		logger.ErrorLogger.Println(err.Error())
-		return false, &util.CustomError{Message: "Invalid Id", Status: http.StatusBadRequest}
+		return false, &util.CustomError{Message: "Invalid Id", Status: http.StatusBadRequest, Cause: err}
 	}
 
-	result := otpCollection.FindOne(context.Background(), bson.M{"_id": objId, "email": email, "verified": true})
-
-	var otpDocument bson.M
-	result.Decode(&otpDocument)
-
-	if otpDocument == nil {
+	filter := bson.M{"_id": objId, "email": email, "purpose": purpose, "verified": true, "expireAt": bson.M{"$gt": time.Now().UTC()}}
+	err = otpCollection.FindOneAndDelete(ctx, filter).Err()
+	if err == mongo.ErrNoDocuments {
 		return false, nil
-	} else {
-		return true, nil
-	}
-}
-
-func RemoveVerifiedUser(id string, email string) (status bool, err error) {
-	client, err := DbSetup()
-	if err != nil {
-		logger.ErrorLogger.Println(err.Error())
-		return false, &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError}
 	}
-	defer client.Disconnect(context.Background())
-
-	otpCollection := client.Database(constants.DatabaseName).Collection(constants.OtpCollection)
-
-	objId, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
-		logger.ErrorLogger.Println(err.Error())
-		return false, &util.CustomError{Message: "Invalid Id", Status: http.StatusBadRequest}
+		return false, &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
 	}
 
-	result := otpCollection.FindOneAndDelete(context.Background(), bson.M{"_id": objId, "email": email, "verified": true})
-
-	var otpDocument bson.M
-	result.Decode(&otpDocument)
-
-	if otpDocument == nil {
-		return false, nil
-	} else {
-		return true, nil
-	}
+	return true, nil
 }
@@ -0,0 +1,149 @@
+package db
+
+import (
+	"context"
+	"net/http"
+	"password-manager/constants"
+	"password-manager/entity"
+	"password-manager/util"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoPasskeyStore struct {
+	database *mongo.Database
+}
+
+func NewMongoPasskeyStore(database *mongo.Database) PasskeyStore {
+	return &mongoPasskeyStore{
+		database: database,
+	}
+}
+
+func (store *mongoPasskeyStore) CreatePasskey(ctx context.Context, passkey entity.Passkey) (err error) {
+	ctx, done := instrument(ctx, "passkeys.CreatePasskey")
+	defer done(&err)
+
+	passkeysCollection := store.database.Collection(constants.PasskeysCollection)
+
+	if _, err = passkeysCollection.InsertOne(ctx, passkey); err != nil {
+		return &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
+	}
+
+	return nil
+}
+
+func (store *mongoPasskeyStore) GetPasskeys(ctx context.Context, userId string) (passkeys []entity.Passkey, err error) {
+	ctx, done := instrument(ctx, "passkeys.GetPasskeys")
+	defer done(&err)
+
+	passkeysCollection := store.database.Collection(constants.PasskeysCollection)
+
+	options := options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}})
+	cursor, err := passkeysCollection.Find(ctx, bson.M{"userId": userId}, options)
+	if err != nil {
+		return []entity.Passkey{}, &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
+	}
+
+	passkeys = []entity.Passkey{}
+	if err = cursor.All(ctx, &passkeys); err != nil {
+		return []entity.Passkey{}, &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
+	}
+
+	return passkeys, nil
+}
+
+func (store *mongoPasskeyStore) FindPasskey(ctx context.Context, credentialId string) (passkey entity.Passkey, found bool, err error) {
+	ctx, done := instrument(ctx, "passkeys.FindPasskey")
+	defer done(&err)
+
+	passkeysCollection := store.database.Collection(constants.PasskeysCollection)
+
+	err = passkeysCollection.FindOne(ctx, bson.M{"credentialId": credentialId}).Decode(&passkey)
+	if err == mongo.ErrNoDocuments {
+		return entity.Passkey{}, false, nil
+	}
+	if err != nil {
+		return entity.Passkey{}, false, &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
+	}
+
+	return passkey, true, nil
+}
+
+func (store *mongoPasskeyStore) RenamePasskey(ctx context.Context, userId string, credentialId string, name string) (found bool, err error) {
+	ctx, done := instrument(ctx, "passkeys.RenamePasskey")
+	defer done(&err)
+
+	passkeysCollection := store.database.Collection(constants.PasskeysCollection)
+
+	filter := bson.M{"userId": userId, "credentialId": credentialId}
+	result, err := passkeysCollection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"name": name}})
+	if err != nil {
+		return false, &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
+	}
+
+	return result.MatchedCount == 1, nil
+}
+
+func (store *mongoPasskeyStore) DeletePasskey(ctx context.Context, userId string, credentialId string) (found bool, err error) {
+	ctx, done := instrument(ctx, "passkeys.DeletePasskey")
+	defer done(&err)
+
+	passkeysCollection := store.database.Collection(constants.PasskeysCollection)
+
+	result, err := passkeysCollection.DeleteOne(ctx, bson.M{"userId": userId, "credentialId": credentialId})
+	if err != nil {
+		return false, &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
+	}
+
+	return result.DeletedCount == 1, nil
+}
+
+func (store *mongoPasskeyStore) UpdatePasskeyUsage(ctx context.Context, credentialId string, signCount uint32, backupState bool, usedAt time.Time) (err error) {
+	ctx, done := instrument(ctx, "passkeys.UpdatePasskeyUsage")
+	defer done(&err)
+
+	passkeysCollection := store.database.Collection(constants.PasskeysCollection)
+
+	update := bson.M{"$set": bson.M{"signCount": signCount, "backupState": backupState, "lastUsedAt": usedAt}}
+	if _, err = passkeysCollection.UpdateOne(ctx, bson.M{"credentialId": credentialId}, update); err != nil {
+		return &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
+	}
+
+	return nil
+}
+
+func (store *mongoPasskeyStore) SaveCeremony(ctx context.Context, ceremony entity.WebAuthnCeremony) (err error) {
+	ctx, done := instrument(ctx, "passkeys.SaveCeremony")
+	defer done(&err)
+
+	ceremoniesCollection := store.database.Collection(constants.CeremoniesCollection)
+
+	if _, err = ceremoniesCollection.InsertOne(ctx, ceremony); err != nil {
+		return &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
+	}
+
+	return nil
+}
+
+// TakeCeremony removes the ceremony as it is read, so each WebAuthn
+// challenge can be answered only once.
+func (store *mongoPasskeyStore) TakeCeremony(ctx context.Context, ceremonyId string) (ceremony entity.WebAuthnCeremony, found bool, err error) {
+	ctx, done := instrument(ctx, "passkeys.TakeCeremony")
+	defer done(&err)
+
+	ceremoniesCollection := store.database.Collection(constants.CeremoniesCollection)
+
+	err = ceremoniesCollection.FindOneAndDelete(ctx, bson.M{"ceremonyId": ceremonyId}).Decode(&ceremony)
+	if err == mongo.ErrNoDocuments {
+		return entity.WebAuthnCeremony{}, false, nil
+	}
+	if err != nil {
+		return entity.WebAuthnCeremony{}, false, &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
+	}
+
+	return ceremony, true, nil
+}
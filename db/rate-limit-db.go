@@ -0,0 +1,63 @@
+package db
+
+import (
+	"context"
+	"net/http"
+	"password-manager/constants"
+	"password-manager/entity"
+	"password-manager/util"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoRateLimitStore struct {
+	database *mongo.Database
+}
+
+func NewMongoRateLimitStore(database *mongo.Database) RateLimitStore {
+	return &mongoRateLimitStore{
+		database: database,
+	}
+}
+
+// TakeToken refills and takes from the bucket in a single pipeline update so
+// that concurrent requests on different instances cannot spend the same
+// token. A missing bucket starts full.
+func (store *mongoRateLimitStore) TakeToken(ctx context.Context, key string, capacity int, refill time.Duration, now time.Time) (bucket entity.RateBucket, err error) {
+	ctx, done := instrument(ctx, "rateLimits.TakeToken")
+	defer done(&err)
+
+	rateLimitsCollection := store.database.Collection(constants.RateLimitsCollection)
+
+	now = now.UTC()
+	full := float64(capacity)
+	elapsed := bson.M{"$max": bson.A{0, bson.M{"$subtract": bson.A{now, bson.M{"$ifNull": bson.A{"$updatedAt", now}}}}}}
+	refilled := bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$tokens", full}}, bson.M{"$divide": bson.A{elapsed, float64(refill.Milliseconds())}}}}
+	hasToken := bson.M{"$gte": bson.A{"$tokens", 1}}
+	update := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"tokens": bson.M{"$min": bson.A{full, refilled}},
+		}}},
+		{{Key: "$set", Value: bson.M{
+			"allowed":   hasToken,
+			"tokens":    bson.M{"$cond": bson.A{hasToken, bson.M{"$subtract": bson.A{"$tokens", 1}}, "$tokens"}},
+			"updatedAt": bson.M{"$max": bson.A{bson.M{"$ifNull": bson.A{"$updatedAt", now}}, now}},
+			"expireAt":  now.Add(refill * time.Duration(capacity)),
+		}}},
+	}
+	options := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	err = rateLimitsCollection.FindOneAndUpdate(ctx, bson.M{"key": key}, update, options).Decode(&bucket)
+	if mongo.IsDuplicateKeyError(err) {
+		// Another request created the bucket between our find and insert.
+		err = rateLimitsCollection.FindOneAndUpdate(ctx, bson.M{"key": key}, update, options).Decode(&bucket)
+	}
+	if err != nil {
+		return entity.RateBucket{}, &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
+	}
+
+	return bucket, nil
+}
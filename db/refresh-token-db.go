@@ -0,0 +1,79 @@
+package db
+
+import (
+	"context"
+	"net/http"
+	"password-manager/constants"
+	"password-manager/entity"
+	"password-manager/util"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoRefreshTokenStore struct {
+	database *mongo.Database
+}
+
+func NewMongoRefreshTokenStore(database *mongo.Database) RefreshTokenStore {
+	return &mongoRefreshTokenStore{
+		database: database,
+	}
+}
+
+func (store *mongoRefreshTokenStore) CreateRefreshToken(ctx context.Context, token entity.RefreshToken) (err error) {
+	ctx, done := instrument(ctx, "refreshTokens.CreateRefreshToken")
+	defer done(&err)
+
+	refreshTokensCollection := store.database.Collection(constants.RefreshTokensCollection)
+
+	if _, err = refreshTokensCollection.InsertOne(ctx, token); err != nil {
+		return &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
+	}
+
+	return nil
+}
+
+func (store *mongoRefreshTokenStore) UseRefreshToken(ctx context.Context, tokenHash string, usedAt time.Time) (token entity.RefreshToken, found bool, err error) {
+	ctx, done := instrument(ctx, "refreshTokens.UseRefreshToken")
+	defer done(&err)
+
+	refreshTokensCollection := store.database.Collection(constants.RefreshTokensCollection)
+
+	filter := bson.M{"tokenHash": tokenHash, "usedAt": nil}
+	update := bson.M{"$set": bson.M{"usedAt": usedAt}}
+	options := options.FindOneAndUpdate().SetReturnDocument(options.Before)
+	err = refreshTokensCollection.FindOneAndUpdate(ctx, filter, update, options).Decode(&token)
+	if err == nil {
+		return token, true, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return entity.RefreshToken{}, false, &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
+	}
+
+	err = refreshTokensCollection.FindOne(ctx, bson.M{"tokenHash": tokenHash}).Decode(&token)
+	if err == mongo.ErrNoDocuments {
+		return entity.RefreshToken{}, false, nil
+	}
+	if err != nil {
+		return entity.RefreshToken{}, false, &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
+	}
+
+	return token, true, nil
+}
+
+func (store *mongoRefreshTokenStore) RevokeRefreshTokenFamily(ctx context.Context, familyId string) (err error) {
+	ctx, done := instrument(ctx, "refreshTokens.RevokeRefreshTokenFamily")
+	defer done(&err)
+
+	refreshTokensCollection := store.database.Collection(constants.RefreshTokensCollection)
+
+	update := bson.M{"$set": bson.M{"revoked": true}}
+	if _, err = refreshTokensCollection.UpdateMany(ctx, bson.M{"familyId": familyId}, update); err != nil {
+		return &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
+	}
+
+	return nil
+}
@@ -0,0 +1,151 @@
+package db
+
+import (
+	"context"
+	"net/http"
+	"password-manager/constants"
+	"password-manager/entity"
+	"password-manager/util"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoSessionStore struct {
+	database *mongo.Database
+}
+
+func NewMongoSessionStore(database *mongo.Database) SessionStore {
+	return &mongoSessionStore{
+		database: database,
+	}
+}
+
+func (store *mongoSessionStore) CreateSession(ctx context.Context, session entity.Session) (err error) {
+	ctx, done := instrument(ctx, "sessions.CreateSession")
+	defer done(&err)
+
+	sessionsCollection := store.database.Collection(constants.SessionsCollection)
+
+	if _, err = sessionsCollection.InsertOne(ctx, session); err != nil {
+		return &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
+	}
+
+	return nil
+}
+
+func (store *mongoSessionStore) GetSession(ctx context.Context, sessionId string) (session entity.Session, found bool, err error) {
+	ctx, done := instrument(ctx, "sessions.GetSession")
+	defer done(&err)
+
+	sessionsCollection := store.database.Collection(constants.SessionsCollection)
+
+	err = sessionsCollection.FindOne(ctx, bson.M{"sessionId": sessionId}).Decode(&session)
+	if err == mongo.ErrNoDocuments {
+		return entity.Session{}, false, nil
+	}
+	if err != nil {
+		return entity.Session{}, false, &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
+	}
+
+	return session, true, nil
+}
+
+func (store *mongoSessionStore) ListSessions(ctx context.Context, userId string, now time.Time) (sessions []entity.Session, err error) {
+	ctx, done := instrument(ctx, "sessions.ListSessions")
+	defer done(&err)
+
+	sessionsCollection := store.database.Collection(constants.SessionsCollection)
+
+	filter := bson.M{"userId": userId, "revoked": false, "expireAt": bson.M{"$gt": now}}
+	options := options.Find().SetSort(bson.D{{Key: "lastSeenAt", Value: -1}})
+	cursor, err := sessionsCollection.Find(ctx, filter, options)
+	if err != nil {
+		return []entity.Session{}, &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
+	}
+
+	sessions = []entity.Session{}
+	if err = cursor.All(ctx, &sessions); err != nil {
+		return []entity.Session{}, &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
+	}
+
+	return sessions, nil
+}
+
+func (store *mongoSessionStore) TouchSession(ctx context.Context, sessionId string, lastSeenAt time.Time, ip string) (err error) {
+	ctx, done := instrument(ctx, "sessions.TouchSession")
+	defer done(&err)
+
+	sessionsCollection := store.database.Collection(constants.SessionsCollection)
+
+	update := bson.M{"$set": bson.M{"lastSeenAt": lastSeenAt, "ip": ip}}
+	if _, err = sessionsCollection.UpdateOne(ctx, bson.M{"sessionId": sessionId}, update); err != nil {
+		return &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
+	}
+
+	return nil
+}
+
+func (store *mongoSessionStore) ExtendSession(ctx context.Context, sessionId string, expiresAt time.Time) (err error) {
+	ctx, done := instrument(ctx, "sessions.ExtendSession")
+	defer done(&err)
+
+	sessionsCollection := store.database.Collection(constants.SessionsCollection)
+
+	update := bson.M{"$set": bson.M{"expireAt": expiresAt}}
+	if _, err = sessionsCollection.UpdateOne(ctx, bson.M{"sessionId": sessionId}, update); err != nil {
+		return &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
+	}
+
+	return nil
+}
+
+func (store *mongoSessionStore) RevokeSession(ctx context.Context, userId string, sessionId string) (revoked bool, err error) {
+	ctx, done := instrument(ctx, "sessions.RevokeSession")
+	defer done(&err)
+
+	sessionsCollection := store.database.Collection(constants.SessionsCollection)
+
+	filter := bson.M{"userId": userId, "sessionId": sessionId, "revoked": false}
+	result, err := sessionsCollection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"revoked": true}})
+	if err != nil {
+		return false, &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
+	}
+
+	return result.ModifiedCount == 1, nil
+}
+
+func (store *mongoSessionStore) RevokeOtherSessions(ctx context.Context, userId string, keepSessionId string) (sessionIds []string, err error) {
+	ctx, done := instrument(ctx, "sessions.RevokeOtherSessions")
+	defer done(&err)
+
+	sessionsCollection := store.database.Collection(constants.SessionsCollection)
+
+	filter := bson.M{"userId": userId, "sessionId": bson.M{"$ne": keepSessionId}, "revoked": false}
+	cursor, err := sessionsCollection.Find(ctx, filter)
+	if err != nil {
+		return []string{}, &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
+	}
+
+	var sessions []entity.Session
+	if err = cursor.All(ctx, &sessions); err != nil {
+		return []string{}, &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
+	}
+
+	sessionIds = []string{}
+	for _, session := range sessions {
+		sessionIds = append(sessionIds, session.Id)
+	}
+	if len(sessionIds) == 0 {
+		return sessionIds, nil
+	}
+
+	filter = bson.M{"sessionId": bson.M{"$in": sessionIds}}
+	if _, err = sessionsCollection.UpdateMany(ctx, filter, bson.M{"$set": bson.M{"revoked": true}}); err != nil {
+		return []string{}, &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
+	}
+
+	return sessionIds, nil
+}
@@ -5,54 +5,33 @@ import (
 	"net/http"
 	"password-manager/constants"
 	"password-manager/entity"
-	"password-manager/logger"
 	"password-manager/util"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-func ReadSites() (sites []entity.Site, err error) {
-	client, err := DbSetup()
-	if err != nil {
-		logger.ErrorLogger.Println(err.Error())
-		return []entity.Site{}, &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError}
-	}
-	defer client.Disconnect(context.Background())
-
-	sitesCollection := client.Database(constants.DatabaseName).Collection(constants.SitesCollection)
-
-	cursor, err := sitesCollection.Find(context.Background(), bson.M{})
-	if err != nil {
-		logger.ErrorLogger.Println(err.Error())
-		return []entity.Site{}, &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError}
-	}
+type mongoSiteStore struct {
+	database *mongo.Database
+}
 
-	sites = []entity.Site{}
-	for cursor.Next(context.Background()) {
-		var site entity.Site
-		cursor.Decode(&site)
-		sites = append(sites, site)
+func NewMongoSiteStore(database *mongo.Database) SiteStore {
+	return &mongoSiteStore{
+		database: database,
 	}
-
-	return sites, nil
 }
 
-func SaveSite(userId string, site entity.Site) (id string, err error) {
-	client, err := DbSetup()
-	if err != nil {
-		logger.ErrorLogger.Println(err.Error())
-		return "", &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError}
-	}
-	defer client.Disconnect(context.Background())
+func (store *mongoSiteStore) SaveSite(ctx context.Context, userId string, site entity.Site) (id string, err error) {
+	ctx, done := instrument(ctx, "sites.SaveSite")
+	defer done(&err)
 
-	sitesCollection := client.Database(constants.DatabaseName).Collection(constants.SitesCollection)
+	sitesCollection := store.database.Collection(constants.SitesCollection)
 
 	userObjId, err := primitive.ObjectIDFromHex(userId)
 	if err != nil {
-		logger.ErrorLogger.Println(err.Error())
-		return "", &util.CustomError{Message: "Invalid User Id", Status: http.StatusBadRequest}
+		return "", &util.CustomError{Message: "Invalid User Id", Status: http.StatusBadRequest, Cause: err}
 	}
 
 	document := bson.M{
@@ -64,45 +43,38 @@ func SaveSite(userId string, site entity.Site) (id string, err error) {
 		"password": site.Password,
 		"notes":    site.Notes,
 		"image":    site.Image,
+		"keyId":    site.KeyId,
 	}
-	result, err := sitesCollection.InsertOne(context.Background(), document)
+	result, err := sitesCollection.InsertOne(ctx, document)
 	if err != nil {
-		logger.ErrorLogger.Println(err.Error())
-		return "", &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError}
+		return "", &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
 	}
 
 	return result.InsertedID.(primitive.ObjectID).Hex(), nil
 }
 
-func GetSites(userId string) (sites []entity.Site, err error) {
-	client, err := DbSetup()
-	if err != nil {
-		logger.ErrorLogger.Println(err.Error())
-		return []entity.Site{}, &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError}
-	}
-	defer client.Disconnect(context.Background())
+func (store *mongoSiteStore) GetSites(ctx context.Context, userId string) (sites []entity.Site, err error) {
+	ctx, done := instrument(ctx, "sites.GetSites")
+	defer done(&err)
 
-	sitesCollection := client.Database(constants.DatabaseName).Collection(constants.SitesCollection)
+	sitesCollection := store.database.Collection(constants.SitesCollection)
 
 	userObjId, err := primitive.ObjectIDFromHex(userId)
 	if err != nil {
-		logger.ErrorLogger.Println(err.Error())
-		return []entity.Site{}, &util.CustomError{Message: "Invalid User Id", Status: http.StatusBadRequest}
+		return []entity.Site{}, &util.CustomError{Message: "Invalid User Id", Status: http.StatusBadRequest, Cause: err}
 	}
 
-	cursor, err := sitesCollection.Find(context.Background(), bson.M{"userId": userObjId})
+	cursor, err := sitesCollection.Find(ctx, bson.M{"userId": userObjId})
 	if err != nil {
-		logger.ErrorLogger.Println(err.Error())
-		return []entity.Site{}, &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError}
+		return []entity.Site{}, &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
 	}
 
 	sites = []entity.Site{}
-	for cursor.Next(context.Background()) {
+	for cursor.Next(ctx) {
 		var site entity.Site
 		err = cursor.Decode(&site)
 		if err != nil {
-			logger.ErrorLogger.Println(err.Error())
-			return []entity.Site{}, &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError}
+			return []entity.Site{}, &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
 		}
 		sites = append(sites, site)
 	}
@@ -110,20 +82,15 @@ func GetSites(userId string) (sites []entity.Site, err error) {
 	return sites, nil
 }
 
-func EditSite(siteId string, site entity.Site) (updatedSite entity.Site, err error) {
-	client, err := DbSetup()
-	if err != nil {
-		logger.ErrorLogger.Println(err.Error())
-		return entity.Site{}, &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError}
-	}
-	defer client.Disconnect(context.Background())
+func (store *mongoSiteStore) EditSite(ctx context.Context, siteId string, site entity.Site) (updatedSite entity.Site, err error) {
+	ctx, done := instrument(ctx, "sites.EditSite")
+	defer done(&err)
 
-	sitesCollection := client.Database(constants.DatabaseName).Collection(constants.SitesCollection)
+	sitesCollection := store.database.Collection(constants.SitesCollection)
 
 	siteObjId, err := primitive.ObjectIDFromHex(siteId)
 	if err != nil {
-		logger.ErrorLogger.Println(err.Error())
-		return entity.Site{}, &util.CustomError{Message: "Invalid Site Id", Status: http.StatusBadRequest}
+		return entity.Site{}, &util.CustomError{Message: "Invalid Site Id", Status: http.StatusBadRequest, Cause: err}
 	}
 
 	filter := bson.M{"_id": siteObjId}
@@ -135,33 +102,33 @@ func EditSite(siteId string, site entity.Site) (updatedSite entity.Site, err err
 		"password": site.Password,
 		"notes":    site.Notes,
 		"image":    site.Image,
+		"keyId":    site.KeyId,
 	}}
 	options := options.FindOneAndUpdate().SetReturnDocument(options.After)
-	result := sitesCollection.FindOneAndUpdate(context.Background(), filter, update, options)
+	err = sitesCollection.FindOneAndUpdate(ctx, filter, update, options).Decode(&updatedSite)
+	if err == mongo.ErrNoDocuments {
+		return entity.Site{}, &util.CustomError{Message: "Site not found", Status: http.StatusBadRequest}
+	}
+	if err != nil {
+		return entity.Site{}, &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
+	}
 
-	result.Decode(&updatedSite)
 	return updatedSite, nil
 }
 
-func DeleteSite(userId string, siteId string) (err error) {
-	client, err := DbSetup()
-	if err != nil {
-		logger.ErrorLogger.Println(err.Error())
-		return &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError}
-	}
-	defer client.Disconnect(context.Background())
+func (store *mongoSiteStore) DeleteSite(ctx context.Context, userId string, siteId string) (err error) {
+	ctx, done := instrument(ctx, "sites.DeleteSite")
+	defer done(&err)
 
-	sitesCollection := client.Database(constants.DatabaseName).Collection(constants.SitesCollection)
+	sitesCollection := store.database.Collection(constants.SitesCollection)
 
 	userObjId, err := primitive.ObjectIDFromHex(userId)
 	if err != nil {
-		logger.ErrorLogger.Println(err.Error())
-		return &util.CustomError{Message: "Invalid User Id", Status: http.StatusBadRequest}
+		return &util.CustomError{Message: "Invalid User Id", Status: http.StatusBadRequest, Cause: err}
 	}
 	siteObjId, err := primitive.ObjectIDFromHex(siteId)
 	if err != nil {
-		logger.ErrorLogger.Println(err.Error())
-		return &util.CustomError{Message: "Invalid Site Id", Status: http.StatusBadRequest}
+		return &util.CustomError{Message: "Invalid Site Id", Status: http.StatusBadRequest, Cause: err}
 	}
 
 	filter := bson.M{
@@ -172,45 +139,122 @@ func DeleteSite(userId string, siteId string) (err error) {
 		"userId":    "",
 		"oldUserId": userObjId,
 	}}
-	_, err = sitesCollection.UpdateOne(context.Background(), filter, update)
+	_, err = sitesCollection.UpdateOne(ctx, filter, update)
 	if err != nil {
-		logger.ErrorLogger.Println(err.Error())
-		return &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError}
+		return &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
 	} else {
 		return nil
 	}
 }
 
-func GetSite(userId string, siteId string) (site entity.Site, err error) {
-	client, err := DbSetup()
+func (store *mongoSiteStore) GetSite(ctx context.Context, userId string, siteId string) (site entity.Site, err error) {
+	ctx, done := instrument(ctx, "sites.GetSite")
+	defer done(&err)
+
+	sitesCollection := store.database.Collection(constants.SitesCollection)
+
+	userObjId, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return entity.Site{}, &util.CustomError{Message: "Invalid User Id", Status: http.StatusBadRequest, Cause: err}
+	}
+	siteObjId, err := primitive.ObjectIDFromHex(siteId)
+	if err != nil {
+		return entity.Site{}, &util.CustomError{Message: "Invalid Site Id", Status: http.StatusBadRequest, Cause: err}
+	}
+
+	err = sitesCollection.FindOne(ctx, bson.M{"_id": siteObjId, "userId": userObjId}).Decode(&site)
+	if err == mongo.ErrNoDocuments {
+		return entity.Site{}, &util.CustomError{Message: "Site not found", Status: http.StatusBadRequest}
+	}
 	if err != nil {
-		logger.ErrorLogger.Println(err.Error())
-		return entity.Site{}, &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError}
+		return entity.Site{}, &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
+	}
+
+	return site, nil
+}
+
+func (store *mongoSiteStore) ListSiteOwners(ctx context.Context) (userIds []string, err error) {
+	ctx, done := instrument(ctx, "sites.ListSiteOwners")
+	defer done(&err)
+
+	sitesCollection := store.database.Collection(constants.SitesCollection)
+
+	seen := map[string]bool{}
+	userIds = []string{}
+	for _, field := range []string{"userId", "oldUserId"} {
+		values, err := sitesCollection.Distinct(ctx, field, bson.M{})
+		if err != nil {
+			return []string{}, &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
+		}
+		for _, value := range values {
+			if objId, ok := value.(primitive.ObjectID); ok && !seen[objId.Hex()] {
+				seen[objId.Hex()] = true
+				userIds = append(userIds, objId.Hex())
+			}
+		}
 	}
-	defer client.Disconnect(context.Background())
 
-	sitesCollection := client.Database(constants.DatabaseName).Collection(constants.SitesCollection)
+	return userIds, nil
+}
+
+func (store *mongoSiteStore) GetAllSites(ctx context.Context, userId string) (sites []entity.Site, err error) {
+	ctx, done := instrument(ctx, "sites.GetAllSites")
+	defer done(&err)
+
+	sitesCollection := store.database.Collection(constants.SitesCollection)
 
 	userObjId, err := primitive.ObjectIDFromHex(userId)
 	if err != nil {
-		logger.ErrorLogger.Println(err.Error())
-		return entity.Site{}, &util.CustomError{Message: "Invalid User Id", Status: http.StatusBadRequest}
+		return []entity.Site{}, &util.CustomError{Message: "Invalid User Id", Status: http.StatusBadRequest, Cause: err}
 	}
-	siteObjId, err := primitive.ObjectIDFromHex(siteId)
+
+	filter := bson.M{"$or": bson.A{bson.M{"userId": userObjId}, bson.M{"oldUserId": userObjId}}}
+	cursor, err := sitesCollection.Find(ctx, filter)
 	if err != nil {
-		logger.ErrorLogger.Println(err.Error())
-		return entity.Site{}, &util.CustomError{Message: "Invalid Site Id", Status: http.StatusBadRequest}
+		return []entity.Site{}, &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
 	}
 
-	result := sitesCollection.FindOne(context.Background(), bson.M{"_id": siteObjId, "userId": userObjId})
+	sites = []entity.Site{}
+	if err = cursor.All(ctx, &sites); err != nil {
+		return []entity.Site{}, &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
+	}
 
-	var siteDecoded bson.M
-	result.Decode(&siteDecoded)
-	if siteDecoded == nil {
-		return entity.Site{}, &util.CustomError{Message: "Site not found", Status: http.StatusBadRequest}
+	return sites, nil
+}
+
+func (store *mongoSiteStore) ReEncryptSite(ctx context.Context, siteId string, previous entity.Site, site entity.Site) (updated bool, err error) {
+	ctx, done := instrument(ctx, "sites.ReEncryptSite")
+	defer done(&err)
+
+	sitesCollection := store.database.Collection(constants.SitesCollection)
+
+	siteObjId, err := primitive.ObjectIDFromHex(siteId)
+	if err != nil {
+		return false, &util.CustomError{Message: "Invalid Site Id", Status: http.StatusBadRequest, Cause: err}
 	}
 
-	result.Decode(&site)
+	var previousKeyId interface{} = previous.KeyId
+	if previous.KeyId == "" {
+		previousKeyId = bson.M{"$in": bson.A{nil, ""}}
+	}
 
-	return site, nil
+	filter := bson.M{
+		"_id":      siteObjId,
+		"keyId":    previousKeyId,
+		"username": previous.Username,
+		"password": previous.Password,
+		"notes":    previous.Notes,
+	}
+	update := bson.M{"$set": bson.M{
+		"username": site.Username,
+		"password": site.Password,
+		"notes":    site.Notes,
+		"keyId":    site.KeyId,
+	}}
+	result, err := sitesCollection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
+	}
+
+	return result.ModifiedCount == 1, nil
 }
@@ -0,0 +1,185 @@
+package db
+
+import (
+	"context"
+	"password-manager/entity"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type UserStore interface {
+	RegisterUser(ctx context.Context, email string, password string) (userId string, err error)
+	ResetPassword(ctx context.Context, email string, password string) (passwordSetAt string, err error)
+	CheckPasswordReset(ctx context.Context, userId string) (passwordSetAt string, err error)
+	CheckUserRegistered(ctx context.Context, email string) (status bool, err error)
+	FindUserByEmail(ctx context.Context, email string) (user entity.User, found bool, err error)
+	FindUserById(ctx context.Context, userId string) (user entity.User, found bool, err error)
+	RehashPassword(ctx context.Context, userId string, oldPassword string, newPassword string) (err error)
+	// SetKdfParams only writes when the stored parameters are at
+	// expectedRevision, with 0 meaning none are set yet.
+	SetKdfParams(ctx context.Context, userId string, params entity.KdfParams, expectedRevision int64) (written bool, err error)
+	SetTotp(ctx context.Context, userId string, totp *entity.TotpSettings) (err error)
+	UseTotpStep(ctx context.Context, userId string, step int64) (accepted bool, err error)
+	SetRecoveryCodes(ctx context.Context, userId string, codeHashes []string) (err error)
+	UseRecoveryCode(ctx context.Context, userId string, codeHash string) (used bool, err error)
+}
+
+// OtpStore keeps one pending OTP per email and purpose. Only an HMAC of the
+// code is stored, and a verified OTP can be used once.
+type OtpStore interface {
+	GenerateOtp(ctx context.Context, email string, purpose string, otpHash string, lifetime time.Duration) (id string, expiresAt string, err error)
+	VerifyOtp(ctx context.Context, dbId string, email string, otpHash string) (err error)
+	OtpVerified(ctx context.Context, dbId string, email string, lifetime time.Duration) (expiresAt string, err error)
+	UseVerifiedOtp(ctx context.Context, dbId string, email string, purpose string) (used bool, err error)
+}
+
+type SiteStore interface {
+	SaveSite(ctx context.Context, userId string, site entity.Site) (id string, err error)
+	GetSites(ctx context.Context, userId string) (sites []entity.Site, err error)
+	GetSite(ctx context.Context, userId string, siteId string) (site entity.Site, err error)
+	EditSite(ctx context.Context, siteId string, site entity.Site) (updatedSite entity.Site, err error)
+	DeleteSite(ctx context.Context, userId string, siteId string) (err error)
+	ListSiteOwners(ctx context.Context) (userIds []string, err error)
+	GetAllSites(ctx context.Context, userId string) (sites []entity.Site, err error)
+	ReEncryptSite(ctx context.Context, siteId string, previous entity.Site, site entity.Site) (updated bool, err error)
+}
+
+type TokenBlacklist interface {
+	BlacklistToken(ctx context.Context, token string, expirationTime time.Time) (err error)
+	CheckBlacklist(ctx context.Context, token string) (blacklisted bool, err error)
+}
+
+// DataKeyStore holds users' data keys wrapped by a master key from the key
+// ring. A user's newest key is the active one used for new writes.
+type DataKeyStore interface {
+	GetDataKey(ctx context.Context, userId string, keyId string) (key entity.DataKey, found bool, err error)
+	GetActiveDataKey(ctx context.Context, userId string) (key entity.DataKey, found bool, err error)
+	CreateDataKey(ctx context.Context, key entity.DataKey) (err error)
+	ListDataKeysNotWrappedBy(ctx context.Context, masterKeyId string) (keys []entity.DataKey, err error)
+	RewrapDataKey(ctx context.Context, key entity.DataKey, previousMasterKeyId string) (updated bool, err error)
+}
+
+// VaultItemStore keeps client-encrypted items that the server never decrypts.
+// PutItem only writes when the stored revision equals expectedRevision, with
+// zero meaning the item must not exist yet.
+type VaultItemStore interface {
+	GetItems(ctx context.Context, userId string, updatedAfter time.Time) (items []entity.VaultItem, err error)
+	GetItem(ctx context.Context, userId string, itemId string) (item entity.VaultItem, found bool, err error)
+	PutItem(ctx context.Context, userId string, item entity.VaultItem, expectedRevision int64) (written bool, err error)
+}
+
+// RefreshTokenStore keeps hashes of issued refresh tokens. UseRefreshToken
+// marks a token as used and returns it as it was before, so a non-nil UsedAt
+// tells the caller the token has been presented before.
+type RefreshTokenStore interface {
+	CreateRefreshToken(ctx context.Context, token entity.RefreshToken) (err error)
+	UseRefreshToken(ctx context.Context, tokenHash string, usedAt time.Time) (token entity.RefreshToken, found bool, err error)
+	RevokeRefreshTokenFamily(ctx context.Context, familyId string) (err error)
+}
+
+// SessionStore records every sign-in. A session shares its id with the
+// refresh token family issued at that sign-in.
+type SessionStore interface {
+	CreateSession(ctx context.Context, session entity.Session) (err error)
+	GetSession(ctx context.Context, sessionId string) (session entity.Session, found bool, err error)
+	ListSessions(ctx context.Context, userId string, now time.Time) (sessions []entity.Session, err error)
+	TouchSession(ctx context.Context, sessionId string, lastSeenAt time.Time, ip string) (err error)
+	ExtendSession(ctx context.Context, sessionId string, expiresAt time.Time) (err error)
+	RevokeSession(ctx context.Context, userId string, sessionId string) (revoked bool, err error)
+	RevokeOtherSessions(ctx context.Context, userId string, keepSessionId string) (sessionIds []string, err error)
+}
+
+type PasskeyStore interface {
+	CreatePasskey(ctx context.Context, passkey entity.Passkey) (err error)
+	GetPasskeys(ctx context.Context, userId string) (passkeys []entity.Passkey, err error)
+	FindPasskey(ctx context.Context, credentialId string) (passkey entity.Passkey, found bool, err error)
+	RenamePasskey(ctx context.Context, userId string, credentialId string, name string) (found bool, err error)
+	DeletePasskey(ctx context.Context, userId string, credentialId string) (found bool, err error)
+	UpdatePasskeyUsage(ctx context.Context, credentialId string, signCount uint32, backupState bool, usedAt time.Time) (err error)
+	SaveCeremony(ctx context.Context, ceremony entity.WebAuthnCeremony) (err error)
+	TakeCeremony(ctx context.Context, ceremonyId string) (ceremony entity.WebAuthnCeremony, found bool, err error)
+}
+
+// AttemptStore counts failed attempts per key. Counters expire once no
+// failure has been recorded for a window, or once any block has passed.
+type AttemptStore interface {
+	GetAttempts(ctx context.Context, key string) (counter entity.AttemptCounter, err error)
+	RecordFailure(ctx context.Context, key string, now time.Time, window time.Duration) (counter entity.AttemptCounter, err error)
+	BlockAttempts(ctx context.Context, key string, until time.Time) (err error)
+	ResetAttempts(ctx context.Context, key string) (err error)
+}
+
+// RateLimitStore keeps token buckets. TakeToken refills the bucket for the
+// time since it was last used, at one token per refill, up to capacity, and
+// takes one token when there is one.
+type RateLimitStore interface {
+	TakeToken(ctx context.Context, key string, capacity int, refill time.Duration, now time.Time) (bucket entity.RateBucket, err error)
+}
+
+// HealthStore reports whether the backing database can serve requests.
+type HealthStore interface {
+	Ping(ctx context.Context) (err error)
+}
+
+type Stores struct {
+	Users      UserStore
+	Otps       OtpStore
+	Sites      SiteStore
+	Blacklist  TokenBlacklist
+	DataKeys   DataKeyStore
+	Items      VaultItemStore
+	Refresh    RefreshTokenStore
+	Sessions   SessionStore
+	Passkeys   PasskeyStore
+	Attempts   AttemptStore
+	RateLimits RateLimitStore
+	Health     HealthStore
+}
+
+func NewMongoStores(database *mongo.Database) Stores {
+	return Stores{
+		Users:      NewMongoUserStore(database),
+		Otps:       NewMongoOtpStore(database),
+		Sites:      NewMongoSiteStore(database),
+		Blacklist:  NewMongoTokenBlacklist(database),
+		DataKeys:   NewMongoDataKeyStore(database),
+		Items:      NewMongoVaultItemStore(database),
+		Refresh:    NewMongoRefreshTokenStore(database),
+		Sessions:   NewMongoSessionStore(database),
+		Passkeys:   NewMongoPasskeyStore(database),
+		Attempts:   NewMongoAttemptStore(database),
+		RateLimits: NewMongoRateLimitStore(database),
+		Health:     NewMongoHealthStore(database),
+	}
+}
+
+func NewMemoryStores() Stores {
+	return Stores{
+		Users:      NewMemoryUserStore(),
+		Otps:       NewMemoryOtpStore(),
+		Sites:      NewMemorySiteStore(),
+		Blacklist:  NewMemoryTokenBlacklist(),
+		DataKeys:   NewMemoryDataKeyStore(),
+		Items:      NewMemoryVaultItemStore(),
+		Refresh:    NewMemoryRefreshTokenStore(),
+		Sessions:   NewMemorySessionStore(),
+		Passkeys:   NewMemoryPasskeyStore(),
+		Attempts:   NewMemoryAttemptStore(),
+		RateLimits: NewMemoryRateLimitStore(),
+		Health:     NewMemoryHealthStore(),
+	}
+}
+
+func NewStores(backend string, config MongoConfig) (stores Stores, err error) {
+	if backend == "memory" {
+		return NewMemoryStores(), nil
+	}
+
+	client, err := Connect(config)
+	if err != nil {
+		return Stores{}, err
+	}
+
+	return NewMongoStores(client.Database(config.Database)), nil
+}
@@ -0,0 +1,111 @@
+package db
+
+import (
+	"context"
+	"net/http"
+	"password-manager/constants"
+	"password-manager/entity"
+	"password-manager/util"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoVaultItemStore struct {
+	database *mongo.Database
+}
+
+func NewMongoVaultItemStore(database *mongo.Database) VaultItemStore {
+	return &mongoVaultItemStore{
+		database: database,
+	}
+}
+
+func (store *mongoVaultItemStore) GetItems(ctx context.Context, userId string, updatedAfter time.Time) (items []entity.VaultItem, err error) {
+	ctx, done := instrument(ctx, "items.GetItems")
+	defer done(&err)
+
+	itemsCollection := store.database.Collection(constants.VaultItemsCollection)
+
+	userObjId, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return []entity.VaultItem{}, &util.CustomError{Message: "Invalid User Id", Status: http.StatusBadRequest, Cause: err}
+	}
+
+	filter := bson.M{"userId": userObjId, "updatedAt": bson.M{"$gt": updatedAfter}}
+	options := options.Find().SetSort(bson.D{{Key: "updatedAt", Value: 1}})
+	cursor, err := itemsCollection.Find(ctx, filter, options)
+	if err != nil {
+		return []entity.VaultItem{}, &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
+	}
+
+	items = []entity.VaultItem{}
+	if err = cursor.All(ctx, &items); err != nil {
+		return []entity.VaultItem{}, &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
+	}
+
+	return items, nil
+}
+
+func (store *mongoVaultItemStore) GetItem(ctx context.Context, userId string, itemId string) (item entity.VaultItem, found bool, err error) {
+	ctx, done := instrument(ctx, "items.GetItem")
+	defer done(&err)
+
+	itemsCollection := store.database.Collection(constants.VaultItemsCollection)
+
+	userObjId, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return entity.VaultItem{}, false, &util.CustomError{Message: "Invalid User Id", Status: http.StatusBadRequest, Cause: err}
+	}
+
+	err = itemsCollection.FindOne(ctx, bson.M{"userId": userObjId, "itemId": itemId}).Decode(&item)
+	if err == mongo.ErrNoDocuments {
+		return entity.VaultItem{}, false, nil
+	}
+	if err != nil {
+		return entity.VaultItem{}, false, &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
+	}
+
+	return item, true, nil
+}
+
+func (store *mongoVaultItemStore) PutItem(ctx context.Context, userId string, item entity.VaultItem, expectedRevision int64) (written bool, err error) {
+	ctx, done := instrument(ctx, "items.PutItem")
+	defer done(&err)
+
+	itemsCollection := store.database.Collection(constants.VaultItemsCollection)
+
+	userObjId, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return false, &util.CustomError{Message: "Invalid User Id", Status: http.StatusBadRequest, Cause: err}
+	}
+
+	fields := bson.M{
+		"revision":  item.Revision,
+		"blob":      item.Blob,
+		"deleted":   item.Deleted,
+		"updatedAt": item.UpdatedAt,
+	}
+
+	if expectedRevision == 0 {
+		fields["userId"] = userObjId
+		fields["itemId"] = item.Id
+		filter := bson.M{"userId": userObjId, "itemId": item.Id}
+		result, err := itemsCollection.UpdateOne(ctx, filter, bson.M{"$setOnInsert": fields}, options.Update().SetUpsert(true))
+		if err != nil {
+			return false, &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
+		}
+		return result.UpsertedCount == 1, nil
+	}
+
+	filter := bson.M{"userId": userObjId, "itemId": item.Id, "revision": expectedRevision}
+	result, err := itemsCollection.UpdateOne(ctx, filter, bson.M{"$set": fields})
+	if err != nil {
+		return false, &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
+	}
+
+	return result.ModifiedCount == 1, nil
+}
@@ -0,0 +1,12 @@
+package entity
+
+import "time"
+
+// AttemptCounter tracks recent failures for one key, such as an account or
+// a client IP on a given endpoint.
+type AttemptCounter struct {
+	Key          string    `bson:"key"`
+	Failures     int       `bson:"failures"`
+	BlockedUntil time.Time `bson:"blockedUntil"`
+	ExpiresAt    time.Time `bson:"expireAt"`
+}
@@ -1,5 +1,7 @@
 package entity
 
+import "encoding/json"
+
 type AuthRequest struct {
 	Email    string `json:"email" binding:"required"`
 	Password string `json:"password" binding:"required"`
@@ -19,3 +21,49 @@ type ResetPasswordRequest struct {
 	OldPassword string `json:"oldPassword" binding:"required"`
 	NewPassword string `json:"newPassword" binding:"required"`
 }
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refreshToken" binding:"required"`
+}
+
+type EnrollTotpRequest struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code"`
+}
+
+type TotpCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type DisableTotpRequest struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+type SignInTotpRequest struct {
+	ChallengeToken string `json:"challengeToken" binding:"required"`
+	Code           string `json:"code" binding:"required"`
+}
+
+type SignInRecoveryRequest struct {
+	ChallengeToken string `json:"challengeToken" binding:"required"`
+	RecoveryCode   string `json:"recoveryCode" binding:"required"`
+}
+
+type PasswordRequest struct {
+	Password string `json:"password" binding:"required"`
+}
+
+type PasskeyBeginRequest struct {
+	ChallengeToken string `json:"challengeToken"`
+}
+
+type PasskeyFinishRequest struct {
+	CeremonyId string          `json:"ceremonyId" binding:"required"`
+	Name       string          `json:"name"`
+	Credential json.RawMessage `json:"credential" binding:"required"`
+}
+
+type RenamePasskeyRequest struct {
+	Name string `json:"name" binding:"required"`
+}
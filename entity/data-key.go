@@ -0,0 +1,11 @@
+package entity
+
+import "time"
+
+type DataKey struct {
+	Id          string    `bson:"keyId"`
+	UserId      string    `bson:"userId"`
+	WrappedKey  []byte    `bson:"wrappedKey"`
+	MasterKeyId string    `bson:"masterKeyId"`
+	CreatedAt   time.Time `bson:"createdAt"`
+}
@@ -0,0 +1,8 @@
+package entity
+
+// An OTP can only be used for the purpose it was requested for, so a code
+// verified for registration cannot reset a password.
+const (
+	OtpPurposeRegister = "register"
+	OtpPurposeReset    = "reset"
+)
@@ -0,0 +1,31 @@
+package entity
+
+import "time"
+
+// Passkey is a WebAuthn credential registered to a user. Id is the
+// base64url-encoded credential id chosen by the authenticator.
+type Passkey struct {
+	Id              string     `json:"id" bson:"credentialId"`
+	UserId          string     `json:"-" bson:"userId"`
+	Name            string     `json:"name" bson:"name"`
+	PublicKey       []byte     `json:"-" bson:"publicKey"`
+	AttestationType string     `json:"-" bson:"attestationType"`
+	Transports      []string   `json:"transports" bson:"transports"`
+	AAGUID          []byte     `json:"-" bson:"aaguid"`
+	SignCount       uint32     `json:"-" bson:"signCount"`
+	BackupEligible  bool       `json:"backupEligible" bson:"backupEligible"`
+	BackupState     bool       `json:"backupState" bson:"backupState"`
+	CreatedAt       time.Time  `json:"createdAt" bson:"createdAt"`
+	LastUsedAt      *time.Time `json:"lastUsedAt" bson:"lastUsedAt,omitempty"`
+}
+
+// WebAuthnCeremony keeps the server side of a registration or assertion
+// between its begin and finish requests. Data is the library's session
+// state, stored as JSON.
+type WebAuthnCeremony struct {
+	Id        string    `bson:"ceremonyId"`
+	UserId    string    `bson:"userId"`
+	Purpose   string    `bson:"purpose"`
+	Data      []byte    `bson:"data"`
+	ExpiresAt time.Time `bson:"expireAt"`
+}
@@ -0,0 +1,13 @@
+package entity
+
+import "time"
+
+// RateBucket is a token bucket for one rate-limit key. Tokens refill
+// continuously from UpdatedAt, so the bucket is only written when used.
+type RateBucket struct {
+	Key       string    `bson:"key"`
+	Tokens    float64   `bson:"tokens"`
+	Allowed   bool      `bson:"allowed"`
+	UpdatedAt time.Time `bson:"updatedAt"`
+	ExpiresAt time.Time `bson:"expireAt"`
+}
@@ -0,0 +1,20 @@
+package entity
+
+import "time"
+
+type RefreshToken struct {
+	TokenHash     string     `bson:"tokenHash"`
+	FamilyId      string     `bson:"familyId"`
+	UserId        string     `bson:"userId"`
+	PasswordSetAt string     `bson:"passwordSetAt"`
+	CreatedAt     time.Time  `bson:"createdAt"`
+	ExpiresAt     time.Time  `bson:"expireAt"`
+	UsedAt        *time.Time `bson:"usedAt"`
+	Revoked       bool       `bson:"revoked"`
+}
+
+type AuthTokens struct {
+	AccessToken      string
+	RefreshToken     string
+	RefreshExpiresAt time.Time
+}
@@ -0,0 +1,20 @@
+package entity
+
+import "time"
+
+type Session struct {
+	Id         string    `json:"id" bson:"sessionId"`
+	UserId     string    `json:"-" bson:"userId"`
+	UserAgent  string    `json:"userAgent" bson:"userAgent"`
+	IP         string    `json:"ip" bson:"ip"`
+	CreatedAt  time.Time `json:"createdAt" bson:"createdAt"`
+	LastSeenAt time.Time `json:"lastSeenAt" bson:"lastSeenAt"`
+	ExpiresAt  time.Time `json:"expiresAt" bson:"expireAt"`
+	Revoked    bool      `json:"-" bson:"revoked"`
+	Current    bool      `json:"current" bson:"-"`
+}
+
+type ClientInfo struct {
+	UserAgent string
+	IP        string
+}
@@ -9,8 +9,10 @@ type NewSiteRequest struct {
 	Notes    *string `json:"notes"`
 }
 
+// EditSiteRequest carries the site id in the body on the legacy /edit-site
+// route; /v1/sites/:id takes it from the path instead.
 type EditSiteRequest struct {
-	Id       string  `json:"id"  binding:"required"`
+	Id       string  `json:"id"`
 	URL      string  `json:"url"`
 	Name     string  `json:"name"`
 	Sector   string  `json:"sector"`
@@ -9,6 +9,7 @@ type Site struct {
 	Password string `json:"password" bson:"password"`
 	Notes    string `json:"notes" bson:"notes"`
 	Image    string `json:"image" bson:"image"`
+	KeyId    string `json:"-" bson:"keyId"`
 }
 
 func ConvertNewSiteToSite(newSite NewSiteRequest) Site {
@@ -0,0 +1,30 @@
+package entity
+
+import "time"
+
+// TotpSettings holds the user's authenticator secret, encrypted under one of
+// their data keys. A pending secret is kept apart until it is confirmed so
+// that re-enrolling does not disable the current authenticator.
+type TotpSettings struct {
+	Secret        string     `bson:"secret,omitempty"`
+	KeyId         string     `bson:"keyId,omitempty"`
+	Enabled       bool       `bson:"enabled"`
+	EnabledAt     *time.Time `bson:"enabledAt,omitempty"`
+	LastUsedStep  int64      `bson:"lastUsedStep"`
+	PendingSecret string     `bson:"pendingSecret,omitempty"`
+	PendingKeyId  string     `bson:"pendingKeyId,omitempty"`
+}
+
+type MfaChallenge struct {
+	Token     string
+	ExpiresAt time.Time
+	Methods   []string
+}
+
+// SignInResult carries either the issued tokens or, when a second factor is
+// required, the challenge to complete.
+type SignInResult struct {
+	Tokens    AuthTokens
+	Kdf       *KdfParams
+	Challenge *MfaChallenge
+}
@@ -0,0 +1,11 @@
+package entity
+
+type User struct {
+	Id            string        `bson:"_id"`
+	Email         string        `bson:"email"`
+	Password      string        `bson:"password"`
+	PasswordSetAt string        `bson:"passwordSetAt"`
+	Kdf           *KdfParams    `bson:"kdf,omitempty"`
+	Totp          *TotpSettings `bson:"totp,omitempty"`
+	RecoveryCodes []string      `bson:"recoveryCodes,omitempty"`
+}
@@ -0,0 +1,20 @@
+package entity
+
+import "time"
+
+type VaultItem struct {
+	Id        string    `json:"id" bson:"itemId"`
+	Revision  int64     `json:"revision" bson:"revision"`
+	Blob      string    `json:"blob" bson:"blob"`
+	Deleted   bool      `json:"deleted" bson:"deleted"`
+	UpdatedAt time.Time `json:"updatedAt" bson:"updatedAt"`
+}
+
+type KdfParams struct {
+	Algorithm   string `json:"algorithm" bson:"algorithm" binding:"required"`
+	Salt        string `json:"salt" bson:"salt" binding:"required"`
+	Iterations  uint32 `json:"iterations" bson:"iterations" binding:"required"`
+	Memory      uint32 `json:"memory,omitempty" bson:"memory,omitempty"`
+	Parallelism uint8  `json:"parallelism,omitempty" bson:"parallelism,omitempty"`
+	Revision    int64  `json:"revision" bson:"revision"`
+}
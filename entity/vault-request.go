@@ -0,0 +1,14 @@
+package entity
+
+type PutVaultItemRequest struct {
+	Revision *int64 `json:"revision" binding:"required"`
+	Blob     string `json:"blob" binding:"required"`
+}
+
+// SetKdfParamsRequest carries the revision the client last read, 0 when no
+// parameters are set yet. Replacing existing parameters also needs Password.
+type SetKdfParamsRequest struct {
+	KdfParams
+	Revision *int64 `json:"revision" binding:"required"`
+	Password string `json:"password"`
+}
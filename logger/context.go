@@ -0,0 +1,71 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	requestIdAttr = "request_id"
+	userIdAttr    = "user_id"
+	traceIdAttr   = "trace_id"
+	spanIdAttr    = "span_id"
+)
+
+type requestIdKey struct{}
+
+type userIdKey struct{}
+
+// WithRequestId returns a context whose log lines carry the request id.
+func WithRequestId(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIdKey{}, id)
+}
+
+// WithUserId returns a context whose log lines carry the authenticated user.
+func WithUserId(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, userIdKey{}, id)
+}
+
+func RequestId(ctx context.Context) string {
+	id, _ := ctx.Value(requestIdKey{}).(string)
+	return id
+}
+
+func UserId(ctx context.Context) string {
+	id, _ := ctx.Value(userIdKey{}).(string)
+	return id
+}
+
+// contextHandler adds the request and user ids and the current span on the
+// context to each record.
+type contextHandler struct {
+	handler slog.Handler
+}
+
+func (h *contextHandler) Enabled(ctx context.Context, l slog.Level) bool {
+	return h.handler.Enabled(ctx, l)
+}
+
+func (h *contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	record = record.Clone()
+	if id := RequestId(ctx); id != "" {
+		record.AddAttrs(slog.String(requestIdAttr, id))
+	}
+	if id := UserId(ctx); id != "" {
+		record.AddAttrs(slog.String(userIdAttr, id))
+	}
+	if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+		record.AddAttrs(slog.String(traceIdAttr, span.TraceID().String()), slog.String(spanIdAttr, span.SpanID().String()))
+	}
+	return h.handler.Handle(ctx, record)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{handler: h.handler.WithAttrs(attrs)}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{handler: h.handler.WithGroup(name)}
+}
@@ -1,16 +1,80 @@
 package logger
 
 import (
+	"context"
+	"fmt"
 	"log"
+	"log/slog"
 	"os"
+	"runtime"
+	"strings"
+	"time"
 )
 
+// InfoLogger and ErrorLogger write through the structured logger so that
+// call sites without a request context still produce JSON lines.
 var (
 	InfoLogger  *log.Logger
 	ErrorLogger *log.Logger
 )
 
+var level = new(slog.LevelVar)
+
+// Init installs a JSON logger on stdout as the slog default. Every record
+// passes through the redaction layer and carries the request and user ids
+// found on its context.
 func Init() {
-	InfoLogger = log.New(os.Stdout, "Password Manager: INFO ", log.Ldate|log.Ltime|log.Lshortfile)
-	ErrorLogger = log.New(os.Stdout, "Password Manager: ERROR ", log.Ldate|log.Ltime|log.Lshortfile)
+	handler := slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
+		AddSource:   true,
+		Level:       level,
+		ReplaceAttr: redactAttr,
+	})
+	logger := slog.New(&contextHandler{handler: handler})
+	slog.SetDefault(logger)
+
+	InfoLogger = slog.NewLogLogger(logger.Handler(), slog.LevelInfo)
+	ErrorLogger = slog.NewLogLogger(logger.Handler(), slog.LevelError)
+}
+
+// SetLevel changes the minimum level logged; it accepts debug, info, warn
+// and error.
+func SetLevel(name string) error {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(strings.ToUpper(name))); err != nil {
+		return fmt.Errorf("unknown log level %q", name)
+	}
+	level.Set(l)
+	return nil
+}
+
+func Debug(ctx context.Context, msg string, args ...any) {
+	write(ctx, slog.LevelDebug, msg, args...)
+}
+
+func Info(ctx context.Context, msg string, args ...any) {
+	write(ctx, slog.LevelInfo, msg, args...)
+}
+
+func Warn(ctx context.Context, msg string, args ...any) {
+	write(ctx, slog.LevelWarn, msg, args...)
+}
+
+func Error(ctx context.Context, msg string, args ...any) {
+	write(ctx, slog.LevelError, msg, args...)
+}
+
+// write records the caller of Debug, Info, Warn or Error as the source.
+func write(ctx context.Context, l slog.Level, msg string, args ...any) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	logger := slog.Default()
+	if !logger.Enabled(ctx, l) {
+		return
+	}
+	var pcs [1]uintptr
+	runtime.Callers(3, pcs[:])
+	record := slog.NewRecord(time.Now(), l, msg, pcs[0])
+	record.Add(args...)
+	_ = logger.Handler().Handle(ctx, record)
 }
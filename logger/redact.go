@@ -0,0 +1,95 @@
+package logger
+
+import (
+	"errors"
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+// sensitiveKeys are attribute names whose values are never logged. Keys are
+// compared lower-cased with underscores and dashes removed.
+var sensitiveKeys = map[string]bool{
+	"otp":          true,
+	"code":         true,
+	"totp":         true,
+	"recoverycode": true,
+	"cookie":       true,
+	"setcookie":    true,
+	"passkey":      true,
+}
+
+// sensitiveKeyParts mark any attribute name containing them as sensitive.
+var sensitiveKeyParts = []string{"password", "secret", "token", "authorization", "masterkey", "jwtkey", "otpkey"}
+
+var (
+	jwtPattern        = regexp.MustCompile(`eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)
+	bearerPattern     = regexp.MustCompile(`(?i)\bbearer\s+\S+`)
+	assignmentPattern = regexp.MustCompile(`(?i)("?\b(?:[a-z_]*password|otp|code|totp|recovery_?code|[a-z_]*token|secret)"?\s*[:=]\s*)("[^"]*"|[^\s,&}]+)`)
+	opaquePattern     = regexp.MustCompile(`[A-Za-z0-9_-]{32,}`)
+	uuidPattern       = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+)
+
+// redactAttr masks sensitive attributes and scrubs credentials out of the
+// message and any string or error value.
+func redactAttr(groups []string, attr slog.Attr) slog.Attr {
+	if len(groups) == 0 {
+		switch attr.Key {
+		case slog.TimeKey, slog.LevelKey, slog.SourceKey, requestIdAttr, userIdAttr, traceIdAttr, spanIdAttr:
+			return attr
+		}
+	}
+	if sensitiveKey(attr.Key) {
+		return slog.String(attr.Key, redacted)
+	}
+	switch attr.Value.Kind() {
+	case slog.KindString:
+		return slog.String(attr.Key, redactText(attr.Value.String()))
+	case slog.KindAny:
+		if err, ok := attr.Value.Any().(error); ok {
+			return slog.String(attr.Key, redactText(errorText(err)))
+		}
+	}
+	return attr
+}
+
+func sensitiveKey(key string) bool {
+	key = strings.NewReplacer("_", "", "-", "").Replace(strings.ToLower(key))
+	if sensitiveKeys[key] {
+		return true
+	}
+	for _, part := range sensitiveKeyParts {
+		if strings.Contains(key, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactText removes bearer tokens, JWTs, key=value credentials and long
+// opaque strings such as refresh tokens from free text. UUIDs are kept.
+func redactText(text string) string {
+	text = jwtPattern.ReplaceAllString(text, redacted)
+	text = bearerPattern.ReplaceAllString(text, "Bearer "+redacted)
+	text = assignmentPattern.ReplaceAllString(text, "${1}"+redacted)
+	return opaquePattern.ReplaceAllStringFunc(text, func(match string) string {
+		if uuidPattern.MatchString(match) {
+			return match
+		}
+		return redacted
+	})
+}
+
+// errorText spells out the chain of causes behind err. Errors that already
+// quote their cause, as fmt.Errorf with %w does, are not repeated.
+func errorText(err error) string {
+	text := err.Error()
+	if cause := errors.Unwrap(err); cause != nil {
+		if causeText := errorText(cause); !strings.Contains(text, causeText) {
+			text += ": " + causeText
+		}
+	}
+	return text
+}
@@ -1,45 +1,78 @@
 package main
 
 import (
-	"password-manager/controller"
+	"context"
+	"errors"
+	"net/http"
+	"os/signal"
+	"password-manager/config"
+	"password-manager/db"
 	"password-manager/logger"
-	"password-manager/middleware"
-	"password-manager/service"
-
-	"github.com/gin-contrib/cors"
-	"github.com/gin-gonic/gin"
+	"password-manager/router"
+	"password-manager/tracing"
+	"strconv"
+	"syscall"
 )
 
 func main() {
 	logger.Init()
 
-	gin.SetMode(gin.ReleaseMode)
-	server := gin.Default()
-
-	config := cors.DefaultConfig()
-	config.AllowOrigins = []string{"https://react-password-manager.vercel.app", "http://localhost:3000"}
-	config.AllowMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
-	config.AllowHeaders = []string{"Authorization", "Content-Type"}
-	config.AllowCredentials = true
-	config.ExposeHeaders = []string{"Authorization", "Set-Cookie"}
-	server.Use(cors.New(config))
-
-	authController := controller.NewAuthController(service.NewAuthService())
-	siteController := controller.NewSiteController(service.NewSiteService())
-
-	server.POST("/generate-otp", authController.GenerateOtp)
-	server.POST("/verify-otp", authController.VerifyOtp)
-	server.POST("/sign-up", authController.SignUp)
-	server.POST("/sign-in", authController.SignIn)
-	server.PUT("/forgot-password", authController.ForgotPassword)
-	server.PUT("/reset-password", middleware.TokenAuthMiddleware(), authController.ResetPassword)
-	server.GET("/sign-out", middleware.TokenAuthMiddleware(), authController.SignOut)
-	server.GET("/check-token", middleware.TokenAuthMiddleware(), authController.CheckToken)
-
-	server.POST("/save-site", middleware.TokenAuthMiddleware(), siteController.SaveSite)
-	server.GET("/get-sites", middleware.TokenAuthMiddleware(), siteController.GetSites)
-	server.PATCH("/edit-site", middleware.TokenAuthMiddleware(), siteController.EditSite)
-	server.DELETE("/delete-site", middleware.TokenAuthMiddleware(), siteController.DeleteSite)
-
-	server.Run(":8080")
+	settings, err := config.Load()
+	if err != nil {
+		logger.ErrorLogger.Fatalln(err.Error())
+	}
+	logger.SetLevel(settings.Log.Level)
+	logger.InfoLogger.Println("Effective configuration:\n" + settings.String())
+
+	shutdownTracing, err := tracing.Init(router.TracingConfig(settings))
+	if err != nil {
+		logger.ErrorLogger.Fatalln(err.Error())
+	}
+
+	routerConfig, err := router.LoadConfig(settings)
+	if err != nil {
+		logger.ErrorLogger.Fatalln(err.Error())
+	}
+
+	server := &http.Server{
+		Addr:         ":" + strconv.Itoa(settings.Server.Port),
+		Handler:      router.New(routerConfig),
+		ReadTimeout:  settings.Server.ReadTimeout,
+		WriteTimeout: settings.Server.WriteTimeout,
+		IdleTimeout:  settings.Server.IdleTimeout,
+	}
+
+	stop, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	serveErr := make(chan error, 1)
+	go func() {
+		logger.InfoLogger.Println("Listening on " + server.Addr)
+		serveErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err = <-serveErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			logger.ErrorLogger.Println(err.Error())
+		}
+	case <-stop.Done():
+		logger.InfoLogger.Println("Shutting down, draining in-flight requests")
+	}
+	cancel()
+
+	// Shutdown stops accepting connections and waits for in-flight requests;
+	// the database and exporter are closed once nothing can use them.
+	ctx, cancelShutdown := context.WithTimeout(context.Background(), settings.Server.ShutdownTimeout)
+	defer cancelShutdown()
+	if err := server.Shutdown(ctx); err != nil {
+		logger.ErrorLogger.Println("Server shutdown: " + err.Error())
+	}
+	if err := db.Disconnect(ctx); err != nil {
+		logger.ErrorLogger.Println("Database disconnect: " + err.Error())
+	}
+	if err := shutdownTracing(ctx); err != nil {
+		logger.ErrorLogger.Println("Tracing shutdown: " + err.Error())
+	}
+	logger.InfoLogger.Println("Server stopped")
 }
@@ -0,0 +1,78 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// The collectors start out on a private registry so packages can record
+// before Init is called, for example in tools that never serve /metrics.
+var (
+	HTTPRequests        *prometheus.CounterVec
+	HTTPRequestDuration *prometheus.HistogramVec
+	SignIns             *prometheus.CounterVec
+	OtpSends            *prometheus.CounterVec
+	OtpVerifications    *prometheus.CounterVec
+	BlacklistHits       prometheus.Counter
+	DbOperationDuration *prometheus.HistogramVec
+	DbOperationErrors   *prometheus.CounterVec
+)
+
+func init() {
+	Init(prometheus.NewRegistry())
+}
+
+// Init creates the collectors and registers them with registerer. Tests pass
+// their own registry to assert on the values recorded.
+func Init(registerer prometheus.Registerer) {
+	HTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "HTTP requests by method, route and status.",
+	}, []string{"method", "route", "status"})
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "HTTP request latency by method, route and status.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+	SignIns = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "auth_sign_ins_total",
+		Help: "Sign-in attempts by method (password, totp, recovery_code, passkey) and result (success, challenge, failure).",
+	}, []string{"method", "result"})
+	OtpSends = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "auth_otp_sends_total",
+		Help: "OTP emails by purpose and result.",
+	}, []string{"purpose", "result"})
+	OtpVerifications = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "auth_otp_verifications_total",
+		Help: "OTP verifications by result.",
+	}, []string{"result"})
+	BlacklistHits = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "auth_token_blacklist_hits_total",
+		Help: "Requests rejected because their access token was blacklisted.",
+	})
+	DbOperationDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "db_operation_duration_seconds",
+		Help:    "Mongo store operation latency by operation.",
+		Buckets: []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"operation"})
+	DbOperationErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "db_operation_errors_total",
+		Help: "Mongo store operations that failed, by operation.",
+	}, []string{"operation"})
+
+	registerer.MustRegister(
+		HTTPRequests,
+		HTTPRequestDuration,
+		SignIns,
+		OtpSends,
+		OtpVerifications,
+		BlacklistHits,
+		DbOperationDuration,
+		DbOperationErrors,
+	)
+}
+
+// Result labels a counter by whether err is nil.
+func Result(err error) string {
+	if err != nil {
+		return "failure"
+	}
+	return "success"
+}
@@ -5,6 +5,8 @@ import (
 	"net/http"
 	"password-manager/db"
 	"password-manager/logger"
+	"password-manager/metrics"
+	"password-manager/signing"
 	"password-manager/util"
 	"reflect"
 	"strings"
@@ -15,14 +17,16 @@ import (
 	"github.com/golang-jwt/jwt"
 )
 
-func TokenAuthMiddleware() gin.HandlerFunc {
+const sessionTouchInterval = time.Minute
+
+func TokenAuthMiddleware(users db.UserStore, blacklist db.TokenBlacklist, sessions db.SessionStore, keys *signing.KeySet) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		tokenString, err := processAuthHeader(c)
 		if err != nil {
 			return
 		}
 
-		token, err := parseToken(c, tokenString)
+		token, err := parseToken(c, keys, tokenString)
 		if err != nil {
 			return
 		}
@@ -38,14 +42,22 @@ func TokenAuthMiddleware() gin.HandlerFunc {
 		var err1 *util.CustomError
 		go func() {
 			defer wg.Done()
-			err1 = checkBlacklisted(c, tokenString)
+			err1 = checkBlacklisted(c, blacklist, tokenString)
 		}()
 
 		wg.Add(1)
 		var err2 *util.CustomError
 		go func() {
 			defer wg.Done()
-			err2 = checkPasswordTimestamp(c, claims["id"].(string), claims["passwordSetAt"].(string))
+			err2 = checkPasswordTimestamp(c, users, claims["id"].(string), claims["passwordSetAt"].(string))
+		}()
+
+		sessionId, _ := claims["sid"].(string)
+		wg.Add(1)
+		var err3 *util.CustomError
+		go func() {
+			defer wg.Done()
+			err3 = checkSession(c, sessions, claims["id"].(string), sessionId)
 		}()
 
 		wg.Wait()
@@ -74,10 +86,26 @@ func TokenAuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		if err3 != nil {
+			response := gin.H{
+				"status":  err3.Status,
+				"message": err3.Message}
+			if err3.Message == "Session is revoked" {
+				response["sessionTimedOut"] = true
+			}
+			c.JSON(err3.Status, response)
+			c.Abort()
+			return
+		}
+
 		expirationTime := time.Unix(int64(claims["exp"].(float64)), 0)
 		c.Set("token", tokenString)
 		c.Set("userId", claims["id"])
+		c.Request = c.Request.WithContext(logger.WithUserId(c.Request.Context(), claims["id"].(string)))
 		c.Set("expirationTime", expirationTime)
+		if sessionId != "" {
+			c.Set("sessionId", sessionId)
+		}
 		c.Next()
 	}
 }
@@ -86,8 +114,7 @@ func processAuthHeader(c *gin.Context) (token string, err error) {
 	authHeader := c.GetHeader("Authorization")
 	if authHeader == "" {
 		message := "Authorization header is missing"
-		logger.ErrorLogger.Println(message)
-		logger.InfoLogger.Println(message)
+		logger.Warn(c, message)
 		c.JSON(http.StatusUnauthorized, gin.H{
 			"status":  http.StatusUnauthorized,
 			"message": message,
@@ -99,8 +126,7 @@ func processAuthHeader(c *gin.Context) (token string, err error) {
 	authHeaderParts := strings.Split(authHeader, " ")
 	if len(authHeaderParts) != 2 || authHeaderParts[0] != "Bearer" {
 		message := "Invalid or missing Bearer token"
-		logger.ErrorLogger.Println(message)
-		logger.InfoLogger.Println(message)
+		logger.Warn(c, message)
 		c.JSON(http.StatusUnauthorized, gin.H{
 			"status":  http.StatusUnauthorized,
 			"message": message,
@@ -112,15 +138,12 @@ func processAuthHeader(c *gin.Context) (token string, err error) {
 	return authHeaderParts[1], nil
 }
 
-func parseToken(c *gin.Context, tokenString string) (token *jwt.Token, err error) {
-	token, err = jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		return []byte("secret"), nil
-	})
+func parseToken(c *gin.Context, keys *signing.KeySet, tokenString string) (token *jwt.Token, err error) {
+	token, err = jwt.Parse(tokenString, keys.Keyfunc)
 
 	if err != nil {
 		message := err.Error()
-		logger.ErrorLogger.Println(message)
-		logger.InfoLogger.Println(message)
+		logger.Warn(c, message)
 		if ve, ok := err.(*jwt.ValidationError); ok {
 			if ve.Errors&jwt.ValidationErrorExpired != 0 {
 				c.JSON(http.StatusUnauthorized, gin.H{
@@ -144,11 +167,11 @@ func parseToken(c *gin.Context, tokenString string) (token *jwt.Token, err error
 
 func checkClaims(c *gin.Context, token *jwt.Token) (claims jwt.MapClaims, err error) {
 	claims, ok := token.Claims.(jwt.MapClaims)
-	if ok && token.Valid {
+	// Tokens with a purpose, such as sign-in challenges, are not access tokens.
+	if ok && token.Valid && claims["purpose"] == nil {
 		if claims["id"] == nil || claims["passwordSetAt"] == nil || claims["exp"] == nil {
 			message := "Invalid token. Required claims not found."
-			logger.ErrorLogger.Println(message)
-			logger.InfoLogger.Println(message)
+			logger.Warn(c, message)
 			c.JSON(http.StatusBadRequest, gin.H{
 				"status":  http.StatusBadRequest,
 				"message": message,
@@ -159,8 +182,7 @@ func checkClaims(c *gin.Context, token *jwt.Token) (claims jwt.MapClaims, err er
 
 		if !(reflect.TypeOf(claims["id"]).Kind() == reflect.String) && (reflect.TypeOf(claims["passwordSetAt"]).Kind() == reflect.String) && (reflect.TypeOf(claims["exp"]).Kind() == reflect.Float64) {
 			message := "Invalid token. Required claims types invalid."
-			logger.ErrorLogger.Println(message)
-			logger.InfoLogger.Println(message)
+			logger.Warn(c, message)
 			c.JSON(http.StatusBadRequest, gin.H{
 				"status":  http.StatusBadRequest,
 				"message": message,
@@ -170,8 +192,7 @@ func checkClaims(c *gin.Context, token *jwt.Token) (claims jwt.MapClaims, err er
 		}
 	} else {
 		message := "Token is invalid"
-		logger.ErrorLogger.Println(message)
-		logger.InfoLogger.Println(message)
+		logger.Warn(c, message)
 		c.JSON(http.StatusUnauthorized, gin.H{
 			"status":  http.StatusUnauthorized,
 			"message": message,
@@ -182,33 +203,27 @@ func checkClaims(c *gin.Context, token *jwt.Token) (claims jwt.MapClaims, err er
 	return claims, nil
 }
 
-func checkBlacklisted(c *gin.Context, tokenString string) (err *util.CustomError) {
-	blacklisted, er := db.CheckBlacklist(tokenString)
+func checkBlacklisted(c *gin.Context, blacklist db.TokenBlacklist, tokenString string) (err *util.CustomError) {
+	blacklisted, er := blacklist.CheckBlacklist(c.Request.Context(), tokenString)
 
 	if er != nil {
-		message := "Internal Server Error"
-		logger.ErrorLogger.Println(er.Error())
-		logger.InfoLogger.Println(message)
-		return &util.CustomError{Status: http.StatusInternalServerError, Message: message}
+		return storeError(c, er)
 	}
 
 	if blacklisted {
+		metrics.BlacklistHits.Inc()
 		message := "Token is blacklisted"
-		logger.ErrorLogger.Println(message)
-		logger.InfoLogger.Println(message)
+		logger.Warn(c, message)
 		return &util.CustomError{Status: http.StatusUnauthorized, Message: message}
 	}
 	return nil
 }
 
-func checkPasswordTimestamp(c *gin.Context, id string, passwordSetAt string) (err *util.CustomError) {
-	passwordTime, er := db.CheckPasswordReset(id)
+func checkPasswordTimestamp(c *gin.Context, users db.UserStore, id string, passwordSetAt string) (err *util.CustomError) {
+	passwordTime, er := users.CheckPasswordReset(c.Request.Context(), id)
 
 	if er != nil {
-		message := "Internal Server Error"
-		logger.ErrorLogger.Println(er.Error())
-		logger.InfoLogger.Println(message)
-		return &util.CustomError{Status: http.StatusInternalServerError, Message: message}
+		return storeError(c, er)
 	}
 
 	tokenPasswordTime := passwordSetAt
@@ -216,10 +231,57 @@ func checkPasswordTimestamp(c *gin.Context, id string, passwordSetAt string) (er
 
 	if tokenPasswordTime != formattedPasswordTime {
 		message := "Token is blacklisted"
-		logger.ErrorLogger.Println(message)
-		logger.InfoLogger.Println(message)
+		logger.Warn(c, message)
+		return &util.CustomError{Status: http.StatusUnauthorized, Message: message}
+	}
+
+	return nil
+}
+
+// checkSession rejects tokens whose session has been revoked and records
+// activity on the session, at most once per sessionTouchInterval. Tokens
+// issued before sessions existed carry no session id and are not checked.
+func checkSession(c *gin.Context, sessions db.SessionStore, id string, sessionId string) (err *util.CustomError) {
+	if sessionId == "" {
+		return nil
+	}
+
+	session, found, er := sessions.GetSession(c.Request.Context(), sessionId)
+	if er != nil {
+		return storeError(c, er)
+	}
+
+	if !found || session.Revoked || session.UserId != id {
+		message := "Session is revoked"
+		logger.Warn(c, message)
 		return &util.CustomError{Status: http.StatusUnauthorized, Message: message}
 	}
 
+	now := time.Now().UTC()
+	if now.Sub(session.LastSeenAt) >= sessionTouchInterval || session.IP != c.ClientIP() {
+		if er = sessions.TouchSession(c.Request.Context(), sessionId, now, c.ClientIP()); er != nil {
+			logger.Error(c, "could not touch session", "error", er)
+		}
+	}
+
 	return nil
 }
+
+// storeError keeps the status of a failed store lookup, so a lookup that
+// timed out or was cancelled is reported as such rather than as a 500.
+func storeError(c *gin.Context, er error) *util.CustomError {
+	customErr, ok := er.(*util.CustomError)
+	if !ok {
+		customErr = &util.CustomError{Status: http.StatusInternalServerError, Message: "Internal Server Error"}
+	}
+	var args []any
+	if cause := errors.Unwrap(er); cause != nil {
+		args = append(args, "error", cause)
+	}
+	if customErr.Status >= http.StatusInternalServerError {
+		logger.Error(c, customErr.Message, args...)
+	} else {
+		logger.Warn(c, customErr.Message, args...)
+	}
+	return customErr
+}
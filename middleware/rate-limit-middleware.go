@@ -0,0 +1,100 @@
+package middleware
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"password-manager/db"
+	"password-manager/logger"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RateKeyFunc picks the bucket a request is counted against.
+type RateKeyFunc func(c *gin.Context) string
+
+// RateLimitPolicy is a token bucket: bursts of up to Capacity requests, then
+// one request per Refill. Name keeps the buckets of different policies apart.
+type RateLimitPolicy struct {
+	Name     string
+	Capacity int
+	Refill   time.Duration
+	Key      RateKeyFunc
+}
+
+func ByIP(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
+}
+
+// ByUser must run after TokenAuthMiddleware; unauthenticated requests fall
+// back to the client IP.
+func ByUser(c *gin.Context) string {
+	if userId, ok := c.Get("userId"); ok {
+		return "user:" + fmt.Sprint(userId)
+	}
+	return ByIP(c)
+}
+
+// Shared is the route key: a policy applied to a single route puts every
+// caller of that route in one bucket, and the policy name keeps it apart
+// from other routes. Unlike keying by path, the route served under /v1 and
+// at its legacy path then has a single cap.
+func Shared(c *gin.Context) string {
+	return "shared"
+}
+
+var (
+	GlobalRateLimit = RateLimitPolicy{Name: "global", Capacity: 100, Refill: time.Millisecond * 200, Key: ByIP}
+	// CredentialRateLimit covers endpoints that check a password or code.
+	CredentialRateLimit = RateLimitPolicy{Name: "credentials", Capacity: 10, Refill: time.Second * 6, Key: ByIP}
+	UserRateLimit       = RateLimitPolicy{Name: "user", Capacity: 120, Refill: time.Millisecond * 500, Key: ByUser}
+	// Every OTP request sends an email, so these are kept tight per client
+	// and also capped overall to protect the mail quota.
+	OtpRateLimit      = RateLimitPolicy{Name: "otp", Capacity: 3, Refill: time.Minute * 5, Key: ByIP}
+	OtpRouteRateLimit = RateLimitPolicy{Name: "otp-route", Capacity: 100, Refill: time.Second * 36, Key: Shared}
+)
+
+// RateLimitMiddleware enforces policy and reports the bucket through the
+// RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset headers. When
+// several policies apply to a route the headers describe whichever bucket
+// has the fewest requests left, since that is the one a client will hit. If
+// the store fails the request is let through rather than taking the API
+// down.
+func RateLimitMiddleware(store db.RateLimitStore, policy RateLimitPolicy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		bucket, err := store.TakeToken(c.Request.Context(), policy.Name+":"+policy.Key(c), policy.Capacity, policy.Refill, time.Now())
+		if err != nil {
+			logger.Error(c, "rate limit store unavailable", "error", err)
+			c.Next()
+			return
+		}
+
+		remaining := int(bucket.Tokens)
+		if tightest, err := strconv.Atoi(c.Writer.Header().Get("RateLimit-Remaining")); err != nil || remaining < tightest {
+			full := time.Duration((float64(policy.Capacity) - bucket.Tokens) * float64(policy.Refill))
+			c.Header("RateLimit-Limit", strconv.Itoa(policy.Capacity))
+			c.Header("RateLimit-Remaining", strconv.Itoa(remaining))
+			c.Header("RateLimit-Reset", strconv.Itoa(seconds(full)))
+		}
+
+		if !bucket.Allowed {
+			wait := time.Duration((1 - bucket.Tokens) * float64(policy.Refill))
+			c.Header("Retry-After", strconv.Itoa(seconds(wait)))
+			logger.Warn(c, "rate limited", "policy", policy.Name, "ip", c.ClientIP())
+			c.JSON(http.StatusTooManyRequests, gin.H{
+				"status":  http.StatusTooManyRequests,
+				"message": "Too many requests, try again later",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func seconds(duration time.Duration) int {
+	return int(math.Ceil(duration.Seconds()))
+}
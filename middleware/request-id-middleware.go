@@ -0,0 +1,83 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"fmt"
+	"net/http"
+	"password-manager/logger"
+	"regexp"
+	"runtime/debug"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const RequestIdHeader = "X-Request-ID"
+
+// Incoming ids are only trusted when they are short and log-safe.
+var requestIdPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// RequestIdMiddleware tags the request with the caller's X-Request-ID, or a
+// new one, echoes it in the response and writes an access log line once the
+// request completes. Every log line written with the request context carries
+// the id.
+func RequestIdMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		requestId := c.GetHeader(RequestIdHeader)
+		if !requestIdPattern.MatchString(requestId) {
+			requestId = newRequestId()
+		}
+		c.Header(RequestIdHeader, requestId)
+		c.Set("requestId", requestId)
+		c.Request = c.Request.WithContext(logger.WithRequestId(c.Request.Context(), requestId))
+
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = c.Request.URL.Path
+		}
+		args := []any{
+			"method", c.Request.Method,
+			"route", route,
+			"status", c.Writer.Status(),
+			"duration_ms", time.Since(start).Milliseconds(),
+			"ip", c.ClientIP(),
+		}
+		if len(c.Errors) > 0 {
+			args = append(args, "error", c.Errors.String())
+		}
+		switch status := c.Writer.Status(); {
+		case status >= http.StatusInternalServerError:
+			logger.Error(c, "request completed", args...)
+		case status >= http.StatusBadRequest:
+			logger.Warn(c, "request completed", args...)
+		default:
+			logger.Info(c, "request completed", args...)
+		}
+	}
+}
+
+// RecoveryMiddleware turns a panic into a 500 and logs it with the request id.
+func RecoveryMiddleware() gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(nil, func(c *gin.Context, recovered any) {
+		logger.Error(c, "panic recovered", "panic", fmt.Sprint(recovered), "stack", string(debug.Stack()))
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"status":  http.StatusInternalServerError,
+			"message": "Internal Server Error",
+		})
+	})
+}
+
+// newRequestId returns a random version 4 UUID.
+func newRequestId() string {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	id[6] = id[6]&0x0f | 0x40
+	id[8] = id[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", id[0:4], id[4:6], id[6:8], id[8:10], id[10:])
+}
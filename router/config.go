@@ -0,0 +1,146 @@
+package router
+
+import (
+	"context"
+	"password-manager/config"
+	"password-manager/db"
+	"password-manager/logger"
+	"password-manager/metrics"
+	"password-manager/service"
+	"password-manager/signing"
+	"password-manager/tracing"
+	"password-manager/util"
+	"password-manager/vault"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+// Config holds everything New needs to build the engine.
+type Config struct {
+	// ServiceName names the server in the spans of incoming requests.
+	ServiceName  string
+	AllowOrigins []string
+	// Middleware runs on every route after CORS and before rate limiting.
+	Middleware   []gin.HandlerFunc
+	Stores       db.Stores
+	SiteVault    vault.Vault
+	Keys         *signing.KeySet
+	RelyingParty *webauthn.WebAuthn
+	Auth         service.AuthSettings
+	// RequestTimeout is the deadline put on every request's context.
+	RequestTimeout time.Duration
+	// ImageLookupTimeout bounds fetching a saved site's logo.
+	ImageLookupTimeout time.Duration
+	// Metrics is served on /metrics; nil leaves the endpoint out.
+	Metrics prometheus.Gatherer
+}
+
+func MongoConfig(settings config.Config) db.MongoConfig {
+	return db.MongoConfig{
+		URI:                    settings.Mongo.URI,
+		Database:               settings.Mongo.Database,
+		MinPoolSize:            settings.Mongo.MinPoolSize,
+		MaxPoolSize:            settings.Mongo.MaxPoolSize,
+		MaxConnIdleTime:        settings.Mongo.MaxConnIdleTime,
+		ConnectTimeout:         settings.Mongo.ConnectTimeout,
+		ServerSelectionTimeout: settings.Mongo.ServerSelectionTimeout,
+		OperationTimeout:       settings.Timeouts.Database,
+	}
+}
+
+func TracingConfig(settings config.Config) tracing.Config {
+	return tracing.Config{
+		Exporter:    settings.Tracing.Exporter,
+		Endpoint:    settings.Tracing.Endpoint,
+		ServiceName: settings.Tracing.ServiceName,
+		SampleRatio: settings.Tracing.SampleRatio,
+	}
+}
+
+// LoadConfig opens the stores, applies pending migrations unless disabled,
+// checks the indexes the stores rely on are in place, parses the configured
+// keys and sets up the metrics registry. The in-memory backend falls back to
+// ephemeral keys.
+func LoadConfig(settings config.Config) (Config, error) {
+	backend := settings.Storage.Backend
+	stores, err := db.NewStores(backend, MongoConfig(settings))
+	if err != nil {
+		return Config{}, err
+	}
+	if settings.Storage.AutoMigrate {
+		if _, err = db.RunMigrations(context.Background(), backend, MongoConfig(settings)); err != nil {
+			return Config{}, err
+		}
+	}
+	if err = db.CheckSchema(context.Background(), backend, MongoConfig(settings)); err != nil {
+		return Config{}, err
+	}
+
+	keyRing, err := vault.ParseKeyRing(settings.Keys.MasterKeys, settings.Keys.MasterKeyId)
+	if err == vault.ErrNoMasterKey && backend == "memory" {
+		logger.InfoLogger.Println("No master key configured, using an ephemeral key for the in-memory store")
+		keyRing, err = vault.NewEphemeralKeyRing()
+	}
+	if err != nil {
+		return Config{}, err
+	}
+
+	keys, err := signing.ParseKeySet(settings.Keys.JwtKeys, settings.Keys.JwtKeyId)
+	if err == signing.ErrNoSigningKey && backend == "memory" {
+		logger.InfoLogger.Println("No signing key configured, using an ephemeral key for the in-memory store")
+		keys, err = signing.NewEphemeralKeySet()
+	}
+	if err != nil {
+		return Config{}, err
+	}
+
+	otpKey, err := service.ParseOtpKey(settings.Keys.OtpKey)
+	if err == service.ErrNoOtpKey && backend == "memory" {
+		logger.InfoLogger.Println("No OTP key configured, using an ephemeral key for the in-memory store")
+		otpKey, err = service.NewEphemeralOtpKey()
+	}
+	if err != nil {
+		return Config{}, err
+	}
+
+	relyingParty, err := service.NewRelyingParty(settings.WebAuthn.RPID, settings.WebAuthn.RPName, settings.WebAuthn.RPOrigins)
+	if err != nil {
+		return Config{}, err
+	}
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(collectors.NewGoCollector(), collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+	metrics.Init(registry)
+
+	return Config{
+		ServiceName:  settings.Tracing.ServiceName,
+		AllowOrigins: settings.Server.AllowOrigins,
+		Stores:       stores,
+		SiteVault:    vault.NewVault(keyRing, stores.DataKeys),
+		Keys:         keys,
+		RelyingParty: relyingParty,
+		Auth: service.AuthSettings{
+			Lifetimes: service.TokenLifetimes{
+				AccessToken:  settings.Auth.AccessTokenTTL,
+				RefreshToken: settings.Auth.RefreshTokenTTL,
+			},
+			OtpKey:      otpKey,
+			OtpLifetime: settings.Auth.OtpTTL,
+			TotpIssuer:  settings.Auth.TotpIssuer,
+			Mail: util.MailConfig{
+				Host:     settings.Mail.Host,
+				Port:     settings.Mail.Port,
+				Username: settings.Mail.Username,
+				Password: settings.Mail.Password,
+				Timeout:  settings.Timeouts.Mail,
+			},
+		},
+		Metrics:            registry,
+		RequestTimeout:     settings.Timeouts.Request,
+		ImageLookupTimeout: settings.Timeouts.ImageLookup,
+	}, nil
+}
@@ -0,0 +1,76 @@
+package router
+
+import (
+	"password-manager/controller"
+	"password-manager/middleware"
+	"password-manager/service"
+
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+)
+
+// New builds the engine with every route registered.
+func New(config Config) *gin.Engine {
+	gin.SetMode(gin.ReleaseMode)
+	server := gin.New()
+	// Lets handlers pass the gin context to the logger and have the request
+	// and user ids picked up from the request context.
+	server.ContextWithFallback = true
+	server.Use(otelgin.Middleware(config.ServiceName), middleware.RequestIdMiddleware(), middleware.RecoveryMiddleware(), middleware.MetricsMiddleware(), middleware.TimeoutMiddleware(config.RequestTimeout))
+
+	corsConfig := cors.DefaultConfig()
+	corsConfig.AllowOrigins = config.AllowOrigins
+	corsConfig.AllowMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
+	corsConfig.AllowHeaders = []string{"Authorization", "Content-Type", middleware.RequestIdHeader, "traceparent", "tracestate", "baggage"}
+	corsConfig.AllowCredentials = true
+	corsConfig.ExposeHeaders = []string{"Authorization", "Set-Cookie", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "Retry-After", "Deprecation", "Link", middleware.RequestIdHeader}
+	server.Use(cors.New(corsConfig))
+	server.Use(config.Middleware...)
+
+	stores := config.Stores
+
+	// Scrapes and probes come from one address, so they are kept out of the
+	// rate limit.
+	if config.Metrics != nil {
+		server.GET("/metrics", gin.WrapH(promhttp.HandlerFor(config.Metrics, promhttp.HandlerOpts{})))
+	}
+	healthController := controller.NewHealthController(stores.Health, config.Auth.Mail, config.Keys)
+	server.GET("/healthz", healthController.Healthz)
+	server.GET("/readyz", healthController.Readyz)
+
+	server.Use(middleware.RateLimitMiddleware(stores.RateLimits, middleware.GlobalRateLimit))
+
+	routes := routes{
+		auth:       middleware.TokenAuthMiddleware(stores.Users, stores.Blacklist, stores.Sessions, config.Keys),
+		credential: middleware.RateLimitMiddleware(stores.RateLimits, middleware.CredentialRateLimit),
+		otp:        middleware.RateLimitMiddleware(stores.RateLimits, middleware.OtpRateLimit),
+		otpRoute:   middleware.RateLimitMiddleware(stores.RateLimits, middleware.OtpRouteRateLimit),
+		user:       middleware.RateLimitMiddleware(stores.RateLimits, middleware.UserRateLimit),
+	}
+
+	authController := controller.NewAuthController(service.NewAuthService(stores.Users, stores.Otps, stores.Blacklist, stores.Refresh, stores.Sessions, stores.Passkeys, stores.Attempts, config.SiteVault, config.RelyingParty, config.Keys, config.Auth))
+	siteController := controller.NewSiteController(service.NewSiteService(stores.Sites, config.SiteVault, config.ImageLookupTimeout))
+	vaultController := controller.NewVaultController(service.NewVaultService(stores.Users, stores.Items, stores.Attempts))
+	sessionController := controller.NewSessionController(service.NewSessionService(stores.Sessions, stores.Refresh))
+	passkeyController := controller.NewPasskeyController(service.NewPasskeyService(stores.Users, stores.Passkeys, stores.Attempts, config.RelyingParty))
+	keyController := controller.NewKeyController(config.Keys)
+
+	server.GET("/.well-known/jwks.json", keyController.JWKS)
+
+	v1 := server.Group("/v1")
+	routes.registerAuth(v1.Group("/auth"), authController)
+	routes.registerTwoFactor(v1.Group("/auth", routes.auth, routes.user), authController)
+	routes.registerSites(v1.Group("/sites", routes.auth, routes.user), siteController)
+
+	// The unversioned auth and site routes stay until clients move to /v1.
+	routes.registerLegacyAuth(server.Group(""), authController)
+	routes.registerLegacySites(server.Group("", middleware.DeprecationMiddleware("/v1/sites"), routes.auth, routes.user), siteController)
+
+	routes.registerPasskeys(server.Group("/passkeys", routes.auth, routes.user), passkeyController)
+	routes.registerSessions(server.Group("/sessions", routes.auth, routes.user), sessionController)
+	routes.registerVault(server.Group("/vault", routes.auth, routes.user), vaultController)
+
+	return server
+}
@@ -0,0 +1,290 @@
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"password-manager/db"
+	"password-manager/entity"
+	"password-manager/logger"
+	"password-manager/metrics"
+	"password-manager/middleware"
+	"password-manager/service"
+	"password-manager/signing"
+	"password-manager/util"
+	"password-manager/vault"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMain(m *testing.M) {
+	logger.Init()
+	os.Exit(m.Run())
+}
+
+// newTestConfig wires the engine to the in-memory stores with one account,
+// testuser@example.com, whose password is "correct horse battery staple".
+func newTestConfig(t *testing.T) Config {
+	defaultParams := util.PasswordHashParams
+	util.PasswordHashParams = util.Argon2Params{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+	t.Cleanup(func() { util.PasswordHashParams = defaultParams })
+
+	stores := db.NewMemoryStores()
+	passwordHash, err := util.HashPassword("correct horse battery staple")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = stores.Users.RegisterUser(context.Background(), "testuser@example.com", passwordHash); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := signing.NewEphemeralKeySet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyRing, err := vault.NewEphemeralKeyRing()
+	if err != nil {
+		t.Fatal(err)
+	}
+	relyingParty, err := service.NewRelyingParty("localhost", "Password Manager", []string{"http://localhost:3000"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return Config{
+		ServiceName:  "password-manager",
+		AllowOrigins: []string{"http://localhost:3000"},
+		Stores:       stores,
+		SiteVault:    vault.NewVault(keyRing, stores.DataKeys),
+		Keys:         keys,
+		RelyingParty: relyingParty,
+		Auth: service.AuthSettings{
+			Lifetimes: service.TokenLifetimes{AccessToken: time.Minute, RefreshToken: time.Hour},
+		},
+		RequestTimeout:     time.Second * 5,
+		ImageLookupTimeout: time.Second,
+	}
+}
+
+func signIn(t *testing.T, handler http.Handler, password string) *httptest.ResponseRecorder {
+	body := `{"email":"testuser@example.com","password":"` + password + `"}`
+	request := httptest.NewRequest(http.MethodPost, "/v1/auth/sign-in", strings.NewReader(body))
+	request.Header.Set("Content-Type", "application/json")
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	return response
+}
+
+func TestSignInMetrics(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	metrics.Init(registry)
+	config := newTestConfig(t)
+	config.Metrics = registry
+	engine := New(config)
+
+	if response := signIn(t, engine, "correct horse battery staple"); response.Code != http.StatusOK {
+		t.Fatalf("sign in: status %d, body %s", response.Code, response.Body)
+	}
+	if response := signIn(t, engine, "wrong password"); response.Code != http.StatusNotFound {
+		t.Fatalf("sign in with a wrong password: status %d, body %s", response.Code, response.Body)
+	}
+
+	expected := `
+# HELP auth_sign_ins_total Sign-in attempts by method (password, totp, recovery_code, passkey) and result (success, challenge, failure).
+# TYPE auth_sign_ins_total counter
+auth_sign_ins_total{method="password",result="failure"} 1
+auth_sign_ins_total{method="password",result="success"} 1
+# HELP http_requests_total HTTP requests by method, route and status.
+# TYPE http_requests_total counter
+http_requests_total{method="POST",route="/v1/auth/sign-in",status="200"} 1
+http_requests_total{method="POST",route="/v1/auth/sign-in",status="404"} 1
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected), "auth_sign_ins_total", "http_requests_total"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVersionedRoutes(t *testing.T) {
+	engine := New(newTestConfig(t))
+
+	response := signIn(t, engine, "correct horse battery staple")
+	if response.Code != http.StatusOK {
+		t.Fatalf("sign in: status %d, body %s", response.Code, response.Body)
+	}
+	authorization := response.Header().Get("Authorization")
+
+	serve := func(method string, path string, body string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, path, strings.NewReader(body))
+		request.Header.Set("Content-Type", "application/json")
+		request.Header.Set("Authorization", authorization)
+		response := httptest.NewRecorder()
+		engine.ServeHTTP(response, request)
+		return response
+	}
+
+	site := `{"url":"https://example.com","name":"Example","sector":"Other","username":"user","password":"secret","notes":""}`
+	response = serve(http.MethodPost, "/v1/sites", site)
+	if response.Code != http.StatusCreated {
+		t.Fatalf("POST /v1/sites: status %d, body %s", response.Code, response.Body)
+	}
+	location := response.Header().Get("Location")
+	if !strings.HasPrefix(location, "/v1/sites/") {
+		t.Fatalf("POST /v1/sites: Location %q", location)
+	}
+	if response = serve(http.MethodGet, location, ""); response.Code != http.StatusOK {
+		t.Fatalf("GET %s: status %d, body %s", location, response.Code, response.Body)
+	}
+
+	if response = serve(http.MethodGet, "/v1/auth/recovery-codes", ""); response.Code != http.StatusOK {
+		t.Fatalf("GET /v1/auth/recovery-codes: status %d, body %s", response.Code, response.Body)
+	}
+	if response.Header().Get("Deprecation") != "" {
+		t.Fatalf("GET /v1/auth/recovery-codes is marked deprecated")
+	}
+
+	legacy := map[string]string{
+		"/recovery-codes": "</v1/auth/recovery-codes>; rel=\"successor-version\"",
+		"/check-token":    "</v1/auth/check-token>; rel=\"successor-version\"",
+	}
+	for path, link := range legacy {
+		response = serve(http.MethodGet, path, "")
+		if response.Header().Get("Deprecation") != "true" || response.Header().Get("Link") != link {
+			t.Fatalf("GET %s: Deprecation %q, Link %q; want true, %s", path, response.Header().Get("Deprecation"), response.Header().Get("Link"), link)
+		}
+	}
+}
+
+// shiftedClock moves the rate limit store's clock forward by offset, so a
+// test can let buckets refill without waiting.
+type shiftedClock struct {
+	db.RateLimitStore
+	offset time.Duration
+}
+
+func (store *shiftedClock) TakeToken(ctx context.Context, key string, capacity int, refill time.Duration, now time.Time) (entity.RateBucket, error) {
+	return store.RateLimitStore.TakeToken(ctx, key, capacity, refill, now.Add(store.offset))
+}
+
+func TestOtpRateLimit(t *testing.T) {
+	config := newTestConfig(t)
+	clock := &shiftedClock{RateLimitStore: config.Stores.RateLimits}
+	config.Stores.RateLimits = clock
+	engine := New(config)
+
+	generateOtp := func() *httptest.ResponseRecorder {
+		// A registered email is refused before any mail is sent.
+		body := `{"email":"testuser@example.com","type":"register"}`
+		request := httptest.NewRequest(http.MethodPost, "/v1/auth/generate-otp", strings.NewReader(body))
+		request.Header.Set("Content-Type", "application/json")
+		response := httptest.NewRecorder()
+		engine.ServeHTTP(response, request)
+		return response
+	}
+
+	policy := middleware.OtpRateLimit
+	for i := 1; i <= policy.Capacity; i++ {
+		response := generateOtp()
+		if response.Code == http.StatusTooManyRequests {
+			t.Fatalf("request %d was rate limited", i)
+		}
+		// The per-client bucket is the tightest, not the shared one.
+		limit, remaining := response.Header().Get("RateLimit-Limit"), response.Header().Get("RateLimit-Remaining")
+		if limit != strconv.Itoa(policy.Capacity) || remaining != strconv.Itoa(policy.Capacity-i) {
+			t.Fatalf("request %d: RateLimit-Limit %s, RateLimit-Remaining %s; want %d, %d", i, limit, remaining, policy.Capacity, policy.Capacity-i)
+		}
+	}
+
+	response := generateOtp()
+	if response.Code != http.StatusTooManyRequests {
+		t.Fatalf("request over capacity: status %d, want 429", response.Code)
+	}
+	if retryAfter := response.Header().Get("Retry-After"); retryAfter != strconv.Itoa(int(policy.Refill.Seconds())) {
+		t.Fatalf("Retry-After %q, want %v", retryAfter, policy.Refill.Seconds())
+	}
+
+	clock.offset += policy.Refill
+	if response = generateOtp(); response.Code == http.StatusTooManyRequests {
+		t.Fatalf("request after a refill was rate limited")
+	}
+	if response = generateOtp(); response.Code != http.StatusTooManyRequests {
+		t.Fatalf("second request after one refill: status %d, want 429", response.Code)
+	}
+}
+
+func TestSessions(t *testing.T) {
+	engine := New(newTestConfig(t))
+
+	signInAs := func() string {
+		response := signIn(t, engine, "correct horse battery staple")
+		if response.Code != http.StatusOK {
+			t.Fatalf("sign in: status %d, body %s", response.Code, response.Body)
+		}
+		return response.Header().Get("Authorization")
+	}
+	serve := func(authorization string, method string, path string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, path, nil)
+		request.Header.Set("Authorization", authorization)
+		response := httptest.NewRecorder()
+		engine.ServeHTTP(response, request)
+		return response
+	}
+	listSessions := func(authorization string) []entity.Session {
+		response := serve(authorization, http.MethodGet, "/sessions")
+		if response.Code != http.StatusOK {
+			t.Fatalf("GET /sessions: status %d, body %s", response.Code, response.Body)
+		}
+		var body struct {
+			Sessions []entity.Session `json:"sessions"`
+		}
+		if err := json.Unmarshal(response.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+		return body.Sessions
+	}
+	expectRevoked := func(authorization string) {
+		response := serve(authorization, http.MethodGet, "/sessions")
+		if response.Code != http.StatusUnauthorized || !strings.Contains(response.Body.String(), `"sessionTimedOut":true`) {
+			t.Fatalf("request with a revoked session: status %d, body %s", response.Code, response.Body)
+		}
+	}
+
+	laptop, phone := signInAs(), signInAs()
+	sessions := listSessions(laptop)
+	if len(sessions) != 2 {
+		t.Fatalf("listed %d sessions, want 2", len(sessions))
+	}
+	var phoneId string
+	for _, session := range sessions {
+		if !session.Current {
+			phoneId = session.Id
+		}
+	}
+	if phoneId == "" || sessions[0].Current == sessions[1].Current {
+		t.Fatalf("want exactly one current session, got %+v", sessions)
+	}
+
+	if response := serve(laptop, http.MethodDelete, "/sessions/"+phoneId); response.Code != http.StatusOK {
+		t.Fatalf("DELETE /sessions/%s: status %d, body %s", phoneId, response.Code, response.Body)
+	}
+	expectRevoked(phone)
+	if sessions = listSessions(laptop); len(sessions) != 1 || !sessions[0].Current {
+		t.Fatalf("after revoking the phone, listed %+v", sessions)
+	}
+
+	tablet := signInAs()
+	response := serve(laptop, http.MethodDelete, "/sessions")
+	if response.Code != http.StatusOK || !strings.Contains(response.Body.String(), `"revoked":1`) {
+		t.Fatalf("DELETE /sessions: status %d, body %s", response.Code, response.Body)
+	}
+	expectRevoked(tablet)
+	if sessions = listSessions(laptop); len(sessions) != 1 {
+		t.Fatalf("after revoking other sessions, listed %d", len(sessions))
+	}
+}
@@ -0,0 +1,109 @@
+package router
+
+import (
+	"password-manager/controller"
+	"password-manager/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// routes holds the middleware shared between route groups.
+type routes struct {
+	auth       gin.HandlerFunc
+	credential gin.HandlerFunc
+	otp        gin.HandlerFunc
+	otpRoute   gin.HandlerFunc
+	user       gin.HandlerFunc
+}
+
+func (routes routes) registerAuth(group *gin.RouterGroup, authController controller.AuthController) {
+	group.POST("/generate-otp", routes.otp, routes.otpRoute, authController.GenerateOtp)
+	group.POST("/verify-otp", routes.credential, authController.VerifyOtp)
+	group.POST("/sign-up", routes.credential, authController.SignUp)
+	group.POST("/sign-in", routes.credential, authController.SignIn)
+	group.POST("/sign-in/totp", routes.credential, authController.SignInTotp)
+	group.POST("/sign-in/recovery-code", routes.credential, authController.SignInRecoveryCode)
+	group.POST("/sign-in/passkey/begin", routes.credential, authController.BeginPasskeySignIn)
+	group.POST("/sign-in/passkey/finish", routes.credential, authController.FinishPasskeySignIn)
+	group.PUT("/forgot-password", routes.credential, authController.ForgotPassword)
+	group.POST("/token/refresh", authController.RefreshToken)
+	group.PUT("/reset-password", routes.auth, routes.credential, authController.ResetPassword)
+	group.GET("/sign-out", routes.auth, routes.user, authController.SignOut)
+	group.GET("/check-token", routes.auth, routes.user, authController.CheckToken)
+}
+
+func (routes routes) registerTwoFactor(group *gin.RouterGroup, authController controller.AuthController) {
+	group.POST("/totp/enroll", authController.EnrollTotp)
+	group.POST("/totp/confirm", authController.ConfirmTotp)
+	group.POST("/totp/disable", authController.DisableTotp)
+	group.GET("/recovery-codes", authController.CountRecoveryCodes)
+	group.POST("/recovery-codes", authController.RegenerateRecoveryCodes)
+}
+
+// registerLegacyAuth keeps the auth routes that predate /v1/auth working,
+// each linking to the route that replaces it. The deprecation comes first so
+// that rejected requests carry it too.
+func (routes routes) registerLegacyAuth(group *gin.RouterGroup, authController controller.AuthController) {
+	successor := func(path string) gin.HandlerFunc {
+		return middleware.DeprecationMiddleware("/v1/auth" + path)
+	}
+
+	group.POST("/generate-otp", successor("/generate-otp"), routes.otp, routes.otpRoute, authController.GenerateOtp)
+	group.POST("/verify-otp", successor("/verify-otp"), routes.credential, authController.VerifyOtp)
+	group.POST("/sign-up", successor("/sign-up"), routes.credential, authController.SignUp)
+	group.POST("/sign-in", successor("/sign-in"), routes.credential, authController.SignIn)
+	group.POST("/sign-in/totp", successor("/sign-in/totp"), routes.credential, authController.SignInTotp)
+	group.POST("/sign-in/recovery-code", successor("/sign-in/recovery-code"), routes.credential, authController.SignInRecoveryCode)
+	group.POST("/sign-in/passkey/begin", successor("/sign-in/passkey/begin"), routes.credential, authController.BeginPasskeySignIn)
+	group.POST("/sign-in/passkey/finish", successor("/sign-in/passkey/finish"), routes.credential, authController.FinishPasskeySignIn)
+	group.PUT("/forgot-password", successor("/forgot-password"), routes.credential, authController.ForgotPassword)
+	group.POST("/token/refresh", successor("/token/refresh"), authController.RefreshToken)
+	group.PUT("/reset-password", successor("/reset-password"), routes.auth, routes.credential, authController.ResetPassword)
+	group.GET("/sign-out", successor("/sign-out"), routes.auth, routes.user, authController.SignOut)
+	group.GET("/check-token", successor("/check-token"), routes.auth, routes.user, authController.CheckToken)
+
+	group.POST("/totp/enroll", successor("/totp/enroll"), routes.auth, routes.user, authController.EnrollTotp)
+	group.POST("/totp/confirm", successor("/totp/confirm"), routes.auth, routes.user, authController.ConfirmTotp)
+	group.POST("/totp/disable", successor("/totp/disable"), routes.auth, routes.user, authController.DisableTotp)
+	group.GET("/recovery-codes", successor("/recovery-codes"), routes.auth, routes.user, authController.CountRecoveryCodes)
+	group.POST("/recovery-codes", successor("/recovery-codes"), routes.auth, routes.user, authController.RegenerateRecoveryCodes)
+}
+
+func (routes routes) registerPasskeys(group *gin.RouterGroup, passkeyController controller.PasskeyController) {
+	group.POST("/register/begin", passkeyController.BeginRegistration)
+	group.POST("/register/finish", passkeyController.FinishRegistration)
+	group.GET("", passkeyController.GetPasskeys)
+	group.PATCH("/:id", passkeyController.RenamePasskey)
+	group.DELETE("/:id", passkeyController.DeletePasskey)
+}
+
+func (routes routes) registerSessions(group *gin.RouterGroup, sessionController controller.SessionController) {
+	group.GET("", sessionController.GetSessions)
+	group.DELETE("", sessionController.RevokeOtherSessions)
+	group.DELETE("/:id", sessionController.RevokeSession)
+}
+
+func (routes routes) registerSites(group *gin.RouterGroup, siteController controller.SiteController) {
+	group.GET("", siteController.GetSites)
+	group.POST("", siteController.CreateSite)
+	group.GET("/:id", siteController.GetSite)
+	group.PATCH("/:id", siteController.EditSite)
+	group.DELETE("/:id", siteController.DeleteSite)
+}
+
+// registerLegacySites keeps the routes that predate /v1/sites working.
+func (routes routes) registerLegacySites(group *gin.RouterGroup, siteController controller.SiteController) {
+	group.POST("/save-site", siteController.SaveSite)
+	group.GET("/get-sites", siteController.GetSites)
+	group.PATCH("/edit-site", siteController.EditSite)
+	group.DELETE("/delete-site", siteController.DeleteSite)
+}
+
+func (routes routes) registerVault(group *gin.RouterGroup, vaultController controller.VaultController) {
+	group.GET("/kdf", vaultController.GetKdfParams)
+	group.PUT("/kdf", vaultController.SetKdfParams)
+	group.GET("/items", vaultController.SyncItems)
+	group.GET("/items/:id", vaultController.GetItem)
+	group.PUT("/items/:id", vaultController.PutItem)
+	group.DELETE("/items/:id", vaultController.DeleteItem)
+}
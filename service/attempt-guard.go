@@ -0,0 +1,155 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"password-manager/db"
+	"password-manager/logger"
+	"password-manager/util"
+	"strings"
+	"time"
+)
+
+// AttemptPolicy describes how failures against one key are throttled. The
+// first FreeAttempts failures cost nothing; each one after that blocks the key
+// for BaseDelay, doubling up to MaxDelay, and LockoutAfter failures lock it
+// for LockoutDuration. Failures are forgotten after Window without one.
+type AttemptPolicy struct {
+	Window          time.Duration
+	FreeAttempts    int
+	BaseDelay       time.Duration
+	MaxDelay        time.Duration
+	LockoutAfter    int
+	LockoutDuration time.Duration
+}
+
+var (
+	AccountAttemptPolicy = AttemptPolicy{
+		Window:          time.Minute * 15,
+		FreeAttempts:    3,
+		BaseDelay:       time.Second,
+		MaxDelay:        time.Minute,
+		LockoutAfter:    10,
+		LockoutDuration: time.Minute * 15,
+	}
+	// IPAttemptPolicy is looser than the account policy since many users
+	// can share an address.
+	IPAttemptPolicy = AttemptPolicy{
+		Window:          time.Minute * 15,
+		FreeAttempts:    10,
+		BaseDelay:       time.Second,
+		MaxDelay:        time.Minute,
+		LockoutAfter:    50,
+		LockoutDuration: time.Minute * 30,
+	}
+)
+
+func (policy AttemptPolicy) blockFor(failures int) time.Duration {
+	if failures >= policy.LockoutAfter {
+		return policy.LockoutDuration
+	}
+	if failures <= policy.FreeAttempts {
+		return 0
+	}
+
+	delay := float64(policy.BaseDelay) * math.Pow(2, float64(failures-policy.FreeAttempts-1))
+	if delay > float64(policy.MaxDelay) {
+		return policy.MaxDelay
+	}
+	return time.Duration(delay)
+}
+
+// attemptKey keeps the scope and kind apart from the full key so that logs
+// can name a key without the email or address in it.
+type attemptKey struct {
+	key    string
+	scope  string
+	kind   string
+	policy AttemptPolicy
+}
+
+func accountKey(scope string, account string) attemptKey {
+	return attemptKey{key: scope + ":account:" + strings.ToLower(account), scope: scope, kind: "account", policy: AccountAttemptPolicy}
+}
+
+func ipKey(scope string, ip string) attemptKey {
+	return attemptKey{key: scope + ":ip:" + ip, scope: scope, kind: "ip", policy: IPAttemptPolicy}
+}
+
+// AttemptGuard protects credential checks from brute force by tracking
+// failures per account and per client IP.
+type AttemptGuard struct {
+	attempts db.AttemptStore
+}
+
+func NewAttemptGuard(attempts db.AttemptStore) *AttemptGuard {
+	return &AttemptGuard{
+		attempts: attempts,
+	}
+}
+
+// check fails with 429 while any of keys is blocked.
+func (guard *AttemptGuard) check(ctx context.Context, keys ...attemptKey) error {
+	now := time.Now()
+	var wait time.Duration
+	for _, key := range keys {
+		counter, err := guard.attempts.GetAttempts(ctx, key.key)
+		if err != nil {
+			return err
+		}
+		if remaining := counter.BlockedUntil.Sub(now); remaining > wait {
+			wait = remaining
+		}
+	}
+	if wait <= 0 {
+		return nil
+	}
+
+	wait = wait.Round(time.Second) + time.Second
+	message := fmt.Sprintf("Too many failed attempts, try again in %d seconds", int(wait.Seconds()))
+	return &util.CustomError{Message: message, Status: http.StatusTooManyRequests, RetryAfter: wait}
+}
+
+// fail records a failure against each key and blocks those that have gone
+// past their free attempts. Errors are logged rather than returned so that
+// the caller still reports the original failure.
+func (guard *AttemptGuard) fail(ctx context.Context, keys ...attemptKey) {
+	now := time.Now()
+	for _, key := range keys {
+		counter, err := guard.attempts.RecordFailure(ctx, key.key, now, key.policy.Window)
+		if err != nil {
+			logger.Error(ctx, "could not record failed attempt", "error", err)
+			continue
+		}
+
+		block := key.policy.blockFor(counter.Failures)
+		if block == 0 {
+			continue
+		}
+		if counter.Failures >= key.policy.LockoutAfter {
+			logger.Warn(ctx, "locked out", "scope", key.scope, "key_type", key.kind, "duration", block.String())
+		}
+		if err = guard.attempts.BlockAttempts(ctx, key.key, now.Add(block)); err != nil {
+			logger.Error(ctx, "could not block attempts", "error", err)
+		}
+	}
+}
+
+// succeed clears the counters for keys. Only account keys should be passed:
+// clearing an IP key would let one valid login reset a guessing run.
+func (guard *AttemptGuard) succeed(ctx context.Context, keys ...attemptKey) {
+	for _, key := range keys {
+		if err := guard.attempts.ResetAttempts(ctx, key.key); err != nil {
+			logger.Error(ctx, "could not reset attempts", "error", err)
+		}
+	}
+}
+
+// rejected reports whether err is the client's fault, such as a wrong code,
+// rather than a server failure that should not count as an attempt.
+func rejected(err error) bool {
+	customErr, ok := err.(*util.CustomError)
+	return ok && customErr.Status >= 400 && customErr.Status < 500
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"password-manager/entity"
+	"password-manager/util"
+	"testing"
+	"time"
+)
+
+func setAccountAttemptPolicy(t *testing.T, policy AttemptPolicy) {
+	defaultPolicy := AccountAttemptPolicy
+	AccountAttemptPolicy = policy
+	t.Cleanup(func() { AccountAttemptPolicy = defaultPolicy })
+}
+
+func TestSignInLockout(t *testing.T) {
+	setAccountAttemptPolicy(t, AttemptPolicy{
+		Window:          time.Minute,
+		FreeAttempts:    1,
+		BaseDelay:       time.Millisecond * 10,
+		MaxDelay:        time.Millisecond * 20,
+		LockoutAfter:    3,
+		LockoutDuration: time.Millisecond * 200,
+	})
+
+	ctx := context.Background()
+	service, stores, _ := newTestAuthService(t)
+	client := entity.ClientInfo{IP: "192.0.2.1"}
+
+	// Wait out each backoff so every failure reaches the password check.
+	for i := 1; i <= AccountAttemptPolicy.LockoutAfter; i++ {
+		time.Sleep(AccountAttemptPolicy.MaxDelay + time.Millisecond*5)
+		_, err := service.SignIn(ctx, testEmail, "wrong password", client)
+		expectStatus(t, "SignIn with a wrong password", err, http.StatusNotFound)
+	}
+
+	counter, err := stores.Attempts.GetAttempts(ctx, accountKey("sign-in", testEmail).key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if counter.Failures != AccountAttemptPolicy.LockoutAfter {
+		t.Fatalf("recorded %d failures, want %d", counter.Failures, AccountAttemptPolicy.LockoutAfter)
+	}
+
+	// The right password is refused while the account is locked, and the
+	// lockout applies whatever case the email is typed in.
+	_, err = service.SignIn(ctx, "TestUser@Example.com", testPassword, client)
+	expectStatus(t, "SignIn while locked out", err, http.StatusTooManyRequests)
+	var customError *util.CustomError
+	if errors.As(err, &customError) && customError.RetryAfter <= 0 {
+		t.Fatalf("lockout has no Retry-After")
+	}
+
+	time.Sleep(AccountAttemptPolicy.LockoutDuration)
+	if _, err = service.SignIn(ctx, testEmail, testPassword, client); err != nil {
+		t.Fatalf("SignIn after the lockout expired: %v", err)
+	}
+	if counter, _ = stores.Attempts.GetAttempts(ctx, accountKey("sign-in", testEmail).key); counter.Failures != 0 {
+		t.Fatalf("successful sign-in left %d failures", counter.Failures)
+	}
+}
@@ -1,219 +1,399 @@
 package service
 
 import (
+	"context"
 	"net/http"
 	"password-manager/db"
+	"password-manager/entity"
 	"password-manager/logger"
+	"password-manager/signing"
 	"password-manager/util"
-	"strconv"
+	"password-manager/vault"
 	"time"
 
-	"github.com/golang-jwt/jwt"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
 )
 
 type AuthService interface {
-	GenerateOtp(email string, otpType string) (id string, expiresAt string, err error)
-	VerifyOtp(dbId string, email string, otp string) (expiresAt string, err error)
-	SignUp(dbId string, email string, password string) (err error)
-	SignIn(email string, password string) (token string, err error)
-	ForgotPassword(dbId string, email string, password string) (err error)
-	ResetPassword(userId string, oldPassword string, newPassword string) (token string, err error)
-	SignOut(token string, expirationTime time.Time) (err error)
+	GenerateOtp(ctx context.Context, email string, otpType string) (id string, expiresAt string, err error)
+	VerifyOtp(ctx context.Context, dbId string, email string, otp string, client entity.ClientInfo) (expiresAt string, err error)
+	SignUp(ctx context.Context, dbId string, email string, password string) (err error)
+	SignIn(ctx context.Context, email string, password string, client entity.ClientInfo) (result entity.SignInResult, err error)
+	SignInTotp(ctx context.Context, challengeToken string, code string, client entity.ClientInfo) (result entity.SignInResult, err error)
+	SignInRecoveryCode(ctx context.Context, challengeToken string, recoveryCode string, client entity.ClientInfo) (result entity.SignInResult, err error)
+	BeginPasskeySignIn(ctx context.Context, challengeToken string) (ceremonyId string, options *protocol.CredentialAssertion, err error)
+	FinishPasskeySignIn(ctx context.Context, ceremonyId string, credential []byte, client entity.ClientInfo) (result entity.SignInResult, err error)
+	ForgotPassword(ctx context.Context, dbId string, email string, password string) (err error)
+	ResetPassword(ctx context.Context, userId string, oldPassword string, newPassword string, client entity.ClientInfo) (tokens entity.AuthTokens, err error)
+	RefreshToken(ctx context.Context, refreshToken string, client entity.ClientInfo) (tokens entity.AuthTokens, err error)
+	SignOut(ctx context.Context, token string, expirationTime time.Time, userId string, sessionId string) (err error)
+	EnrollTotp(ctx context.Context, userId string, password string, code string) (secret string, uri string, err error)
+	ConfirmTotp(ctx context.Context, userId string, code string) (recoveryCodes []string, err error)
+	DisableTotp(ctx context.Context, userId string, password string, code string) (err error)
+	RegenerateRecoveryCodes(ctx context.Context, userId string, password string) (recoveryCodes []string, err error)
+	CountRecoveryCodes(ctx context.Context, userId string) (remaining int, err error)
 }
 
-type authService struct{}
+type authService struct {
+	users         db.UserStore
+	otps          db.OtpStore
+	otpKey        []byte
+	otpLifetime   time.Duration
+	blacklist     db.TokenBlacklist
+	refreshTokens db.RefreshTokenStore
+	sessions      db.SessionStore
+	passkeys      db.PasskeyStore
+	guard         *AttemptGuard
+	secrets       vault.Vault
+	relyingParty  *webauthn.WebAuthn
+	keys          *signing.KeySet
+	lifetimes     TokenLifetimes
+	totpIssuer    string
+	mail          util.MailConfig
+}
+
+// AuthSettings are the configurable parts of the auth service.
+type AuthSettings struct {
+	Lifetimes   TokenLifetimes
+	OtpKey      []byte
+	OtpLifetime time.Duration
+	TotpIssuer  string
+	Mail        util.MailConfig
+}
 
-func NewAuthService() AuthService {
-	return &authService{}
+func NewAuthService(users db.UserStore, otps db.OtpStore, blacklist db.TokenBlacklist, refreshTokens db.RefreshTokenStore, sessions db.SessionStore, passkeys db.PasskeyStore, attempts db.AttemptStore, secrets vault.Vault, relyingParty *webauthn.WebAuthn, keys *signing.KeySet, settings AuthSettings) AuthService {
+	return &authService{
+		users:         users,
+		otps:          otps,
+		otpKey:        settings.OtpKey,
+		otpLifetime:   settings.OtpLifetime,
+		blacklist:     blacklist,
+		refreshTokens: refreshTokens,
+		sessions:      sessions,
+		passkeys:      passkeys,
+		guard:         NewAttemptGuard(attempts),
+		secrets:       secrets,
+		relyingParty:  relyingParty,
+		keys:          keys,
+		lifetimes:     settings.Lifetimes,
+		totpIssuer:    settings.TotpIssuer,
+		mail:          settings.Mail,
+	}
 }
 
-func (service *authService) GenerateOtp(email string, otpType string) (id string, expiresAt string, err error) {
-	registerationStatus, err := db.CheckUserRegistered(email)
+func (service *authService) GenerateOtp(ctx context.Context, email string, otpType string) (id string, expiresAt string, err error) {
+	ctx, span := tracer.Start(ctx, "AuthService.GenerateOtp")
+	defer span.End()
+
+	registerationStatus, err := service.users.CheckUserRegistered(ctx, email)
 	var purpose string
-	if otpType == "reset" {
+	if otpType == entity.OtpPurposeReset {
 		purpose = "forgot password"
 		if err != nil {
-			logger.ErrorLogger.Println(err.Error())
 			return "", "", err
 		}
 		if !registerationStatus {
 			message := "Email is not registered"
-			logger.ErrorLogger.Println(message)
 			return "", "", &util.CustomError{Message: message, Status: http.StatusConflict}
 		}
 	} else {
 		purpose = "registration"
 		if err != nil {
-			logger.ErrorLogger.Println(err.Error())
 			return "", "", err
 		}
 		if registerationStatus {
 			message := "Email is already registered"
-			logger.ErrorLogger.Println(message)
 			return "", "", &util.CustomError{Message: message, Status: http.StatusConflict}
 		}
 	}
 
-	id, err = db.CheckOtpGenerated(email)
+	otp, err := util.GenerateOtp(otpDigits)
 	if err != nil {
-		logger.ErrorLogger.Println(err.Error())
-		return "", "", &util.CustomError{Message: "OTP generation failed", Status: http.StatusInternalServerError}
+		return "", "", &util.CustomError{Message: "OTP generation failed", Status: http.StatusInternalServerError, Cause: err}
 	}
 
-	otp := util.GenerateOtp(6)
-	if id == "" {
-		if id, expiresAt, err = db.GenerateOtp(email, strconv.Itoa(otp)); err != nil {
-			logger.ErrorLogger.Println(err.Error())
-			return "", "", &util.CustomError{Message: "OTP generation failed", Status: http.StatusInternalServerError}
-		}
-
-		if err = util.SendEmailOtp(email, otp, purpose); err != nil {
-			logger.ErrorLogger.Println(err.Error())
-			return "", "", &util.CustomError{Message: "OTP generation failed", Status: http.StatusInternalServerError}
-		}
-	} else {
-		if expiresAt, err = db.ReGenerateOtp(email, strconv.Itoa(otp)); err != nil {
-			logger.ErrorLogger.Println(err.Error())
-			return "", "", &util.CustomError{Message: "OTP generation failed", Status: http.StatusInternalServerError}
-		}
+	if id, expiresAt, err = service.otps.GenerateOtp(ctx, email, otpType, hashOtp(service.otpKey, email, otp), service.otpLifetime); err != nil {
+		return "", "", &util.CustomError{Message: "OTP generation failed", Status: http.StatusInternalServerError, Cause: err}
+	}
 
-		if err = util.SendEmailOtp(email, otp, purpose); err != nil {
-			logger.ErrorLogger.Println(err.Error())
-			return "", "", &util.CustomError{Message: "OTP generation failed", Status: http.StatusInternalServerError}
-		}
+	if err = util.SendEmailOtp(ctx, service.mail, email, otp, purpose); err != nil {
+		return "", "", &util.CustomError{Message: "OTP generation failed", Status: http.StatusInternalServerError, Cause: err}
 	}
 
 	return id, expiresAt, nil
 }
 
-func (service *authService) VerifyOtp(dbId string, email string, otp string) (expiresAt string, err error) {
-	if err = db.VerifyOtp(dbId, email, otp); err != nil {
-		logger.ErrorLogger.Println(err.Error())
-		return "", &util.CustomError{Message: err.Error(), Status: http.StatusInternalServerError}
+func (service *authService) VerifyOtp(ctx context.Context, dbId string, email string, otp string, client entity.ClientInfo) (expiresAt string, err error) {
+	ctx, span := tracer.Start(ctx, "AuthService.VerifyOtp")
+	defer span.End()
+
+	account, ip := accountKey("verify-otp", email), ipKey("verify-otp", client.IP)
+	if err = service.guard.check(ctx, account, ip); err != nil {
+		return "", err
+	}
+
+	if err = service.otps.VerifyOtp(ctx, dbId, email, hashOtp(service.otpKey, email, otp)); err != nil {
+		if rejected(err) {
+			service.guard.fail(ctx, account, ip)
+		}
+		return "", err
 	}
+	service.guard.succeed(ctx, account)
 
-	if expiresAt, err = db.OtpVerified(email); err != nil {
-		logger.ErrorLogger.Println(err.Error())
-		return "", &util.CustomError{Message: err.Error(), Status: http.StatusInternalServerError}
+	if expiresAt, err = service.otps.OtpVerified(ctx, dbId, email, service.otpLifetime); err != nil {
+		return "", &util.CustomError{Message: err.Error(), Status: http.StatusInternalServerError, Cause: err}
 	}
 
 	return expiresAt, nil
 }
 
-func (service *authService) SignUp(dbId string, email string, password string) error {
-	verificationStatus, err := db.RemoveVerifiedUser(dbId, email)
+// SignUp consumes the verified OTP and creates the account. The OTP can only
+// be used once and the store refuses a second account for the same email, so
+// concurrent sign-ups create at most one user.
+func (service *authService) SignUp(ctx context.Context, dbId string, email string, password string) error {
+	ctx, span := tracer.Start(ctx, "AuthService.SignUp")
+	defer span.End()
+
+	passwordHash, err := util.HashPassword(password)
+	if err != nil {
+		return &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
+	}
+
+	verificationStatus, err := service.otps.UseVerifiedOtp(ctx, dbId, email, entity.OtpPurposeRegister)
 	if err != nil {
-		logger.ErrorLogger.Println(err.Error())
 		return err
 	}
 	if !verificationStatus {
 		message := "Email is not verified"
-		logger.ErrorLogger.Println(message)
 		return &util.CustomError{Message: message, Status: http.StatusBadRequest}
 	}
 
-	_, err = db.RegisterUser(email, password)
-	if err != nil {
-		logger.ErrorLogger.Println(err.Error())
-	}
-
+	_, err = service.users.RegisterUser(ctx, email, passwordHash)
 	return err
 }
 
-func (service *authService) SignIn(email string, password string) (string, error) {
-	registerationStatus, err := db.CheckUserRegistered(email)
+func (service *authService) SignIn(ctx context.Context, email string, password string, client entity.ClientInfo) (entity.SignInResult, error) {
+	ctx, span := tracer.Start(ctx, "AuthService.SignIn")
+	defer span.End()
+
+	account, ip := accountKey("sign-in", email), ipKey("sign-in", client.IP)
+	if err := service.guard.check(ctx, account, ip); err != nil {
+		return entity.SignInResult{}, err
+	}
+
+	registerationStatus, err := service.users.CheckUserRegistered(ctx, email)
 	if err != nil {
-		logger.ErrorLogger.Println(err.Error())
-		return "", err
+		return entity.SignInResult{}, err
 	}
 	if !registerationStatus {
+		service.guard.fail(ctx, account, ip)
 		message := "Email is not registered"
-		logger.ErrorLogger.Println(message)
-		return "", &util.CustomError{Message: message, Status: http.StatusNotFound}
+		return entity.SignInResult{}, &util.CustomError{Message: message, Status: http.StatusNotFound}
 	}
 
-	validCredentials, userId, passwordSetAt, err := db.CheckUserCredentials(email, password)
+	user, found, err := service.users.FindUserByEmail(ctx, email)
 	if err != nil {
-		logger.ErrorLogger.Println(err.Error())
-		return "", err
+		return entity.SignInResult{}, err
+	}
+	validCredentials := false
+	if found {
+		if validCredentials, err = checkPassword(ctx, service.users, user, password); err != nil {
+			return entity.SignInResult{}, err
+		}
 	}
 	if !validCredentials {
+		service.guard.fail(ctx, account, ip)
 		message := "Email or password is wrong"
-		logger.ErrorLogger.Println(message)
-		return "", &util.CustomError{Message: message, Status: http.StatusNotFound}
+		return entity.SignInResult{}, &util.CustomError{Message: message, Status: http.StatusNotFound}
 	}
+	service.guard.succeed(ctx, account)
 
-	token := jwt.New(jwt.SigningMethodHS256)
-
-	claims := token.Claims.(jwt.MapClaims)
-	claims["exp"] = time.Now().Add(time.Minute * 60).Unix()
-	claims["passwordSetAt"] = passwordSetAt
-	claims["id"] = userId
+	// A registered passkey is a second factor on its own, so the password
+	// alone never signs in an account that has one.
+	passkeys, err := service.passkeys.GetPasskeys(ctx, user.Id)
+	if err != nil {
+		return entity.SignInResult{}, err
+	}
+	totpEnabled := user.Totp != nil && user.Totp.Enabled
+	if totpEnabled || len(passkeys) > 0 {
+		methods := []string{}
+		if totpEnabled {
+			methods = append(methods, "totp")
+		}
+		if len(user.RecoveryCodes) > 0 {
+			methods = append(methods, "recoveryCode")
+		}
+		if len(passkeys) > 0 {
+			methods = append(methods, "passkey")
+		}
+		challenge, err := service.issueChallenge(user, methods)
+		if err != nil {
+			return entity.SignInResult{}, err
+		}
+		return entity.SignInResult{Challenge: challenge}, nil
+	}
 
-	t, err := token.SignedString([]byte("secret"))
+	tokens, err := service.issueTokens(ctx, user.Id, user.PasswordSetAt, "", client)
 	if err != nil {
-		logger.ErrorLogger.Println(err.Error())
-		return "", &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError}
+		return entity.SignInResult{}, err
 	}
 
-	return t, nil
+	return entity.SignInResult{Tokens: tokens, Kdf: user.Kdf}, nil
 }
 
-func (service *authService) ForgotPassword(dbId string, email string, password string) error {
-	verificationStatus, err := db.CheckUserVerified(dbId, email)
+func (service *authService) ForgotPassword(ctx context.Context, dbId string, email string, password string) error {
+	ctx, span := tracer.Start(ctx, "AuthService.ForgotPassword")
+	defer span.End()
+
+	verificationStatus, err := service.otps.UseVerifiedOtp(ctx, dbId, email, entity.OtpPurposeReset)
 	if err != nil {
-		logger.ErrorLogger.Println(err.Error())
 		return err
 	}
 	if !verificationStatus {
 		message := "Email is not verified"
-		logger.ErrorLogger.Println(message)
 		return &util.CustomError{Message: message, Status: http.StatusBadRequest}
 	}
 
-	_, err = db.ResetPassword(email, password)
+	passwordHash, err := util.HashPassword(password)
 	if err != nil {
-		logger.ErrorLogger.Println(err.Error())
+		return &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
 	}
 
+	_, err = service.users.ResetPassword(ctx, email, passwordHash)
 	return err
 }
 
-func (service *authService) ResetPassword(userId string, oldPassword string, newPassword string) (string, error) {
-	userEmail, err := db.CheckUserCredentialsWithId(userId, oldPassword)
+func (service *authService) ResetPassword(ctx context.Context, userId string, oldPassword string, newPassword string, client entity.ClientInfo) (entity.AuthTokens, error) {
+	ctx, span := tracer.Start(ctx, "AuthService.ResetPassword")
+	defer span.End()
+
+	account, ip := accountKey("reset-password", userId), ipKey("reset-password", client.IP)
+	if err := service.guard.check(ctx, account, ip); err != nil {
+		return entity.AuthTokens{}, err
+	}
+
+	user, found, err := service.users.FindUserById(ctx, userId)
 	if err != nil {
-		logger.ErrorLogger.Println(err.Error())
-		return "", err
+		return entity.AuthTokens{}, err
+	}
+	validCredentials := false
+	if found {
+		if validCredentials, err = checkPassword(ctx, service.users, user, oldPassword); err != nil {
+			return entity.AuthTokens{}, err
+		}
 	}
-	if userEmail == "" {
+	if !validCredentials {
+		service.guard.fail(ctx, account, ip)
 		message := "Password is wrong"
-		logger.ErrorLogger.Println(message)
-		return "", &util.CustomError{Message: message, Status: http.StatusNotFound}
+		return entity.AuthTokens{}, &util.CustomError{Message: message, Status: http.StatusNotFound}
 	}
+	service.guard.succeed(ctx, account)
 
-	passwordSetAt, err := db.ResetPassword(userEmail, newPassword)
+	passwordHash, err := util.HashPassword(newPassword)
 	if err != nil {
-		logger.ErrorLogger.Println(err.Error())
-		return "", err
+		return entity.AuthTokens{}, &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
 	}
 
-	token := jwt.New(jwt.SigningMethodHS256)
+	passwordSetAt, err := service.users.ResetPassword(ctx, user.Email, passwordHash)
+	if err != nil {
+		return entity.AuthTokens{}, err
+	}
 
-	claims := token.Claims.(jwt.MapClaims)
-	claims["exp"] = time.Now().Add(time.Minute * 60).Unix()
-	claims["passwordSetAt"] = passwordSetAt
-	claims["id"] = userId
+	return service.issueTokens(ctx, userId, passwordSetAt, "", client)
+}
 
-	t, err := token.SignedString([]byte("secret"))
+// RefreshToken exchanges a refresh token for a new access and refresh token
+// pair in the same family. Refresh tokens are single use: presenting one a
+// second time means it has leaked, so the whole family is revoked.
+func (service *authService) RefreshToken(ctx context.Context, refreshToken string, client entity.ClientInfo) (entity.AuthTokens, error) {
+	ctx, span := tracer.Start(ctx, "AuthService.RefreshToken")
+	defer span.End()
+
+	now := time.Now()
+
+	record, found, err := service.refreshTokens.UseRefreshToken(ctx, hashToken(refreshToken), now.UTC())
 	if err != nil {
-		logger.ErrorLogger.Println(err.Error())
-		return "", &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError}
+		return entity.AuthTokens{}, err
+	}
+	if !found {
+		message := "Refresh token is invalid"
+		return entity.AuthTokens{}, &util.CustomError{Message: message, Status: http.StatusUnauthorized}
+	}
+
+	if record.UsedAt != nil {
+		message := "Refresh token reuse detected"
+		logger.Warn(ctx, "revoking refresh token family after reuse", "family_id", record.FamilyId)
+		ctx = context.WithoutCancel(ctx)
+		if err = service.refreshTokens.RevokeRefreshTokenFamily(ctx, record.FamilyId); err != nil {
+			logger.Error(ctx, "could not revoke refresh token family", "family_id", record.FamilyId, "error", err)
+		}
+		if _, err = service.sessions.RevokeSession(ctx, record.UserId, record.FamilyId); err != nil {
+			logger.Error(ctx, "could not revoke session", "session_id", record.FamilyId, "error", err)
+		}
+		return entity.AuthTokens{}, &util.CustomError{Message: message, Status: http.StatusUnauthorized}
 	}
 
-	return t, err
+	if record.Revoked || record.ExpiresAt.Before(now) {
+		message := "Refresh token is expired or revoked"
+		return entity.AuthTokens{}, &util.CustomError{Message: message, Status: http.StatusUnauthorized}
+	}
+
+	user, found, err := service.users.FindUserById(ctx, record.UserId)
+	if err != nil {
+		return entity.AuthTokens{}, err
+	}
+	if !found || user.PasswordSetAt != record.PasswordSetAt {
+		message := "Refresh token is expired or revoked"
+		return entity.AuthTokens{}, &util.CustomError{Message: message, Status: http.StatusUnauthorized}
+	}
+
+	return service.issueTokens(ctx, user.Id, user.PasswordSetAt, record.FamilyId, client)
 }
 
-func (service *authService) SignOut(token string, expirationTime time.Time) error {
-	db.BlacklistToken(token, expirationTime)
+func (service *authService) SignOut(ctx context.Context, token string, expirationTime time.Time, userId string, sessionId string) error {
+	ctx, span := tracer.Start(ctx, "AuthService.SignOut")
+	defer span.End()
+	// Revocation runs to completion even if the client hangs up; each store
+	// call is still bounded by the operation timeout.
+	ctx = context.WithoutCancel(ctx)
+
+	if err := service.blacklist.BlacklistToken(ctx, token, expirationTime); err != nil {
+		return err
+	}
+	if sessionId != "" {
+		if err := service.refreshTokens.RevokeRefreshTokenFamily(ctx, sessionId); err != nil {
+			return err
+		}
+		if _, err := service.sessions.RevokeSession(ctx, userId, sessionId); err != nil {
+			return err
+		}
+	}
 	return nil
 }
+
+// checkPassword verifies password against the stored record and, on success,
+// upgrades plaintext or weaker hashes in place. A failed upgrade is logged but
+// does not fail the sign-in.
+func checkPassword(ctx context.Context, users db.UserStore, user entity.User, password string) (bool, error) {
+	match, needsRehash, err := util.VerifyPassword(password, user.Password)
+	if err != nil {
+		return false, &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
+	}
+	if !match {
+		return false, nil
+	}
+
+	if needsRehash {
+		passwordHash, err := util.HashPassword(password)
+		if err != nil {
+			logger.Error(ctx, "could not rehash password", "error", err)
+			return true, nil
+		}
+		if err = users.RehashPassword(ctx, user.Id, user.Password, passwordHash); err != nil {
+			logger.Error(ctx, "could not rehash password", "error", err)
+		}
+	}
+
+	return true, nil
+}
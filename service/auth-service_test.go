@@ -0,0 +1,148 @@
+package service
+
+import (
+	"context"
+	"os"
+	"password-manager/db"
+	"password-manager/entity"
+	"password-manager/logger"
+	"password-manager/signing"
+	"password-manager/util"
+	"password-manager/vault"
+	"sync"
+	"testing"
+	"time"
+)
+
+const concurrentSignUps = 50
+
+const (
+	testEmail    = "testuser@example.com"
+	testPassword = "correct horse battery staple"
+)
+
+func TestMain(m *testing.M) {
+	logger.Init()
+	os.Exit(m.Run())
+}
+
+// cheapPasswordHashes swaps in argon2 parameters that cost next to nothing
+// for the length of the test.
+func cheapPasswordHashes(t *testing.T) {
+	defaultParams := util.PasswordHashParams
+	util.PasswordHashParams = util.Argon2Params{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+	t.Cleanup(func() { util.PasswordHashParams = defaultParams })
+}
+
+// newTestAuthService builds the auth service on fresh memory stores holding
+// one account, testEmail with testPassword, and returns that account's id.
+func newTestAuthService(t *testing.T) (*authService, db.Stores, string) {
+	cheapPasswordHashes(t)
+
+	stores := db.NewMemoryStores()
+	passwordHash, err := util.HashPassword(testPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	userId, err := stores.Users.RegisterUser(context.Background(), testEmail, passwordHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyRing, err := vault.NewEphemeralKeyRing()
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := signing.NewEphemeralKeySet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	otpKey, err := NewEphemeralOtpKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	settings := AuthSettings{
+		Lifetimes:   TokenLifetimes{AccessToken: time.Minute, RefreshToken: time.Hour},
+		OtpKey:      otpKey,
+		OtpLifetime: time.Minute * 10,
+		TotpIssuer:  "Password Manager",
+	}
+	service := NewAuthService(stores.Users, stores.Otps, stores.Blacklist, stores.Refresh, stores.Sessions, stores.Passkeys, stores.Attempts, vault.NewVault(keyRing, stores.DataKeys), nil, keys, settings)
+
+	return service.(*authService), stores, userId
+}
+
+func TestRegisterUserConcurrently(t *testing.T) {
+	stores := db.NewMemoryStores()
+
+	errs := make(chan error, concurrentSignUps)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrentSignUps; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := stores.Users.RegisterUser(context.Background(), "race@example.com", "hash")
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	registered, taken := 0, 0
+	for err := range errs {
+		switch err {
+		case nil:
+			registered++
+		case db.ErrEmailTaken:
+			taken++
+		default:
+			t.Fatalf("RegisterUser: unexpected error %v", err)
+		}
+	}
+	if registered != 1 || taken != concurrentSignUps-1 {
+		t.Fatalf("got %d accounts and %d ErrEmailTaken, want 1 and %d", registered, taken, concurrentSignUps-1)
+	}
+}
+
+func TestSignUpConcurrently(t *testing.T) {
+	// Fifty full-cost hashes at once need gigabytes of memory.
+	cheapPasswordHashes(t)
+
+	ctx := context.Background()
+	stores := db.NewMemoryStores()
+	service := NewAuthService(stores.Users, stores.Otps, stores.Blacklist, stores.Refresh, stores.Sessions, stores.Passkeys, stores.Attempts, nil, nil, nil, AuthSettings{})
+
+	email := "race@example.com"
+	otpId, _, err := stores.Otps.GenerateOtp(ctx, email, entity.OtpPurposeRegister, "otp-hash", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = stores.Otps.OtpVerified(ctx, otpId, email, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	errs := make(chan error, concurrentSignUps)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrentSignUps; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- service.SignUp(ctx, otpId, email, "correct horse battery staple")
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	succeeded := 0
+	for err := range errs {
+		if err == nil {
+			succeeded++
+		}
+	}
+	if succeeded != 1 {
+		t.Fatalf("%d sign-ups succeeded, want 1", succeeded)
+	}
+	if registered, err := stores.Users.CheckUserRegistered(ctx, email); err != nil || !registered {
+		t.Fatalf("CheckUserRegistered = %v, %v; want true", registered, err)
+	}
+}
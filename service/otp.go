@@ -0,0 +1,47 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+const otpDigits = 6
+
+var ErrNoOtpKey = errors.New("OTP_KEY must be set")
+
+// ParseOtpKey decodes the base64 key that OTPs are HMACed with. Only the HMAC
+// is stored, so a leaked otp collection does not reveal pending codes.
+func ParseOtpKey(encoded string) ([]byte, error) {
+	if encoded == "" {
+		return nil, ErrNoOtpKey
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, errors.New("OTP_KEY must be base64 encoded")
+	}
+	if len(key) < 32 {
+		return nil, errors.New("OTP_KEY must be at least 256 bits")
+	}
+
+	return key, nil
+}
+
+func NewEphemeralOtpKey() ([]byte, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// hashOtp binds the code to the address it was sent to.
+func hashOtp(key []byte, email string, otp string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(strings.ToLower(email) + "\x00" + otp))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"password-manager/entity"
+	"testing"
+	"time"
+)
+
+func TestOtp(t *testing.T) {
+	// The account throttle would step in before the OTP's own attempt cap.
+	setAccountAttemptPolicy(t, AttemptPolicy{Window: time.Minute, FreeAttempts: 100, LockoutAfter: 100})
+
+	const (
+		newEmail    = "newuser@example.com"
+		code        = "123456"
+		otpAttempts = 5
+	)
+	otherKey, err := NewEphemeralOtpKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		purpose      string
+		email        string
+		verifyEmail  string
+		key          []byte
+		wrongGuesses int
+		verifyStatus int
+		usePurpose   string
+		useStatus    int
+	}{
+		{name: "sign up", purpose: entity.OtpPurposeRegister, email: newEmail, usePurpose: entity.OtpPurposeRegister},
+		{name: "password reset", purpose: entity.OtpPurposeReset, email: testEmail, usePurpose: entity.OtpPurposeReset},
+		{name: "four wrong guesses", purpose: entity.OtpPurposeRegister, email: newEmail, wrongGuesses: 4, usePurpose: entity.OtpPurposeRegister},
+		{name: "five wrong guesses", purpose: entity.OtpPurposeRegister, email: newEmail, wrongGuesses: 5, verifyStatus: http.StatusTooManyRequests},
+		{name: "sent to another address", purpose: entity.OtpPurposeRegister, email: newEmail, verifyEmail: "other@example.com", verifyStatus: http.StatusBadRequest},
+		{name: "hashed under another key", purpose: entity.OtpPurposeRegister, email: newEmail, key: otherKey, verifyStatus: http.StatusBadRequest},
+		{name: "sign up code used for a reset", purpose: entity.OtpPurposeRegister, email: testEmail, usePurpose: entity.OtpPurposeReset, useStatus: http.StatusBadRequest},
+		{name: "reset code used for sign up", purpose: entity.OtpPurposeReset, email: newEmail, usePurpose: entity.OtpPurposeRegister, useStatus: http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			service, _, _ := newTestAuthService(t)
+			key := test.key
+			if key == nil {
+				key = service.otpKey
+			}
+			verifyEmail := test.verifyEmail
+			if verifyEmail == "" {
+				verifyEmail = test.email
+			}
+
+			id, _, err := service.otps.GenerateOtp(ctx, test.email, test.purpose, hashOtp(key, test.email, code), service.otpLifetime)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < test.wrongGuesses; i++ {
+				_, err = service.VerifyOtp(ctx, id, verifyEmail, "654321", entity.ClientInfo{})
+				if i < otpAttempts-1 {
+					expectStatus(t, "wrong guess", err, http.StatusBadRequest)
+				} else {
+					expectStatus(t, "last wrong guess", err, http.StatusTooManyRequests)
+				}
+			}
+
+			_, err = service.VerifyOtp(ctx, id, verifyEmail, code, entity.ClientInfo{})
+			if test.verifyStatus != 0 {
+				expectStatus(t, "VerifyOtp", err, test.verifyStatus)
+				return
+			}
+			if err != nil {
+				t.Fatalf("VerifyOtp: %v", err)
+			}
+
+			if test.usePurpose == entity.OtpPurposeRegister {
+				err = service.SignUp(ctx, id, test.email, "a new password")
+			} else {
+				err = service.ForgotPassword(ctx, id, test.email, "a new password")
+			}
+			if test.useStatus != 0 {
+				expectStatus(t, "using the OTP", err, test.useStatus)
+				return
+			}
+			if err != nil {
+				t.Fatalf("using the OTP: %v", err)
+			}
+
+			// A used OTP cannot be used again.
+			if test.usePurpose == entity.OtpPurposeRegister {
+				err = service.SignUp(ctx, id, test.email, "a new password")
+			} else {
+				err = service.ForgotPassword(ctx, id, test.email, "a new password")
+			}
+			expectStatus(t, "using the OTP twice", err, http.StatusBadRequest)
+		})
+	}
+}
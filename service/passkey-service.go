@@ -0,0 +1,193 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"net/http"
+	"password-manager/db"
+	"password-manager/entity"
+	"password-manager/util"
+	"strings"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+const maxPasskeyNameLength = 64
+
+type PasskeyService interface {
+	BeginRegistration(ctx context.Context, userId string, password string) (ceremonyId string, options *protocol.CredentialCreation, err error)
+	FinishRegistration(ctx context.Context, userId string, ceremonyId string, name string, credential []byte) (passkey entity.Passkey, err error)
+	GetPasskeys(ctx context.Context, userId string) (passkeys []entity.Passkey, err error)
+	RenamePasskey(ctx context.Context, userId string, passkeyId string, name string) (err error)
+	DeletePasskey(ctx context.Context, userId string, passkeyId string, password string) (err error)
+}
+
+type passkeyService struct {
+	users        db.UserStore
+	passkeys     db.PasskeyStore
+	relyingParty *webauthn.WebAuthn
+	guard        *AttemptGuard
+}
+
+func NewPasskeyService(users db.UserStore, passkeys db.PasskeyStore, attempts db.AttemptStore, relyingParty *webauthn.WebAuthn) PasskeyService {
+	return &passkeyService{
+		users:        users,
+		passkeys:     passkeys,
+		relyingParty: relyingParty,
+		guard:        NewAttemptGuard(attempts),
+	}
+}
+
+// BeginRegistration needs the account password as well as the access token,
+// so a stolen token alone cannot add a sign-in method. FinishRegistration is
+// bound to the ceremony started here.
+func (service *passkeyService) BeginRegistration(ctx context.Context, userId string, password string) (string, *protocol.CredentialCreation, error) {
+	ctx, span := tracer.Start(ctx, "PasskeyService.BeginRegistration")
+	defer span.End()
+
+	if _, err := reauthenticate(ctx, service.users, service.guard, userId, password); err != nil {
+		return "", nil, err
+	}
+
+	user, err := loadWebAuthnUser(ctx, service.users, service.passkeys, userId)
+	if err != nil {
+		return "", nil, err
+	}
+
+	options, session, err := service.relyingParty.BeginRegistration(user, webauthn.WithExclusions(user.descriptors()))
+	if err != nil {
+		return "", nil, &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
+	}
+
+	ceremonyId, err := saveCeremony(ctx, service.passkeys, userId, ceremonyRegistration, session)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return ceremonyId, options, nil
+}
+
+func (service *passkeyService) FinishRegistration(ctx context.Context, userId string, ceremonyId string, name string, credential []byte) (entity.Passkey, error) {
+	ctx, span := tracer.Start(ctx, "PasskeyService.FinishRegistration")
+	defer span.End()
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = "Passkey"
+	}
+	if len(name) > maxPasskeyNameLength {
+		message := "Name must be at most 64 characters"
+		return entity.Passkey{}, &util.CustomError{Message: message, Status: http.StatusBadRequest}
+	}
+
+	ceremony, session, err := takeCeremony(ctx, service.passkeys, ceremonyId, ceremonyRegistration)
+	if err != nil {
+		return entity.Passkey{}, err
+	}
+	if ceremony.UserId != userId {
+		message := "Passkey request is invalid or expired"
+		return entity.Passkey{}, &util.CustomError{Message: message, Status: http.StatusBadRequest}
+	}
+
+	user, err := loadWebAuthnUser(ctx, service.users, service.passkeys, userId)
+	if err != nil {
+		return entity.Passkey{}, err
+	}
+
+	parsed, err := protocol.ParseCredentialCreationResponseBody(bytes.NewReader(credential))
+	if err != nil {
+		return entity.Passkey{}, passkeyError(err)
+	}
+	created, err := service.relyingParty.CreateCredential(user, session, parsed)
+	if err != nil {
+		return entity.Passkey{}, passkeyError(err)
+	}
+
+	passkey := entity.Passkey{
+		Id:              base64.RawURLEncoding.EncodeToString(created.ID),
+		UserId:          userId,
+		Name:            name,
+		PublicKey:       created.PublicKey,
+		AttestationType: created.AttestationType,
+		Transports:      []string{},
+		AAGUID:          created.Authenticator.AAGUID,
+		SignCount:       created.Authenticator.SignCount,
+		BackupEligible:  created.Flags.BackupEligible,
+		BackupState:     created.Flags.BackupState,
+		CreatedAt:       time.Now().UTC(),
+	}
+	for _, transport := range created.Transport {
+		passkey.Transports = append(passkey.Transports, string(transport))
+	}
+
+	_, exists, err := service.passkeys.FindPasskey(ctx, passkey.Id)
+	if err != nil {
+		return entity.Passkey{}, err
+	}
+	if exists {
+		message := "Passkey is already registered"
+		return entity.Passkey{}, &util.CustomError{Message: message, Status: http.StatusConflict}
+	}
+
+	if err = service.passkeys.CreatePasskey(ctx, passkey); err != nil {
+		return entity.Passkey{}, err
+	}
+
+	return passkey, nil
+}
+
+func (service *passkeyService) GetPasskeys(ctx context.Context, userId string) ([]entity.Passkey, error) {
+	ctx, span := tracer.Start(ctx, "PasskeyService.GetPasskeys")
+	defer span.End()
+
+	passkeys, err := service.passkeys.GetPasskeys(ctx, userId)
+	if err != nil {
+		return []entity.Passkey{}, err
+	}
+	return passkeys, nil
+}
+
+func (service *passkeyService) RenamePasskey(ctx context.Context, userId string, passkeyId string, name string) error {
+	ctx, span := tracer.Start(ctx, "PasskeyService.RenamePasskey")
+	defer span.End()
+
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > maxPasskeyNameLength {
+		message := "Name must be between 1 and 64 characters"
+		return &util.CustomError{Message: message, Status: http.StatusBadRequest}
+	}
+
+	found, err := service.passkeys.RenamePasskey(ctx, userId, passkeyId, name)
+	if err != nil {
+		return err
+	}
+	if !found {
+		message := "Passkey not found"
+		return &util.CustomError{Message: message, Status: http.StatusNotFound}
+	}
+
+	return nil
+}
+
+func (service *passkeyService) DeletePasskey(ctx context.Context, userId string, passkeyId string, password string) error {
+	ctx, span := tracer.Start(ctx, "PasskeyService.DeletePasskey")
+	defer span.End()
+
+	if _, err := reauthenticate(ctx, service.users, service.guard, userId, password); err != nil {
+		return err
+	}
+
+	found, err := service.passkeys.DeletePasskey(ctx, userId, passkeyId)
+	if err != nil {
+		return err
+	}
+	if !found {
+		message := "Passkey not found"
+		return &util.CustomError{Message: message, Status: http.StatusNotFound}
+	}
+
+	return nil
+}
@@ -0,0 +1,228 @@
+package service
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"password-manager/db"
+	"password-manager/entity"
+	"password-manager/signing"
+	"password-manager/util"
+	"testing"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+	"github.com/go-webauthn/webauthn/protocol/webauthncose"
+)
+
+const testOrigin = "http://localhost:3000"
+
+// softAuthenticator is a platform authenticator in memory: one P-256
+// credential, "none" attestation, and user verification on every ceremony.
+type softAuthenticator struct {
+	t            *testing.T
+	key          *ecdsa.PrivateKey
+	credentialId []byte
+	signCount    uint32
+}
+
+func newSoftAuthenticator(t *testing.T) *softAuthenticator {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	credentialId := make([]byte, 16)
+	if _, err = rand.Read(credentialId); err != nil {
+		t.Fatal(err)
+	}
+	return &softAuthenticator{t: t, key: key, credentialId: credentialId}
+}
+
+func (authenticator *softAuthenticator) clientData(ceremony protocol.CeremonyType, challenge []byte) []byte {
+	clientData, err := json.Marshal(protocol.CollectedClientData{
+		Type:      ceremony,
+		Challenge: base64.RawURLEncoding.EncodeToString(challenge),
+		Origin:    testOrigin,
+	})
+	if err != nil {
+		authenticator.t.Fatal(err)
+	}
+	return clientData
+}
+
+func (authenticator *softAuthenticator) authenticatorData(rpId string, flags protocol.AuthenticatorFlags, attested []byte) []byte {
+	rpIdHash := sha256.Sum256([]byte(rpId))
+	data := append(rpIdHash[:], byte(flags))
+	data = binary.BigEndian.AppendUint32(data, authenticator.signCount)
+	return append(data, attested...)
+}
+
+func (authenticator *softAuthenticator) register(options *protocol.CredentialCreation) []byte {
+	publicKey, err := webauthncbor.Marshal(webauthncose.EC2PublicKeyData{
+		PublicKeyData: webauthncose.PublicKeyData{
+			KeyType:   int64(webauthncose.EllipticKey),
+			Algorithm: int64(webauthncose.AlgES256),
+		},
+		Curve:  int64(webauthncose.P256),
+		XCoord: authenticator.key.X.FillBytes(make([]byte, 32)),
+		YCoord: authenticator.key.Y.FillBytes(make([]byte, 32)),
+	})
+	if err != nil {
+		authenticator.t.Fatal(err)
+	}
+
+	attested := make([]byte, 16)
+	attested = binary.BigEndian.AppendUint16(attested, uint16(len(authenticator.credentialId)))
+	attested = append(attested, authenticator.credentialId...)
+	attested = append(attested, publicKey...)
+
+	flags := protocol.FlagUserPresent | protocol.FlagUserVerified | protocol.FlagAttestedCredentialData
+	attestationObject, err := webauthncbor.Marshal(map[string]interface{}{
+		"fmt":      "none",
+		"attStmt":  map[string]interface{}{},
+		"authData": authenticator.authenticatorData(options.Response.RelyingParty.ID, flags, attested),
+	})
+	if err != nil {
+		authenticator.t.Fatal(err)
+	}
+
+	return authenticator.credential(map[string]string{
+		"clientDataJSON":    encode(authenticator.clientData(protocol.CreateCeremony, options.Response.Challenge)),
+		"attestationObject": encode(attestationObject),
+	})
+}
+
+func (authenticator *softAuthenticator) assert(options *protocol.CredentialAssertion, userHandle string) []byte {
+	authenticator.signCount++
+	authenticatorData := authenticator.authenticatorData(options.Response.RelyingPartyID, protocol.FlagUserPresent|protocol.FlagUserVerified, nil)
+	clientData := authenticator.clientData(protocol.AssertCeremony, options.Response.Challenge)
+
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(authenticatorData, clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, authenticator.key, digest[:])
+	if err != nil {
+		authenticator.t.Fatal(err)
+	}
+
+	return authenticator.credential(map[string]string{
+		"clientDataJSON":    encode(clientData),
+		"authenticatorData": encode(authenticatorData),
+		"signature":         encode(signature),
+		"userHandle":        encode([]byte(userHandle)),
+	})
+}
+
+func (authenticator *softAuthenticator) credential(response map[string]string) []byte {
+	credential, err := json.Marshal(map[string]interface{}{
+		"id":       encode(authenticator.credentialId),
+		"rawId":    encode(authenticator.credentialId),
+		"type":     "public-key",
+		"response": response,
+	})
+	if err != nil {
+		authenticator.t.Fatal(err)
+	}
+	return credential
+}
+
+func encode(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func expectStatus(t *testing.T, operation string, err error, status int) {
+	t.Helper()
+	var customError *util.CustomError
+	if !errors.As(err, &customError) || customError.Status != status {
+		t.Fatalf("%s: got error %v, want status %d", operation, err, status)
+	}
+}
+
+func TestPasskeyRegistrationAndSignIn(t *testing.T) {
+	cheapPasswordHashes(t)
+
+	ctx := context.Background()
+	stores := db.NewMemoryStores()
+	relyingParty, err := NewRelyingParty("localhost", "Password Manager", []string{testOrigin})
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := signing.NewEphemeralKeySet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	lifetimes := TokenLifetimes{AccessToken: time.Minute, RefreshToken: time.Hour}
+	auth := NewAuthService(stores.Users, stores.Otps, stores.Blacklist, stores.Refresh, stores.Sessions, stores.Passkeys, stores.Attempts, nil, relyingParty, keys, AuthSettings{Lifetimes: lifetimes})
+	passkeys := NewPasskeyService(stores.Users, stores.Passkeys, stores.Attempts, relyingParty)
+
+	email, password := "passkey@example.com", "correct horse battery staple"
+	passwordHash, err := util.HashPassword(password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	userId, err := stores.Users.RegisterUser(ctx, email, passwordHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, err = passkeys.BeginRegistration(ctx, userId, "wrong password")
+	expectStatus(t, "BeginRegistration with a wrong password", err, http.StatusUnauthorized)
+
+	ceremonyId, creation, err := passkeys.BeginRegistration(ctx, userId, password)
+	if err != nil {
+		t.Fatalf("BeginRegistration: %v", err)
+	}
+	authenticator := newSoftAuthenticator(t)
+	passkey, err := passkeys.FinishRegistration(ctx, userId, ceremonyId, "Laptop", authenticator.register(creation))
+	if err != nil {
+		t.Fatalf("FinishRegistration: %v", err)
+	}
+
+	// The password alone now only earns a challenge.
+	result, err := auth.SignIn(ctx, email, password, entity.ClientInfo{})
+	if err != nil {
+		t.Fatalf("SignIn: %v", err)
+	}
+	if result.Challenge == nil || result.Tokens.AccessToken != "" {
+		t.Fatalf("SignIn with a passkey registered issued tokens instead of a challenge")
+	}
+	if len(result.Challenge.Methods) != 1 || result.Challenge.Methods[0] != "passkey" {
+		t.Fatalf("challenge methods = %v, want [passkey]", result.Challenge.Methods)
+	}
+
+	ceremonyId, assertion, err := auth.BeginPasskeySignIn(ctx, result.Challenge.Token)
+	if err != nil {
+		t.Fatalf("BeginPasskeySignIn: %v", err)
+	}
+	result, err = auth.FinishPasskeySignIn(ctx, ceremonyId, authenticator.assert(assertion, userId), entity.ClientInfo{})
+	if err != nil {
+		t.Fatalf("FinishPasskeySignIn: %v", err)
+	}
+	if result.Tokens.AccessToken == "" {
+		t.Fatalf("FinishPasskeySignIn issued no access token")
+	}
+
+	err = passkeys.DeletePasskey(ctx, userId, passkey.Id, "wrong password")
+	expectStatus(t, "DeletePasskey with a wrong password", err, http.StatusUnauthorized)
+	if registered, _ := passkeys.GetPasskeys(ctx, userId); len(registered) != 1 {
+		t.Fatalf("passkey was deleted without the password")
+	}
+
+	if err = passkeys.DeletePasskey(ctx, userId, passkey.Id, password); err != nil {
+		t.Fatalf("DeletePasskey: %v", err)
+	}
+	result, err = auth.SignIn(ctx, email, password, entity.ClientInfo{})
+	if err != nil {
+		t.Fatalf("SignIn: %v", err)
+	}
+	if result.Challenge != nil || result.Tokens.AccessToken == "" {
+		t.Fatalf("SignIn after deleting the only passkey still challenged")
+	}
+}
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"password-manager/entity"
+	"password-manager/util"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+// BeginPasskeySignIn starts an assertion. With a challenge token from SignIn
+// the passkey completes a two-factor sign-in; without one it is a
+// passwordless sign-in with a discoverable credential, which must verify the
+// user.
+func (service *authService) BeginPasskeySignIn(ctx context.Context, challengeToken string) (string, *protocol.CredentialAssertion, error) {
+	ctx, span := tracer.Start(ctx, "AuthService.BeginPasskeySignIn")
+	defer span.End()
+
+	if challengeToken == "" {
+		options, session, err := service.relyingParty.BeginDiscoverableLogin(webauthn.WithUserVerification(protocol.VerificationRequired))
+		if err != nil {
+			return "", nil, &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
+		}
+
+		ceremonyId, err := saveCeremony(ctx, service.passkeys, "", ceremonySignIn, session)
+		if err != nil {
+			return "", nil, err
+		}
+		return ceremonyId, options, nil
+	}
+
+	challenged, err := service.parseChallenge(ctx, challengeToken)
+	if err != nil {
+		return "", nil, err
+	}
+	user, err := loadWebAuthnUser(ctx, service.users, service.passkeys, challenged.Id)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(user.passkeys) == 0 {
+		message := "No passkeys are registered"
+		return "", nil, &util.CustomError{Message: message, Status: http.StatusBadRequest}
+	}
+
+	options, session, err := service.relyingParty.BeginLogin(user)
+	if err != nil {
+		return "", nil, &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
+	}
+
+	ceremonyId, err := saveCeremony(ctx, service.passkeys, user.user.Id, ceremonySecondFactor, session)
+	if err != nil {
+		return "", nil, err
+	}
+	return ceremonyId, options, nil
+}
+
+func (service *authService) FinishPasskeySignIn(ctx context.Context, ceremonyId string, credential []byte, client entity.ClientInfo) (entity.SignInResult, error) {
+	ctx, span := tracer.Start(ctx, "AuthService.FinishPasskeySignIn")
+	defer span.End()
+
+	ceremony, session, err := takeCeremony(ctx, service.passkeys, ceremonyId, ceremonySignIn, ceremonySecondFactor)
+	if err != nil {
+		return entity.SignInResult{}, err
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBody(bytes.NewReader(credential))
+	if err != nil {
+		return entity.SignInResult{}, passkeyError(err)
+	}
+
+	var user webAuthnUser
+	var verified *webauthn.Credential
+	if ceremony.Purpose == ceremonySecondFactor {
+		if user, err = loadWebAuthnUser(ctx, service.users, service.passkeys, ceremony.UserId); err != nil {
+			return entity.SignInResult{}, err
+		}
+		verified, err = service.relyingParty.ValidateLogin(user, session, parsed)
+	} else {
+		verified, err = service.relyingParty.ValidateDiscoverableLogin(func(rawId []byte, userHandle []byte) (webauthn.User, error) {
+			passkey, found, err := service.passkeys.FindPasskey(ctx, base64.RawURLEncoding.EncodeToString(rawId))
+			if err != nil {
+				return nil, err
+			}
+			if !found || passkey.UserId != string(userHandle) {
+				return nil, errors.New("passkey is not registered")
+			}
+			if user, err = loadWebAuthnUser(ctx, service.users, service.passkeys, passkey.UserId); err != nil {
+				return nil, err
+			}
+			return user, nil
+		}, session, parsed)
+	}
+	if err != nil {
+		return entity.SignInResult{}, passkeyError(err)
+	}
+
+	passkeyId := base64.RawURLEncoding.EncodeToString(verified.ID)
+	if verified.Authenticator.CloneWarning {
+		message := "Passkey may have been cloned"
+		return entity.SignInResult{}, &util.CustomError{Message: message, Status: http.StatusUnauthorized, Cause: errors.New("clone warning for passkey " + passkeyId)}
+	}
+	if err = service.passkeys.UpdatePasskeyUsage(ctx, passkeyId, verified.Authenticator.SignCount, verified.Flags.BackupState, time.Now().UTC()); err != nil {
+		return entity.SignInResult{}, err
+	}
+
+	tokens, err := service.issueTokens(ctx, user.user.Id, user.user.PasswordSetAt, "", client)
+	if err != nil {
+		return entity.SignInResult{}, err
+	}
+
+	return entity.SignInResult{Tokens: tokens, Kdf: user.user.Kdf}, nil
+}
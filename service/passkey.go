@@ -0,0 +1,189 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"password-manager/db"
+	"password-manager/entity"
+	"password-manager/util"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+const (
+	ceremonyLifetime = time.Minute * 5
+
+	ceremonyRegistration = "registration"
+	ceremonySignIn       = "sign-in"
+	ceremonySecondFactor = "second-factor"
+)
+
+// NewRelyingParty configures WebAuthn for the relying party id (the domain
+// passkeys are scoped to), the name shown by authenticators, and the origins
+// the frontend is served from.
+func NewRelyingParty(id string, name string, origins []string) (*webauthn.WebAuthn, error) {
+	config := &webauthn.Config{
+		RPID:                  id,
+		RPDisplayName:         name,
+		RPOrigins:             origins,
+		AttestationPreference: protocol.PreferNoAttestation,
+		AuthenticatorSelection: protocol.AuthenticatorSelection{
+			ResidentKey:      protocol.ResidentKeyRequirementPreferred,
+			UserVerification: protocol.VerificationPreferred,
+		},
+		Timeouts: webauthn.TimeoutsConfig{
+			Login:        webauthn.TimeoutConfig{Enforce: true, Timeout: ceremonyLifetime, TimeoutUVD: ceremonyLifetime},
+			Registration: webauthn.TimeoutConfig{Enforce: true, Timeout: ceremonyLifetime, TimeoutUVD: ceremonyLifetime},
+		},
+	}
+
+	return webauthn.New(config)
+}
+
+// webAuthnUser adapts a user and their passkeys to the webauthn.User
+// interface. The user handle is the user id, which carries no personal data.
+type webAuthnUser struct {
+	user     entity.User
+	passkeys []entity.Passkey
+}
+
+func (user webAuthnUser) WebAuthnID() []byte {
+	return []byte(user.user.Id)
+}
+
+func (user webAuthnUser) WebAuthnName() string {
+	return user.user.Email
+}
+
+func (user webAuthnUser) WebAuthnDisplayName() string {
+	return user.user.Email
+}
+
+func (user webAuthnUser) WebAuthnIcon() string {
+	return ""
+}
+
+func (user webAuthnUser) WebAuthnCredentials() []webauthn.Credential {
+	credentials := make([]webauthn.Credential, 0, len(user.passkeys))
+	for _, passkey := range user.passkeys {
+		id, err := base64.RawURLEncoding.DecodeString(passkey.Id)
+		if err != nil {
+			continue
+		}
+
+		transports := make([]protocol.AuthenticatorTransport, len(passkey.Transports))
+		for i, transport := range passkey.Transports {
+			transports[i] = protocol.AuthenticatorTransport(transport)
+		}
+
+		credentials = append(credentials, webauthn.Credential{
+			ID:              id,
+			PublicKey:       passkey.PublicKey,
+			AttestationType: passkey.AttestationType,
+			Transport:       transports,
+			Flags: webauthn.CredentialFlags{
+				BackupEligible: passkey.BackupEligible,
+				BackupState:    passkey.BackupState,
+			},
+			Authenticator: webauthn.Authenticator{
+				AAGUID:    passkey.AAGUID,
+				SignCount: passkey.SignCount,
+			},
+		})
+	}
+	return credentials
+}
+
+func (user webAuthnUser) descriptors() []protocol.CredentialDescriptor {
+	credentials := user.WebAuthnCredentials()
+	descriptors := make([]protocol.CredentialDescriptor, len(credentials))
+	for i, credential := range credentials {
+		descriptors[i] = credential.Descriptor()
+	}
+	return descriptors
+}
+
+func loadWebAuthnUser(ctx context.Context, users db.UserStore, passkeys db.PasskeyStore, userId string) (webAuthnUser, error) {
+	user, found, err := users.FindUserById(ctx, userId)
+	if err != nil {
+		return webAuthnUser{}, err
+	}
+	if !found {
+		message := "User not found"
+		return webAuthnUser{}, &util.CustomError{Message: message, Status: http.StatusNotFound}
+	}
+
+	registered, err := passkeys.GetPasskeys(ctx, userId)
+	if err != nil {
+		return webAuthnUser{}, err
+	}
+
+	return webAuthnUser{user: user, passkeys: registered}, nil
+}
+
+func saveCeremony(ctx context.Context, passkeys db.PasskeyStore, userId string, purpose string, session *webauthn.SessionData) (string, error) {
+	id, err := randomToken(16)
+	if err != nil {
+		return "", &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
+	}
+
+	data, err := json.Marshal(session)
+	if err != nil {
+		return "", &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
+	}
+
+	ceremony := entity.WebAuthnCeremony{
+		Id:        id,
+		UserId:    userId,
+		Purpose:   purpose,
+		Data:      data,
+		ExpiresAt: time.Now().Add(ceremonyLifetime).UTC(),
+	}
+	if err = passkeys.SaveCeremony(ctx, ceremony); err != nil {
+		return "", err
+	}
+
+	return id, nil
+}
+
+// takeCeremony consumes a ceremony started for one of purposes, rejecting
+// unknown, expired or mismatched ones alike.
+func takeCeremony(ctx context.Context, passkeys db.PasskeyStore, ceremonyId string, purposes ...string) (entity.WebAuthnCeremony, webauthn.SessionData, error) {
+	message := "Passkey request is invalid or expired"
+
+	ceremony, found, err := passkeys.TakeCeremony(ctx, ceremonyId)
+	if err != nil {
+		return entity.WebAuthnCeremony{}, webauthn.SessionData{}, err
+	}
+	expected := false
+	for _, purpose := range purposes {
+		expected = expected || ceremony.Purpose == purpose
+	}
+	if !found || !expected || ceremony.ExpiresAt.Before(time.Now()) {
+		return entity.WebAuthnCeremony{}, webauthn.SessionData{}, &util.CustomError{Message: message, Status: http.StatusBadRequest}
+	}
+
+	var session webauthn.SessionData
+	if err = json.Unmarshal(ceremony.Data, &session); err != nil {
+		return entity.WebAuthnCeremony{}, webauthn.SessionData{}, &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
+	}
+
+	return ceremony, session, nil
+}
+
+// passkeyError reports a failed WebAuthn verification without echoing the
+// library's details back to the client.
+func passkeyError(err error) error {
+	if _, ok := err.(*util.CustomError); ok {
+		return err
+	}
+	if protocolErr, ok := err.(*protocol.Error); ok {
+		err = fmt.Errorf("%w: %s", protocolErr, protocolErr.DevInfo)
+	}
+	return &util.CustomError{Message: "Passkey verification failed", Status: http.StatusUnauthorized, Cause: err}
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"password-manager/entity"
+	"password-manager/logger"
+	"password-manager/util"
+)
+
+const recoveryCodeCount = 10
+
+// SignInRecoveryCode completes a sign-in challenge with a recovery code
+// instead of a TOTP code. Each code is removed as it is used.
+func (service *authService) SignInRecoveryCode(ctx context.Context, challengeToken string, recoveryCode string, client entity.ClientInfo) (entity.SignInResult, error) {
+	ctx, span := tracer.Start(ctx, "AuthService.SignInRecoveryCode")
+	defer span.End()
+
+	user, err := service.parseChallenge(ctx, challengeToken)
+	if err != nil {
+		return entity.SignInResult{}, err
+	}
+
+	account, ip := accountKey("two-factor", user.Id), ipKey("two-factor", client.IP)
+	if err = service.guard.check(ctx, account, ip); err != nil {
+		return entity.SignInResult{}, err
+	}
+
+	used, err := service.users.UseRecoveryCode(ctx, user.Id, hashToken(util.NormalizeRecoveryCode(recoveryCode)))
+	if err != nil {
+		return entity.SignInResult{}, err
+	}
+	if !used {
+		service.guard.fail(ctx, account, ip)
+		message := "Recovery code is invalid"
+		return entity.SignInResult{}, &util.CustomError{Message: message, Status: http.StatusUnauthorized}
+	}
+	logger.Info(ctx, "recovery code used", "user_id", user.Id)
+	service.guard.succeed(ctx, account)
+
+	tokens, err := service.issueTokens(ctx, user.Id, user.PasswordSetAt, "", client)
+	if err != nil {
+		return entity.SignInResult{}, err
+	}
+
+	return entity.SignInResult{Tokens: tokens, Kdf: user.Kdf}, nil
+}
+
+// RegenerateRecoveryCodes replaces every remaining code with a fresh set.
+func (service *authService) RegenerateRecoveryCodes(ctx context.Context, userId string, password string) ([]string, error) {
+	ctx, span := tracer.Start(ctx, "AuthService.RegenerateRecoveryCodes")
+	defer span.End()
+
+	user, err := reauthenticate(ctx, service.users, service.guard, userId, password)
+	if err != nil {
+		return nil, err
+	}
+	if user.Totp == nil || !user.Totp.Enabled {
+		message := "Two-factor authentication is not enabled"
+		return nil, &util.CustomError{Message: message, Status: http.StatusBadRequest}
+	}
+
+	return service.issueRecoveryCodes(ctx, userId)
+}
+
+func (service *authService) CountRecoveryCodes(ctx context.Context, userId string) (int, error) {
+	ctx, span := tracer.Start(ctx, "AuthService.CountRecoveryCodes")
+	defer span.End()
+
+	user, found, err := service.users.FindUserById(ctx, userId)
+	if err != nil {
+		return 0, err
+	}
+	if !found {
+		message := "User not found"
+		return 0, &util.CustomError{Message: message, Status: http.StatusNotFound}
+	}
+
+	return len(user.RecoveryCodes), nil
+}
+
+// issueRecoveryCodes stores only the hashes of the new codes; the plain codes
+// are returned once for the user to write down.
+func (service *authService) issueRecoveryCodes(ctx context.Context, userId string) ([]string, error) {
+	codes, err := util.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
+	}
+
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = hashToken(util.NormalizeRecoveryCode(code))
+	}
+	if err = service.users.SetRecoveryCodes(ctx, userId, hashes); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
@@ -0,0 +1,51 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"password-manager/entity"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecoveryCodeUsedOnce(t *testing.T) {
+	ctx := context.Background()
+	service, _, userId := newTestAuthService(t)
+	_, codes := enableTotp(t, service, userId, time.Now().Unix()/30)
+	if len(codes) != recoveryCodeCount {
+		t.Fatalf("issued %d recovery codes, want %d", len(codes), recoveryCodeCount)
+	}
+
+	// Each case runs against the codes left by the ones before it.
+	tests := []struct {
+		name   string
+		code   string
+		status int
+	}{
+		{name: "unknown code", code: "aaaaa-aaaaa", status: http.StatusUnauthorized},
+		{name: "first code", code: codes[0]},
+		{name: "first code again", code: codes[0], status: http.StatusUnauthorized},
+		{name: "first code retyped", code: strings.ToUpper(strings.ReplaceAll(codes[0], "-", " ")), status: http.StatusUnauthorized},
+		{name: "second code retyped", code: strings.ToUpper(strings.ReplaceAll(codes[1], "-", " "))},
+		{name: "second code again", code: codes[1], status: http.StatusUnauthorized},
+	}
+	for _, test := range tests {
+		_, err := service.SignInRecoveryCode(ctx, challenge(t, service), test.code, entity.ClientInfo{})
+		if test.status == 0 {
+			if err != nil {
+				t.Fatalf("%s: %v", test.name, err)
+			}
+			continue
+		}
+		expectStatus(t, test.name, err, test.status)
+	}
+
+	remaining, err := service.CountRecoveryCodes(ctx, userId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if remaining != recoveryCodeCount-2 {
+		t.Fatalf("%d recovery codes left, want %d", remaining, recoveryCodeCount-2)
+	}
+}
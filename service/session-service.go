@@ -0,0 +1,88 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"password-manager/db"
+	"password-manager/entity"
+	"password-manager/util"
+	"time"
+)
+
+type SessionService interface {
+	GetSessions(ctx context.Context, userId string, currentSessionId string) (sessions []entity.Session, err error)
+	RevokeSession(ctx context.Context, userId string, sessionId string) (err error)
+	RevokeOtherSessions(ctx context.Context, userId string, currentSessionId string) (count int, err error)
+}
+
+type sessionService struct {
+	sessions      db.SessionStore
+	refreshTokens db.RefreshTokenStore
+}
+
+func NewSessionService(sessions db.SessionStore, refreshTokens db.RefreshTokenStore) SessionService {
+	return &sessionService{
+		sessions:      sessions,
+		refreshTokens: refreshTokens,
+	}
+}
+
+func (service *sessionService) GetSessions(ctx context.Context, userId string, currentSessionId string) ([]entity.Session, error) {
+	ctx, span := tracer.Start(ctx, "SessionService.GetSessions")
+	defer span.End()
+
+	sessions, err := service.sessions.ListSessions(ctx, userId, time.Now().UTC())
+	if err != nil {
+		return []entity.Session{}, err
+	}
+
+	for i := range sessions {
+		sessions[i].Current = sessions[i].Id == currentSessionId
+	}
+
+	return sessions, nil
+}
+
+// RevokeSession ends a session and its refresh token family. Access tokens
+// already issued for it are rejected by the auth middleware from then on.
+// Revocation is not abandoned when the client disconnects, so a session is
+// never left half revoked.
+func (service *sessionService) RevokeSession(ctx context.Context, userId string, sessionId string) error {
+	ctx, span := tracer.Start(ctx, "SessionService.RevokeSession")
+	defer span.End()
+	ctx = context.WithoutCancel(ctx)
+
+	revoked, err := service.sessions.RevokeSession(ctx, userId, sessionId)
+	if err != nil {
+		return err
+	}
+	if !revoked {
+		message := "Session not found"
+		return &util.CustomError{Message: message, Status: http.StatusNotFound}
+	}
+
+	if err = service.refreshTokens.RevokeRefreshTokenFamily(ctx, sessionId); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (service *sessionService) RevokeOtherSessions(ctx context.Context, userId string, currentSessionId string) (int, error) {
+	ctx, span := tracer.Start(ctx, "SessionService.RevokeOtherSessions")
+	defer span.End()
+	ctx = context.WithoutCancel(ctx)
+
+	sessionIds, err := service.sessions.RevokeOtherSessions(ctx, userId, currentSessionId)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, sessionId := range sessionIds {
+		if err = service.refreshTokens.RevokeRefreshTokenFamily(ctx, sessionId); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(sessionIds), nil
+}
@@ -1,31 +1,63 @@
 package service
 
 import (
+	"context"
 	"password-manager/db"
 	"password-manager/entity"
-	"password-manager/logger"
 	"password-manager/util"
+	"password-manager/vault"
+	"time"
 )
 
 type SiteService interface {
-	SaveSite(userId string, site entity.NewSiteRequest) (newSite entity.Site, err error)
-	GetSites(userId string) (sites []entity.Site, err error)
-	EditSite(userId string, siteId string, site entity.EditSiteRequest) (resultSite entity.Site, err error)
-	DeleteSite(userId string, siteId string) (err error)
+	SaveSite(ctx context.Context, userId string, site entity.NewSiteRequest) (newSite entity.Site, err error)
+	GetSites(ctx context.Context, userId string) (sites []entity.Site, err error)
+	GetSite(ctx context.Context, userId string, siteId string) (site entity.Site, err error)
+	EditSite(ctx context.Context, userId string, siteId string, site entity.EditSiteRequest) (resultSite entity.Site, err error)
+	DeleteSite(ctx context.Context, userId string, siteId string) (err error)
 }
 
-type siteService struct{}
+type siteService struct {
+	sites              db.SiteStore
+	vault              vault.Vault
+	imageLookupTimeout time.Duration
+}
+
+// NewSiteService looks up site logos for at most imageLookupTimeout, or for
+// as long as the request allows when it is zero.
+func NewSiteService(sites db.SiteStore, vault vault.Vault, imageLookupTimeout time.Duration) SiteService {
+	return &siteService{
+		sites:              sites,
+		vault:              vault,
+		imageLookupTimeout: imageLookupTimeout,
+	}
+}
 
-func NewSiteService() SiteService {
-	return &siteService{}
+// getImage finds the site's logo without letting a slow lookup hold up the
+// save; a lookup that times out just leaves the site without an image.
+func (service *siteService) getImage(ctx context.Context, url string) string {
+	if service.imageLookupTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, service.imageLookupTimeout)
+		defer cancel()
+	}
+	return util.GetImage(ctx, url)
 }
 
-func (service *siteService) SaveSite(userId string, site entity.NewSiteRequest) (newSite entity.Site, err error) {
+func (service *siteService) SaveSite(ctx context.Context, userId string, site entity.NewSiteRequest) (newSite entity.Site, err error) {
+	ctx, span := tracer.Start(ctx, "SiteService.SaveSite")
+	defer span.End()
+
 	newSite = entity.ConvertNewSiteToSite(site)
-	newSite.Image = util.GetImage(newSite.URL)
-	siteId, err := db.SaveSite(userId, newSite)
+	newSite.Image = service.getImage(ctx, newSite.URL)
+
+	encryptedSite, err := service.vault.EncryptSite(ctx, userId, newSite)
+	if err != nil {
+		return entity.Site{}, err
+	}
+
+	siteId, err := service.sites.SaveSite(ctx, userId, encryptedSite)
 	if err != nil {
-		logger.ErrorLogger.Println(err.Error())
 		return entity.Site{}, err
 	}
 
@@ -34,45 +66,83 @@ func (service *siteService) SaveSite(userId string, site entity.NewSiteRequest)
 	return newSite, nil
 }
 
-func (service *siteService) GetSites(userId string) (sites []entity.Site, err error) {
-	sites, err = db.GetSites(userId)
+func (service *siteService) GetSites(ctx context.Context, userId string) (sites []entity.Site, err error) {
+	ctx, span := tracer.Start(ctx, "SiteService.GetSites")
+	defer span.End()
+
+	sites, err = service.sites.GetSites(ctx, userId)
 	if err != nil {
-		logger.ErrorLogger.Println(err.Error())
+		return sites, err
+	}
+
+	for i, site := range sites {
+		if sites[i], err = service.vault.DecryptSite(ctx, userId, site); err != nil {
+			return []entity.Site{}, err
+		}
 	}
 
-	return sites, err
+	return sites, nil
 }
 
-func (service *siteService) EditSite(userId string, siteId string, updatedSite entity.EditSiteRequest) (resultSite entity.Site, err error) {
-	site, err := db.GetSite(userId, siteId)
+func (service *siteService) GetSite(ctx context.Context, userId string, siteId string) (site entity.Site, err error) {
+	ctx, span := tracer.Start(ctx, "SiteService.GetSite")
+	defer span.End()
+
+	site, err = service.sites.GetSite(ctx, userId, siteId)
 	if err != nil {
-		logger.ErrorLogger.Println(err.Error())
+		return entity.Site{}, err
+	}
+
+	if site, err = service.vault.DecryptSite(ctx, userId, site); err != nil {
+		return entity.Site{}, err
+	}
+
+	return site, nil
+}
+
+func (service *siteService) EditSite(ctx context.Context, userId string, siteId string, updatedSite entity.EditSiteRequest) (resultSite entity.Site, err error) {
+	ctx, span := tracer.Start(ctx, "SiteService.EditSite")
+	defer span.End()
+
+	site, err := service.sites.GetSite(ctx, userId, siteId)
+	if err != nil {
+		return entity.Site{}, err
+	}
+
+	if site, err = service.vault.DecryptSite(ctx, userId, site); err != nil {
 		return entity.Site{}, err
 	}
 
 	finalSite := entity.ConvertEditSiteToSite(updatedSite, site)
 	if updatedSite.URL != site.URL {
-		finalSite.Image = util.GetImage(finalSite.URL)
+		finalSite.Image = service.getImage(ctx, finalSite.URL)
 	}
-	resultSite, err = db.EditSite(siteId, finalSite)
+
+	encryptedSite, err := service.vault.EncryptSite(ctx, userId, finalSite)
 	if err != nil {
-		logger.ErrorLogger.Println(err.Error())
+		return entity.Site{}, err
 	}
 
-	return resultSite, err
-}
-
-func (service *siteService) DeleteSite(userId string, siteId string) (err error) {
-	_, err = db.GetSite(userId, siteId)
+	resultSite, err = service.sites.EditSite(ctx, siteId, encryptedSite)
 	if err != nil {
-		logger.ErrorLogger.Println(err.Error())
-		return err
+		return entity.Site{}, err
+	}
+
+	if resultSite, err = service.vault.DecryptSite(ctx, userId, resultSite); err != nil {
+		return entity.Site{}, err
 	}
 
-	err = db.DeleteSite(userId, siteId)
+	return resultSite, nil
+}
+
+func (service *siteService) DeleteSite(ctx context.Context, userId string, siteId string) (err error) {
+	ctx, span := tracer.Start(ctx, "SiteService.DeleteSite")
+	defer span.End()
+
+	_, err = service.sites.GetSite(ctx, userId, siteId)
 	if err != nil {
-		logger.ErrorLogger.Println(err.Error())
+		return err
 	}
 
-	return err
+	return service.sites.DeleteSite(ctx, userId, siteId)
 }
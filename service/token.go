@@ -0,0 +1,160 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"password-manager/entity"
+	"password-manager/util"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+const (
+	challengeLifetime = time.Minute * 5
+	challengePurpose  = "mfa"
+)
+
+type TokenLifetimes struct {
+	AccessToken  time.Duration
+	RefreshToken time.Duration
+}
+
+// issueTokens signs an access token and stores a new refresh token for the
+// given family. An empty familyId starts a new family and records a session
+// under the same id; the id is carried in the access token as the "sid"
+// claim.
+func (service *authService) issueTokens(ctx context.Context, userId string, passwordSetAt string, familyId string, client entity.ClientInfo) (entity.AuthTokens, error) {
+	newSession := familyId == ""
+	if newSession {
+		id, err := randomToken(16)
+		if err != nil {
+			return entity.AuthTokens{}, &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
+		}
+		familyId = id
+	}
+
+	now := time.Now()
+
+	claims := jwt.MapClaims{}
+	claims["exp"] = now.Add(service.lifetimes.AccessToken).Unix()
+	claims["passwordSetAt"] = passwordSetAt
+	claims["id"] = userId
+	claims["sid"] = familyId
+
+	accessToken, err := service.keys.Sign(claims)
+	if err != nil {
+		return entity.AuthTokens{}, &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
+	}
+
+	refreshToken, err := randomToken(32)
+	if err != nil {
+		return entity.AuthTokens{}, &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
+	}
+
+	record := entity.RefreshToken{
+		TokenHash:     hashToken(refreshToken),
+		FamilyId:      familyId,
+		UserId:        userId,
+		PasswordSetAt: passwordSetAt,
+		CreatedAt:     now.UTC(),
+		ExpiresAt:     now.Add(service.lifetimes.RefreshToken).UTC(),
+	}
+	if err = service.refreshTokens.CreateRefreshToken(ctx, record); err != nil {
+		return entity.AuthTokens{}, err
+	}
+
+	if newSession {
+		err = service.sessions.CreateSession(ctx, entity.Session{
+			Id:         familyId,
+			UserId:     userId,
+			UserAgent:  client.UserAgent,
+			IP:         client.IP,
+			CreatedAt:  record.CreatedAt,
+			LastSeenAt: record.CreatedAt,
+			ExpiresAt:  record.ExpiresAt,
+		})
+	} else {
+		if err = service.sessions.ExtendSession(ctx, familyId, record.ExpiresAt); err == nil {
+			err = service.sessions.TouchSession(ctx, familyId, record.CreatedAt, client.IP)
+		}
+	}
+	if err != nil {
+		return entity.AuthTokens{}, err
+	}
+
+	return entity.AuthTokens{
+		AccessToken:      accessToken,
+		RefreshToken:     refreshToken,
+		RefreshExpiresAt: record.ExpiresAt,
+	}, nil
+}
+
+// issueChallenge signs the short-lived token returned by the first sign-in
+// step when a second factor is required. The "purpose" claim keeps it from
+// being accepted as an access token.
+func (service *authService) issueChallenge(user entity.User, methods []string) (*entity.MfaChallenge, error) {
+	expiresAt := time.Now().Add(challengeLifetime)
+
+	claims := jwt.MapClaims{}
+	claims["exp"] = expiresAt.Unix()
+	claims["passwordSetAt"] = user.PasswordSetAt
+	claims["id"] = user.Id
+	claims["purpose"] = challengePurpose
+
+	token, err := service.keys.Sign(claims)
+	if err != nil {
+		return nil, &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
+	}
+
+	return &entity.MfaChallenge{
+		Token:     token,
+		ExpiresAt: expiresAt.UTC(),
+		Methods:   methods,
+	}, nil
+}
+
+// parseChallenge returns the user a challenge token was issued to, provided
+// it is unexpired and their password has not changed since.
+func (service *authService) parseChallenge(ctx context.Context, challengeToken string) (entity.User, error) {
+	message := "Challenge token is invalid or expired"
+
+	token, err := jwt.Parse(challengeToken, service.keys.Keyfunc)
+	if err != nil {
+		return entity.User{}, &util.CustomError{Message: message, Status: http.StatusUnauthorized, Cause: err}
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid || claims["purpose"] != challengePurpose {
+		return entity.User{}, &util.CustomError{Message: message, Status: http.StatusUnauthorized}
+	}
+	userId, _ := claims["id"].(string)
+	passwordSetAt, _ := claims["passwordSetAt"].(string)
+
+	user, found, err := service.users.FindUserById(ctx, userId)
+	if err != nil {
+		return entity.User{}, err
+	}
+	if !found || user.PasswordSetAt != passwordSetAt {
+		return entity.User{}, &util.CustomError{Message: message, Status: http.StatusUnauthorized}
+	}
+
+	return user, nil
+}
+
+func randomToken(size int) (string, error) {
+	token := make([]byte, size)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(token), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
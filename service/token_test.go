@@ -0,0 +1,51 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"password-manager/entity"
+	"testing"
+	"time"
+)
+
+func TestRefreshTokenReuseRevokesFamily(t *testing.T) {
+	ctx := context.Background()
+	service, stores, userId := newTestAuthService(t)
+
+	signedIn, err := service.SignIn(ctx, testEmail, testPassword, entity.ClientInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := signedIn.Tokens.RefreshToken
+
+	refreshed, err := service.RefreshToken(ctx, first, entity.ClientInfo{})
+	if err != nil {
+		t.Fatalf("RefreshToken: %v", err)
+	}
+	second := refreshed.RefreshToken
+	if second == "" || second == first {
+		t.Fatalf("RefreshToken did not rotate the refresh token")
+	}
+	if sessions, _ := stores.Sessions.ListSessions(ctx, userId, time.Now()); len(sessions) != 1 {
+		t.Fatalf("rotation left %d sessions, want 1", len(sessions))
+	}
+
+	_, err = service.RefreshToken(ctx, first, entity.ClientInfo{})
+	expectStatus(t, "replaying a used refresh token", err, http.StatusUnauthorized)
+
+	// The replay revokes the newer token in the family and its session too.
+	_, err = service.RefreshToken(ctx, second, entity.ClientInfo{})
+	expectStatus(t, "refreshing after the family was revoked", err, http.StatusUnauthorized)
+	if sessions, _ := stores.Sessions.ListSessions(ctx, userId, time.Now()); len(sessions) != 0 {
+		t.Fatalf("session survived refresh token reuse")
+	}
+
+	// Other sign-ins are separate families and keep working.
+	signedIn, err = service.SignIn(ctx, testEmail, testPassword, entity.ClientInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = service.RefreshToken(ctx, signedIn.Tokens.RefreshToken, entity.ClientInfo{}); err != nil {
+		t.Fatalf("RefreshToken for a new sign-in: %v", err)
+	}
+}
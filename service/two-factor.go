@@ -0,0 +1,207 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"password-manager/db"
+	"password-manager/entity"
+	"password-manager/util"
+	"time"
+)
+
+const totpSecretField = "totpSecret"
+
+// EnrollTotp starts enrollment with a fresh secret. The secret only replaces
+// the current one once ConfirmTotp sees a code from it, and re-enrolling an
+// account that already has TOTP enabled also needs a current code.
+func (service *authService) EnrollTotp(ctx context.Context, userId string, password string, code string) (string, string, error) {
+	ctx, span := tracer.Start(ctx, "AuthService.EnrollTotp")
+	defer span.End()
+
+	user, err := reauthenticate(ctx, service.users, service.guard, userId, password)
+	if err != nil {
+		return "", "", err
+	}
+
+	settings := entity.TotpSettings{}
+	if user.Totp != nil {
+		settings = *user.Totp
+	}
+	if settings.Enabled {
+		if settings.LastUsedStep, err = service.verifyTotp(ctx, user, code); err != nil {
+			return "", "", err
+		}
+	}
+
+	secret, err := util.GenerateTotpSecret()
+	if err != nil {
+		return "", "", &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
+	}
+
+	if settings.PendingSecret, settings.PendingKeyId, err = service.secrets.EncryptSecret(ctx, userId, totpSecretField, secret); err != nil {
+		return "", "", err
+	}
+	if err = service.users.SetTotp(ctx, userId, &settings); err != nil {
+		return "", "", err
+	}
+
+	return secret, util.TotpURI(service.totpIssuer, user.Email, secret), nil
+}
+
+// ConfirmTotp enables the pending secret once a code from it is seen and
+// issues a fresh set of recovery codes.
+func (service *authService) ConfirmTotp(ctx context.Context, userId string, code string) ([]string, error) {
+	ctx, span := tracer.Start(ctx, "AuthService.ConfirmTotp")
+	defer span.End()
+
+	user, found, err := service.users.FindUserById(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	if !found || user.Totp == nil || user.Totp.PendingSecret == "" {
+		message := "TOTP enrollment has not been started"
+		return nil, &util.CustomError{Message: message, Status: http.StatusBadRequest}
+	}
+
+	secret, err := service.secrets.DecryptSecret(ctx, userId, totpSecretField, user.Totp.PendingKeyId, user.Totp.PendingSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	step, valid := util.ValidateTotp(secret, code, now)
+	if !valid {
+		message := "Two-factor code is invalid"
+		return nil, &util.CustomError{Message: message, Status: http.StatusUnauthorized}
+	}
+
+	enabledAt := now.UTC()
+	settings := entity.TotpSettings{
+		Secret:       user.Totp.PendingSecret,
+		KeyId:        user.Totp.PendingKeyId,
+		Enabled:      true,
+		EnabledAt:    &enabledAt,
+		LastUsedStep: step,
+	}
+	if err = service.users.SetTotp(ctx, userId, &settings); err != nil {
+		return nil, err
+	}
+
+	return service.issueRecoveryCodes(ctx, userId)
+}
+
+func (service *authService) DisableTotp(ctx context.Context, userId string, password string, code string) error {
+	ctx, span := tracer.Start(ctx, "AuthService.DisableTotp")
+	defer span.End()
+
+	user, err := reauthenticate(ctx, service.users, service.guard, userId, password)
+	if err != nil {
+		return err
+	}
+	if user.Totp == nil || !user.Totp.Enabled {
+		message := "TOTP is not enabled"
+		return &util.CustomError{Message: message, Status: http.StatusBadRequest}
+	}
+
+	if _, err = service.verifyTotp(ctx, user, code); err != nil {
+		return err
+	}
+
+	if err = service.users.SetTotp(ctx, userId, nil); err != nil {
+		return err
+	}
+	if err = service.users.SetRecoveryCodes(ctx, userId, nil); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// SignInTotp completes a sign-in that SignIn answered with a challenge.
+func (service *authService) SignInTotp(ctx context.Context, challengeToken string, code string, client entity.ClientInfo) (entity.SignInResult, error) {
+	ctx, span := tracer.Start(ctx, "AuthService.SignInTotp")
+	defer span.End()
+
+	user, err := service.parseChallenge(ctx, challengeToken)
+	if err != nil {
+		return entity.SignInResult{}, err
+	}
+
+	account, ip := accountKey("two-factor", user.Id), ipKey("two-factor", client.IP)
+	if err = service.guard.check(ctx, account, ip); err != nil {
+		return entity.SignInResult{}, err
+	}
+	if _, err = service.verifyTotp(ctx, user, code); err != nil {
+		if rejected(err) {
+			service.guard.fail(ctx, account, ip)
+		}
+		return entity.SignInResult{}, err
+	}
+	service.guard.succeed(ctx, account)
+
+	tokens, err := service.issueTokens(ctx, user.Id, user.PasswordSetAt, "", client)
+	if err != nil {
+		return entity.SignInResult{}, err
+	}
+
+	return entity.SignInResult{Tokens: tokens, Kdf: user.Kdf}, nil
+}
+
+// reauthenticate confirms the signed-in user's password before a change to
+// their sign-in methods. Failures count towards the same lockout whichever
+// service asks.
+func reauthenticate(ctx context.Context, users db.UserStore, guard *AttemptGuard, userId string, password string) (entity.User, error) {
+	account := accountKey("reauthenticate", userId)
+	if err := guard.check(ctx, account); err != nil {
+		return entity.User{}, err
+	}
+
+	user, found, err := users.FindUserById(ctx, userId)
+	if err != nil {
+		return entity.User{}, err
+	}
+	validCredentials := false
+	if found {
+		if validCredentials, err = checkPassword(ctx, users, user, password); err != nil {
+			return entity.User{}, err
+		}
+	}
+	if !validCredentials {
+		guard.fail(ctx, account)
+		message := "Password is wrong"
+		return entity.User{}, &util.CustomError{Message: message, Status: http.StatusUnauthorized}
+	}
+	guard.succeed(ctx, account)
+
+	return user, nil
+}
+
+// verifyTotp checks a code from the enabled authenticator and consumes its
+// time step so the same code cannot be replayed.
+func (service *authService) verifyTotp(ctx context.Context, user entity.User, code string) (int64, error) {
+	message := "Two-factor code is invalid"
+	if user.Totp == nil || !user.Totp.Enabled {
+		return 0, &util.CustomError{Message: message, Status: http.StatusUnauthorized, Cause: errors.New("TOTP is not enabled")}
+	}
+
+	secret, err := service.secrets.DecryptSecret(ctx, user.Id, totpSecretField, user.Totp.KeyId, user.Totp.Secret)
+	if err != nil {
+		return 0, err
+	}
+
+	step, valid := util.ValidateTotp(secret, code, time.Now())
+	if !valid {
+		return 0, &util.CustomError{Message: message, Status: http.StatusUnauthorized}
+	}
+
+	accepted, err := service.users.UseTotpStep(ctx, user.Id, step)
+	if err != nil {
+		return 0, err
+	}
+	if !accepted {
+		return 0, &util.CustomError{Message: message, Status: http.StatusUnauthorized, Cause: errors.New("TOTP code replayed")}
+	}
+
+	return step, nil
+}
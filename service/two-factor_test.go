@@ -0,0 +1,90 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/http"
+	"password-manager/entity"
+	"testing"
+	"time"
+)
+
+// totpAt computes the code for secret at step, as an authenticator app would.
+// Tests name steps relative to one read of the clock so that crossing a step
+// boundary mid-test does not change which codes are fresh.
+func totpAt(t *testing.T, secret string, step int64) string {
+	t.Helper()
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	counter := make([]byte, 8)
+	binary.BigEndian.PutUint64(counter, uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter)
+	sum := mac.Sum(nil)
+	index := sum[len(sum)-1] & 0x0f
+	return fmt.Sprintf("%06d", (binary.BigEndian.Uint32(sum[index:index+4])&0x7fffffff)%1000000)
+}
+
+// enableTotp enrolls the test user with a code for step and returns the
+// secret and recovery codes.
+func enableTotp(t *testing.T, service *authService, userId string, step int64) (string, []string) {
+	t.Helper()
+	ctx := context.Background()
+	secret, _, err := service.EnrollTotp(ctx, userId, testPassword, "")
+	if err != nil {
+		t.Fatalf("EnrollTotp: %v", err)
+	}
+	codes, err := service.ConfirmTotp(ctx, userId, totpAt(t, secret, step))
+	if err != nil {
+		t.Fatalf("ConfirmTotp: %v", err)
+	}
+	return secret, codes
+}
+
+func challenge(t *testing.T, service *authService) string {
+	t.Helper()
+	result, err := service.SignIn(context.Background(), testEmail, testPassword, entity.ClientInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Challenge == nil {
+		t.Fatal("SignIn issued tokens without a second factor")
+	}
+	return result.Challenge.Token
+}
+
+func TestTotpStepUsedOnce(t *testing.T) {
+	ctx := context.Background()
+	service, _, userId := newTestAuthService(t)
+	step := time.Now().Unix() / 30
+	secret, _ := enableTotp(t, service, userId, step)
+
+	// Each case runs against the steps accepted by the ones before it.
+	tests := []struct {
+		name   string
+		offset int64
+		status int
+	}{
+		{name: "code used to confirm enrollment", offset: 0, status: http.StatusUnauthorized},
+		{name: "earlier step", offset: -1, status: http.StatusUnauthorized},
+		{name: "next step", offset: 1},
+		{name: "next step replayed", offset: 1, status: http.StatusUnauthorized},
+		{name: "current step after a later one", offset: 0, status: http.StatusUnauthorized},
+	}
+	for _, test := range tests {
+		_, err := service.SignInTotp(ctx, challenge(t, service), totpAt(t, secret, step+test.offset), entity.ClientInfo{})
+		if test.status == 0 {
+			if err != nil {
+				t.Fatalf("%s: %v", test.name, err)
+			}
+			continue
+		}
+		expectStatus(t, test.name, err, test.status)
+	}
+}
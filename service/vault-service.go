@@ -0,0 +1,198 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"password-manager/db"
+	"password-manager/entity"
+	"password-manager/util"
+	"regexp"
+	"time"
+)
+
+const maxVaultBlobSize = 64 * 1024
+
+var vaultItemIdPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// VaultService stores client-encrypted items for the zero-knowledge vault.
+// Blobs are opaque to the server; it only tracks revisions so that clients
+// can sync and detect conflicting writes.
+type VaultService interface {
+	GetKdfParams(ctx context.Context, userId string) (params entity.KdfParams, err error)
+	SetKdfParams(ctx context.Context, userId string, params entity.KdfParams, revision int64, password string) (saved entity.KdfParams, err error)
+	SyncItems(ctx context.Context, userId string, since time.Time) (items []entity.VaultItem, serverTime time.Time, err error)
+	GetItem(ctx context.Context, userId string, itemId string) (item entity.VaultItem, err error)
+	PutItem(ctx context.Context, userId string, itemId string, revision int64, blob string) (item entity.VaultItem, err error)
+	DeleteItem(ctx context.Context, userId string, itemId string, revision int64) (item entity.VaultItem, err error)
+}
+
+type vaultService struct {
+	users db.UserStore
+	items db.VaultItemStore
+	guard *AttemptGuard
+}
+
+func NewVaultService(users db.UserStore, items db.VaultItemStore, attempts db.AttemptStore) VaultService {
+	return &vaultService{
+		users: users,
+		items: items,
+		guard: NewAttemptGuard(attempts),
+	}
+}
+
+func (service *vaultService) GetKdfParams(ctx context.Context, userId string) (entity.KdfParams, error) {
+	ctx, span := tracer.Start(ctx, "VaultService.GetKdfParams")
+	defer span.End()
+
+	user, found, err := service.users.FindUserById(ctx, userId)
+	if err != nil {
+		return entity.KdfParams{}, err
+	}
+	if !found || user.Kdf == nil {
+		message := "KDF parameters are not set"
+		return entity.KdfParams{}, &util.CustomError{Message: message, Status: http.StatusNotFound}
+	}
+
+	return *user.Kdf, nil
+}
+
+// SetKdfParams saves params as the revision after revision. New parameters
+// make every existing item undecryptable unless the client has re-encrypted
+// them, so replacing parameters that are already set needs the password too.
+func (service *vaultService) SetKdfParams(ctx context.Context, userId string, params entity.KdfParams, revision int64, password string) (entity.KdfParams, error) {
+	ctx, span := tracer.Start(ctx, "VaultService.SetKdfParams")
+	defer span.End()
+
+	if message := validateKdfParams(params); message != "" {
+		return entity.KdfParams{}, &util.CustomError{Message: message, Status: http.StatusBadRequest}
+	}
+	if revision < 0 {
+		message := "Revision cannot be negative"
+		return entity.KdfParams{}, &util.CustomError{Message: message, Status: http.StatusBadRequest}
+	}
+
+	if revision != 0 {
+		if _, err := reauthenticate(ctx, service.users, service.guard, userId, password); err != nil {
+			return entity.KdfParams{}, err
+		}
+	}
+
+	params.Revision = revision + 1
+	written, err := service.users.SetKdfParams(ctx, userId, params, revision)
+	if err != nil {
+		return entity.KdfParams{}, err
+	}
+	if !written {
+		message := "KDF parameters have been modified, fetch and retry"
+		return entity.KdfParams{}, &util.CustomError{Message: message, Status: http.StatusConflict}
+	}
+
+	return params, nil
+}
+
+func (service *vaultService) SyncItems(ctx context.Context, userId string, since time.Time) ([]entity.VaultItem, time.Time, error) {
+	ctx, span := tracer.Start(ctx, "VaultService.SyncItems")
+	defer span.End()
+
+	serverTime := time.Now().UTC()
+
+	items, err := service.items.GetItems(ctx, userId, since)
+	if err != nil {
+		return []entity.VaultItem{}, time.Time{}, err
+	}
+
+	return items, serverTime, nil
+}
+
+func (service *vaultService) GetItem(ctx context.Context, userId string, itemId string) (entity.VaultItem, error) {
+	ctx, span := tracer.Start(ctx, "VaultService.GetItem")
+	defer span.End()
+
+	item, found, err := service.items.GetItem(ctx, userId, itemId)
+	if err != nil {
+		return entity.VaultItem{}, err
+	}
+	if !found {
+		message := "Item not found"
+		return entity.VaultItem{}, &util.CustomError{Message: message, Status: http.StatusNotFound}
+	}
+
+	return item, nil
+}
+
+func (service *vaultService) PutItem(ctx context.Context, userId string, itemId string, revision int64, blob string) (entity.VaultItem, error) {
+	ctx, span := tracer.Start(ctx, "VaultService.PutItem")
+	defer span.End()
+
+	if !vaultItemIdPattern.MatchString(itemId) {
+		message := "Item Id must be 1-64 letters, digits, '-' or '_'"
+		return entity.VaultItem{}, &util.CustomError{Message: message, Status: http.StatusBadRequest}
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(blob)
+	if err != nil || len(decoded) == 0 || len(decoded) > maxVaultBlobSize {
+		message := "Blob must be base64 encoded and at most 64 KiB"
+		return entity.VaultItem{}, &util.CustomError{Message: message, Status: http.StatusBadRequest}
+	}
+
+	return service.write(ctx, userId, entity.VaultItem{Id: itemId, Blob: blob}, revision)
+}
+
+func (service *vaultService) DeleteItem(ctx context.Context, userId string, itemId string, revision int64) (entity.VaultItem, error) {
+	ctx, span := tracer.Start(ctx, "VaultService.DeleteItem")
+	defer span.End()
+
+	if revision == 0 {
+		message := "Revision is required"
+		return entity.VaultItem{}, &util.CustomError{Message: message, Status: http.StatusBadRequest}
+	}
+
+	return service.write(ctx, userId, entity.VaultItem{Id: itemId, Deleted: true}, revision)
+}
+
+// write stores item as the revision after expectedRevision. Deleted items are
+// kept as tombstones so other devices learn about the delete on their next
+// sync.
+func (service *vaultService) write(ctx context.Context, userId string, item entity.VaultItem, expectedRevision int64) (entity.VaultItem, error) {
+	if expectedRevision < 0 {
+		message := "Revision cannot be negative"
+		return entity.VaultItem{}, &util.CustomError{Message: message, Status: http.StatusBadRequest}
+	}
+
+	item.Revision = expectedRevision + 1
+	item.UpdatedAt = time.Now().UTC()
+
+	written, err := service.items.PutItem(ctx, userId, item, expectedRevision)
+	if err != nil {
+		return entity.VaultItem{}, err
+	}
+	if !written {
+		message := "Item has been modified, sync and retry"
+		return entity.VaultItem{}, &util.CustomError{Message: message, Status: http.StatusConflict}
+	}
+
+	return item, nil
+}
+
+func validateKdfParams(params entity.KdfParams) string {
+	salt, err := base64.StdEncoding.DecodeString(params.Salt)
+	if err != nil || len(salt) < 16 {
+		return "Salt must be at least 16 base64 encoded bytes"
+	}
+
+	switch params.Algorithm {
+	case "argon2id":
+		if params.Iterations < 2 || params.Memory < 19*1024 || params.Parallelism < 1 {
+			return "argon2id requires at least 2 iterations, 19456 KiB of memory and parallelism of 1"
+		}
+	case "pbkdf2-sha256":
+		if params.Iterations < 600000 || params.Memory != 0 || params.Parallelism != 0 {
+			return "pbkdf2-sha256 requires at least 600000 iterations and no memory or parallelism"
+		}
+	default:
+		return "Algorithm can either be 'argon2id' or 'pbkdf2-sha256'"
+	}
+
+	return ""
+}
@@ -0,0 +1,54 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"password-manager/db"
+	"password-manager/entity"
+	"password-manager/util"
+	"testing"
+)
+
+func TestSetKdfParamsNeedsRevisionAndPassword(t *testing.T) {
+	cheapPasswordHashes(t)
+
+	ctx := context.Background()
+	stores := db.NewMemoryStores()
+	vault := NewVaultService(stores.Users, stores.Items, stores.Attempts)
+
+	password := "correct horse battery staple"
+	passwordHash, err := util.HashPassword(password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	userId, err := stores.Users.RegisterUser(ctx, "kdf@example.com", passwordHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	params := entity.KdfParams{Algorithm: "pbkdf2-sha256", Salt: "AAAAAAAAAAAAAAAAAAAAAA==", Iterations: 600000}
+	saved, err := vault.SetKdfParams(ctx, userId, params, 0, "")
+	if err != nil {
+		t.Fatalf("first SetKdfParams: %v", err)
+	}
+	if saved.Revision != 1 {
+		t.Fatalf("first revision = %d, want 1", saved.Revision)
+	}
+
+	_, err = vault.SetKdfParams(ctx, userId, params, 0, "")
+	expectStatus(t, "SetKdfParams over existing parameters at revision 0", err, http.StatusConflict)
+
+	_, err = vault.SetKdfParams(ctx, userId, params, 1, "wrong password")
+	expectStatus(t, "SetKdfParams with a wrong password", err, http.StatusUnauthorized)
+
+	_, err = vault.SetKdfParams(ctx, userId, params, 2, password)
+	expectStatus(t, "SetKdfParams at a stale revision", err, http.StatusConflict)
+
+	params.Iterations = 700000
+	if saved, err = vault.SetKdfParams(ctx, userId, params, 1, password); err != nil {
+		t.Fatalf("SetKdfParams with the password: %v", err)
+	}
+	if saved.Revision != 2 || saved.Iterations != 700000 {
+		t.Fatalf("saved %+v, want revision 2 with 700000 iterations", saved)
+	}
+}
@@ -0,0 +1,243 @@
+package signing
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt"
+)
+
+const (
+	defaultKeyId      = "default"
+	minHmacSecretSize = 32
+)
+
+var ErrNoSigningKey = errors.New("JWT_KEYS must be set")
+
+type Key struct {
+	Id        string
+	method    jwt.SigningMethod
+	signKey   interface{}
+	verifyKey interface{}
+}
+
+// KeySet signs tokens with its active key and verifies tokens signed by any
+// key it holds, so retired keys can stay in the set until their tokens expire.
+type KeySet struct {
+	active *Key
+	keys   map[string]*Key
+}
+
+func NewKeySet(keys []*Key, activeId string) (*KeySet, error) {
+	set := &KeySet{keys: map[string]*Key{}}
+	for _, key := range keys {
+		if _, exists := set.keys[key.Id]; exists {
+			return nil, fmt.Errorf("signing key %q is listed twice", key.Id)
+		}
+		set.keys[key.Id] = key
+	}
+
+	active, ok := set.keys[activeId]
+	if !ok {
+		return nil, fmt.Errorf("active signing key %q is not in the key set", activeId)
+	}
+	set.active = active
+
+	return set, nil
+}
+
+// ParseKeySet reads signing keys as configured in JWT_KEYS. Entries are
+// separated by commas or newlines and written as "kid:alg:value", where value
+// is a base64 secret for HS256 or the path of a PEM private key for RS256 and
+// EdDSA. A bare base64 secret is an HS256 key with the id "default"; only one
+// key may be bare, and "kid:value" is refused rather than guessed at. activeId
+// selects the key used to sign new tokens and is required when several keys
+// are configured.
+func ParseKeySet(encoded string, activeId string) (*KeySet, error) {
+	keys := []*Key{}
+	ids := map[string]bool{}
+	entries := strings.FieldsFunc(encoded, func(r rune) bool {
+		return r == ',' || r == '\n' || r == '\r'
+	})
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		id, algorithm, value := defaultKeyId, jwt.SigningMethodHS256.Alg(), entry
+		switch parts := strings.SplitN(entry, ":", 3); len(parts) {
+		case 2:
+			return nil, fmt.Errorf("signing key %q: entries are written as \"kid:alg:value\", for example \"%s:HS256:<secret>\"", strings.TrimSpace(parts[0]), strings.TrimSpace(parts[0]))
+		case 3:
+			id, algorithm, value = strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]), strings.TrimSpace(parts[2])
+		}
+		if ids[id] {
+			return nil, fmt.Errorf("signing key %q is listed twice; give every key its own kid", id)
+		}
+		ids[id] = true
+
+		key, err := parseKey(id, algorithm, value)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	if len(keys) == 0 {
+		return nil, ErrNoSigningKey
+	}
+	if activeId == "" {
+		if len(keys) > 1 {
+			return nil, errors.New("JWT_KEY_ID must be set when several signing keys are configured")
+		}
+		activeId = keys[0].Id
+	}
+
+	return NewKeySet(keys, activeId)
+}
+
+func NewEphemeralKeySet() (*KeySet, error) {
+	secret := make([]byte, minHmacSecretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+
+	key, err := NewHmacKey("ephemeral", secret)
+	if err != nil {
+		return nil, err
+	}
+	return NewKeySet([]*Key{key}, key.Id)
+}
+
+func NewHmacKey(id string, secret []byte) (*Key, error) {
+	if len(secret) < minHmacSecretSize {
+		return nil, fmt.Errorf("signing key %q: HS256 secrets must be at least %d bytes", id, minHmacSecretSize)
+	}
+	return &Key{Id: id, method: jwt.SigningMethodHS256, signKey: secret, verifyKey: secret}, nil
+}
+
+func NewRsaKey(id string, privateKey *rsa.PrivateKey) (*Key, error) {
+	if privateKey.N.BitLen() < 2048 {
+		return nil, fmt.Errorf("signing key %q: RS256 keys must be at least 2048 bits", id)
+	}
+	return &Key{Id: id, method: jwt.SigningMethodRS256, signKey: privateKey, verifyKey: &privateKey.PublicKey}, nil
+}
+
+func NewEd25519Key(id string, privateKey ed25519.PrivateKey) *Key {
+	return &Key{Id: id, method: jwt.SigningMethodEdDSA, signKey: privateKey, verifyKey: privateKey.Public()}
+}
+
+func parseKey(id string, algorithm string, value string) (*Key, error) {
+	switch algorithm {
+	case jwt.SigningMethodHS256.Alg():
+		secret, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return nil, fmt.Errorf("signing key %q must be base64 encoded", id)
+		}
+		return NewHmacKey(id, secret)
+	case jwt.SigningMethodRS256.Alg():
+		contents, err := os.ReadFile(value)
+		if err != nil {
+			return nil, fmt.Errorf("signing key %q: %w", id, err)
+		}
+		privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(contents)
+		if err != nil {
+			return nil, fmt.Errorf("signing key %q: %w", id, err)
+		}
+		return NewRsaKey(id, privateKey)
+	case jwt.SigningMethodEdDSA.Alg():
+		contents, err := os.ReadFile(value)
+		if err != nil {
+			return nil, fmt.Errorf("signing key %q: %w", id, err)
+		}
+		privateKey, err := jwt.ParseEdPrivateKeyFromPEM(contents)
+		if err != nil {
+			return nil, fmt.Errorf("signing key %q: %w", id, err)
+		}
+		return NewEd25519Key(id, privateKey.(ed25519.PrivateKey)), nil
+	default:
+		return nil, fmt.Errorf("signing key %q: algorithm must be HS256, RS256 or EdDSA", id)
+	}
+}
+
+func (set *KeySet) ActiveId() string {
+	return set.active.Id
+}
+
+func (set *KeySet) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(set.active.method, claims)
+	token.Header["kid"] = set.active.Id
+	return token.SignedString(set.active.signKey)
+}
+
+// Keyfunc resolves the verification key from the token's kid header and
+// refuses tokens whose alg does not match that key.
+func (set *KeySet) Keyfunc(token *jwt.Token) (interface{}, error) {
+	id, _ := token.Header["kid"].(string)
+	key, ok := set.keys[id]
+	if !ok {
+		return nil, errors.New("unknown signing key")
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, errors.New("unexpected signing method")
+	}
+	return key.verifyKey, nil
+}
+
+type JSONWebKey struct {
+	KeyType   string `json:"kty"`
+	KeyId     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+}
+
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// JWKS lists the public halves of the asymmetric keys. HS256 secrets are
+// never published, so services that only see the JWKS cannot verify them.
+func (set *KeySet) JWKS() JSONWebKeySet {
+	jwks := JSONWebKeySet{Keys: []JSONWebKey{}}
+	for _, key := range set.keys {
+		if jwk, ok := publicJWK(key); ok {
+			jwks.Keys = append(jwks.Keys, jwk)
+		}
+	}
+	sort.Slice(jwks.Keys, func(i, j int) bool {
+		return jwks.Keys[i].KeyId < jwks.Keys[j].KeyId
+	})
+	return jwks
+}
+
+func publicJWK(key *Key) (JSONWebKey, bool) {
+	jwk := JSONWebKey{KeyId: key.Id, Use: "sig", Algorithm: key.method.Alg()}
+
+	switch publicKey := key.verifyKey.(type) {
+	case *rsa.PublicKey:
+		jwk.KeyType = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.KeyType = "OKP"
+		jwk.Curve = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(publicKey)
+	default:
+		return JSONWebKey{}, false
+	}
+
+	return jwk, true
+}
@@ -0,0 +1,166 @@
+package signing
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/golang-jwt/jwt"
+)
+
+func testSecret(t *testing.T) string {
+	secret := make([]byte, minHmacSecretSize)
+	if _, err := rand.Read(secret); err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(secret)
+}
+
+func testEd25519Pem(t *testing.T) string {
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "ed25519.pem")
+	if err = os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestParseKeySet(t *testing.T) {
+	secret, other := testSecret(t), testSecret(t)
+	edPath := testEd25519Pem(t)
+
+	tests := []struct {
+		name     string
+		encoded  string
+		activeId string
+		wantId   string
+		wantErr  string
+	}{
+		{name: "bare secret", encoded: secret, wantId: "default"},
+		{name: "kid alg value", encoded: "2024:HS256:" + secret, wantId: "2024"},
+		{name: "several keys", encoded: "old:HS256:" + secret + ",\nnew:EdDSA:" + edPath, activeId: "new", wantId: "new"},
+		{name: "no keys", encoded: " , ", wantErr: ErrNoSigningKey.Error()},
+		{name: "kid and secret only", encoded: "2024:" + secret, wantErr: `signing key "2024": entries are written as "kid:alg:value"`},
+		{name: "two bare secrets", encoded: secret + "," + other, wantErr: `signing key "default" is listed twice`},
+		{name: "duplicate kid", encoded: "a:HS256:" + secret + ",a:HS256:" + other, activeId: "a", wantErr: `signing key "a" is listed twice`},
+		{name: "several keys without an active id", encoded: "a:HS256:" + secret + ",b:HS256:" + other, wantErr: "JWT_KEY_ID must be set"},
+		{name: "unknown active id", encoded: "a:HS256:" + secret, activeId: "b", wantErr: `active signing key "b" is not in the key set`},
+		{name: "short secret", encoded: "a:HS256:" + base64.StdEncoding.EncodeToString([]byte("short")), wantErr: "at least 32 bytes"},
+		{name: "unknown algorithm", encoded: "a:ES256:" + secret, wantErr: "algorithm must be HS256, RS256 or EdDSA"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			set, err := ParseKeySet(test.encoded, test.activeId)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("error %v, want one containing %q", err, test.wantErr)
+				}
+				if err != nil && strings.Contains(err.Error(), secret) {
+					t.Fatalf("error %q quotes the secret", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if set.ActiveId() != test.wantId {
+				t.Fatalf("active key %q, want %q", set.ActiveId(), test.wantId)
+			}
+		})
+	}
+}
+
+func TestSignAndVerify(t *testing.T) {
+	oldSecret, newSecret := testSecret(t), testSecret(t)
+	previous, err := ParseKeySet("old:HS256:"+oldSecret, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	retiredToken, err := previous.Sign(jwt.MapClaims{"id": "user"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	set, err := ParseKeySet("old:HS256:"+oldSecret+",new:HS256:"+newSecret, "new")
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := set.Sign(jwt.MapClaims{"id": "user"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := jwt.Parse(token, set.Keyfunc)
+	if err != nil || !parsed.Valid {
+		t.Fatalf("token signed with the active key: %v", err)
+	}
+	if kid := parsed.Header["kid"]; kid != "new" {
+		t.Fatalf("token signed with kid %v, want new", kid)
+	}
+
+	if parsed, err = jwt.Parse(retiredToken, set.Keyfunc); err != nil || !parsed.Valid {
+		t.Fatalf("token signed with the retired key: %v", err)
+	}
+
+	current, err := ParseKeySet("new:HS256:"+newSecret, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = jwt.Parse(retiredToken, current.Keyfunc); err == nil {
+		t.Fatal("token signed with a key no longer in the set was accepted")
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	secret := testSecret(t)
+	set, err := ParseKeySet("hmac:HS256:"+secret+",ed:EdDSA:"+testEd25519Pem(t), "ed")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jwks := set.JWKS()
+	if len(jwks.Keys) != 1 {
+		t.Fatalf("JWKS lists %d keys, want only the EdDSA key", len(jwks.Keys))
+	}
+	key := jwks.Keys[0]
+	if key.KeyId != "ed" || key.KeyType != "OKP" || key.Curve != "Ed25519" || key.Algorithm != "EdDSA" || key.X == "" {
+		t.Fatalf("JWKS key %+v", key)
+	}
+
+	published, err := json.Marshal(jwks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(published), "hmac") || strings.Contains(string(published), strings.TrimRight(secret, "=")) {
+		t.Fatalf("JWKS leaks the HS256 key: %s", published)
+	}
+
+	token, err := set.Sign(jwt.MapClaims{"id": "user"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	x, err := base64.RawURLEncoding.DecodeString(key.X)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = jwt.Parse(token, func(token *jwt.Token) (interface{}, error) {
+		return ed25519.PublicKey(x), nil
+	})
+	if err != nil {
+		t.Fatalf("token does not verify against the published key: %v", err)
+	}
+}
@@ -0,0 +1,77 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+type Config struct {
+	// Exporter is none, stdout or otlp.
+	Exporter string
+	// Endpoint is the OTLP/HTTP collector URL; a path other than / replaces
+	// the default /v1/traces.
+	Endpoint    string
+	ServiceName string
+	SampleRatio float64
+}
+
+// Init installs the W3C trace-context and baggage propagators and, unless
+// the exporter is none, a tracer provider that batches spans to it. The
+// returned func flushes pending spans and stops the exporter.
+func Init(config Config) (shutdown func(context.Context) error, err error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	switch config.Exporter {
+	case "none":
+		return func(context.Context) error { return nil }, nil
+	case "stdout":
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case "otlp":
+		exporter, err = otlpExporter(config.Endpoint)
+	default:
+		err = fmt.Errorf("unknown trace exporter %q", config.Exporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	serviceResource, err := resource.Merge(resource.Default(), resource.NewSchemaless(attribute.String("service.name", config.ServiceName)))
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(serviceResource),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(config.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+func otlpExporter(endpoint string) (sdktrace.SpanExporter, error) {
+	collector, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	options := []otlptracehttp.Option{otlptracehttp.WithEndpoint(collector.Host)}
+	if collector.Scheme == "http" {
+		options = append(options, otlptracehttp.WithInsecure())
+	}
+	if collector.Path != "" && collector.Path != "/" {
+		options = append(options, otlptracehttp.WithURLPath(collector.Path))
+	}
+	return otlptracehttp.New(context.Background(), options...)
+}
@@ -1,10 +1,22 @@
 package util
 
+import "time"
+
 type CustomError struct {
 	Message string
 	Status  int
+	// RetryAfter, when set, tells the client how long to wait before trying
+	// again and is sent as the Retry-After header.
+	RetryAfter time.Duration
+	// Cause is the underlying failure. It is logged with the error but never
+	// sent to the client.
+	Cause error
 }
 
 func (e *CustomError) Error() string {
 	return e.Message
 }
+
+func (e *CustomError) Unwrap() error {
+	return e.Cause
+}
@@ -1,22 +1,115 @@
 package util
 
 import (
+	"context"
+	"crypto/tls"
 	"fmt"
+	"net"
 	"net/smtp"
-	"os"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
-func SendEmailOtp(toEmail string, otp int, purpose string) (err error) {
-	smtpServer := "smtp.gmail.com"
-	smtpPort := "587"
+// MailConfig is the SMTP account OTP emails are sent from.
+type MailConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	// Timeout bounds each send, including connecting to the server.
+	Timeout time.Duration
+}
+
+var tracer = otel.Tracer("password-manager/util")
+
+func SendEmailOtp(ctx context.Context, mail MailConfig, toEmail string, otp string, purpose string) (err error) {
+	if mail.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, mail.Timeout)
+		defer cancel()
+	}
+	ctx, span := tracer.Start(ctx, "smtp.SendMail", trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		attribute.String("server.address", mail.Host),
+		attribute.Int("server.port", mail.Port),
+	))
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
 
-	auth := smtp.PlainAuth("", os.Getenv("EMAIL"), os.Getenv("PASSKEY"), smtpServer)
-	from := os.Getenv("EMAIL")
+	auth := smtp.PlainAuth("", mail.Username, mail.Password, mail.Host)
+	from := mail.Username
 	to := []string{toEmail}
 	subject := "OTP for Password Manager"
 	body := fmt.Sprintf("OTP for %v is %v", purpose, otp)
 
 	message := "Subject: " + subject + "\r\n" + "\r\n" + body
 
-	return smtp.SendMail(smtpServer+":"+smtpPort, auth, from, to, []byte(message))
+	return sendMail(ctx, mail, auth, from, to, []byte(message))
+}
+
+// sendMail does what smtp.SendMail does over a connection bound to ctx: it
+// is dialled with ctx's deadline and closed as soon as ctx ends, so a slow or
+// unreachable server cannot hold the request past its deadline.
+func sendMail(ctx context.Context, mail MailConfig, auth smtp.Auth, from string, to []string, message []byte) (err error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(mail.Host, strconv.Itoa(mail.Port)))
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer func() {
+		stop()
+		if err != nil && ctx.Err() != nil {
+			err = ctx.Err()
+		}
+	}()
+
+	client, err := smtp.NewClient(conn, mail.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err = client.StartTLS(&tls.Config{ServerName: mail.Host}); err != nil {
+			return err
+		}
+	}
+	if ok, _ := client.Extension("AUTH"); ok {
+		if err = client.Auth(auth); err != nil {
+			return err
+		}
+	}
+	if err = client.Mail(from); err != nil {
+		return err
+	}
+	for _, address := range to {
+		if err = client.Rcpt(address); err != nil {
+			return err
+		}
+	}
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err = writer.Write(message); err != nil {
+		return err
+	}
+	if err = writer.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
 }
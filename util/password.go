@@ -0,0 +1,112 @@
+package util
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+type Argon2Params struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+var PasswordHashParams = Argon2Params{
+	Memory:      64 * 1024,
+	Iterations:  3,
+	Parallelism: 2,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+const argon2idPrefix = "$argon2id$"
+
+var errInvalidPasswordHash = errors.New("invalid password hash")
+
+// HashPassword encodes the Argon2id parameters alongside the salt and key in
+// the PHC string format so they can be checked later by VerifyPassword.
+func HashPassword(password string) (encoded string, err error) {
+	params := PasswordHashParams
+
+	salt := make([]byte, params.SaltLength)
+	if _, err = rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
+
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2idPrefix,
+		argon2.Version,
+		params.Memory,
+		params.Iterations,
+		params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// VerifyPassword reports whether password matches the stored value and whether
+// the stored value should be replaced with a fresh hash. Values that are not
+// Argon2id hashes are legacy plaintext records.
+func VerifyPassword(password string, stored string) (match bool, needsRehash bool, err error) {
+	if !strings.HasPrefix(stored, argon2idPrefix) {
+		match = subtle.ConstantTimeCompare([]byte(password), []byte(stored)) == 1
+		return match, true, nil
+	}
+
+	params, salt, key, err := decodePasswordHash(stored)
+	if err != nil {
+		return false, false, err
+	}
+
+	candidate := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
+	if subtle.ConstantTimeCompare(candidate, key) != 1 {
+		return false, false, nil
+	}
+
+	current := PasswordHashParams
+	needsRehash = params.Memory < current.Memory ||
+		params.Iterations < current.Iterations ||
+		params.Parallelism < current.Parallelism ||
+		params.SaltLength < current.SaltLength ||
+		params.KeyLength < current.KeyLength
+
+	return true, needsRehash, nil
+}
+
+func decodePasswordHash(encoded string) (params Argon2Params, salt []byte, key []byte, err error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 {
+		return Argon2Params{}, nil, nil, errInvalidPasswordHash
+	}
+
+	var version int
+	if _, err = fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return Argon2Params{}, nil, nil, errInvalidPasswordHash
+	}
+
+	if _, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return Argon2Params{}, nil, nil, errInvalidPasswordHash
+	}
+
+	if salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return Argon2Params{}, nil, nil, errInvalidPasswordHash
+	}
+	if key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil {
+		return Argon2Params{}, nil, nil, errInvalidPasswordHash
+	}
+
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
+
+	return params, salt, key, nil
+}
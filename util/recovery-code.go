@@ -0,0 +1,32 @@
+package util
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"strings"
+)
+
+var recoveryCodeEncoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+// GenerateRecoveryCodes returns count random codes of 50 bits each, formatted
+// as two groups of five characters for easier transcription.
+func GenerateRecoveryCodes(count int) (codes []string, err error) {
+	codes = make([]string, count)
+	for i := range codes {
+		raw := make([]byte, 7)
+		if _, err = rand.Read(raw); err != nil {
+			return nil, err
+		}
+		code := recoveryCodeEncoding.EncodeToString(raw)[:10]
+		codes[i] = code[:5] + "-" + code[5:]
+	}
+	return codes, nil
+}
+
+// NormalizeRecoveryCode strips the separators and case a user may have typed
+// so that only the characters of the code itself are compared.
+func NormalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	code = strings.ReplaceAll(code, "-", "")
+	return strings.ReplaceAll(code, " ", "")
+}
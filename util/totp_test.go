@@ -0,0 +1,66 @@
+package util
+
+import (
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA-1 seed from RFC 6238 appendix B, base32 encoded.
+var rfc6238Secret = totpEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestTotpRfc6238Vectors(t *testing.T) {
+	// The RFC lists eight digit codes; six digit codes are their last six.
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{unix: 59, code: "287082"},
+		{unix: 1111111109, code: "081804"},
+		{unix: 1111111111, code: "050471"},
+		{unix: 1234567890, code: "005924"},
+		{unix: 2000000000, code: "279037"},
+		{unix: 20000000000, code: "353130"},
+	}
+	for _, test := range tests {
+		now := time.Unix(test.unix, 0)
+		step, valid := ValidateTotp(rfc6238Secret, test.code, now)
+		if !valid || step != test.unix/totpPeriod {
+			t.Errorf("ValidateTotp(%q) at %d = %d, %v; want step %d", test.code, test.unix, step, valid, test.unix/totpPeriod)
+		}
+	}
+}
+
+func TestValidateTotp(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	current := now.Unix() / totpPeriod
+	key, err := totpEncoding.DecodeString(rfc6238Secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		secret   string
+		code     string
+		wantStep int64
+		wantOk   bool
+	}{
+		{name: "current step", secret: rfc6238Secret, code: totpCode(key, current), wantStep: current, wantOk: true},
+		{name: "previous step", secret: rfc6238Secret, code: totpCode(key, current-1), wantStep: current - 1, wantOk: true},
+		{name: "next step", secret: rfc6238Secret, code: totpCode(key, current+1), wantStep: current + 1, wantOk: true},
+		{name: "two steps old", secret: rfc6238Secret, code: totpCode(key, current-2)},
+		{name: "two steps ahead", secret: rfc6238Secret, code: totpCode(key, current+2)},
+		{name: "wrong code", secret: rfc6238Secret, code: "000000"},
+		{name: "too short", secret: rfc6238Secret, code: "50471"},
+		{name: "eight digits", secret: rfc6238Secret, code: "14050471"},
+		{name: "invalid secret", secret: "not base32!", code: totpCode(key, current)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			step, valid := ValidateTotp(test.secret, test.code, now)
+			if valid != test.wantOk || step != test.wantStep {
+				t.Fatalf("ValidateTotp = %d, %v; want %d, %v", step, valid, test.wantStep, test.wantOk)
+			}
+		})
+	}
+}
@@ -1,16 +1,25 @@
 package util
 
 import (
+	"context"
+	"crypto/rand"
 	"fmt"
-	"math"
-	"math/rand"
+	"math/big"
 	"net/http"
 	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
-func GenerateOtp(numberOfDigits int) (otp int) {
-	max := math.Pow(10, float64(numberOfDigits)-1) * 9
-	return rand.Intn(int(max)) + int(math.Pow(10, float64(numberOfDigits)-1))
+// GenerateOtp returns a uniformly random code of numberOfDigits digits,
+// including leading zeros.
+func GenerateOtp(numberOfDigits int) (otp string, err error) {
+	max := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(numberOfDigits)), nil)
+	n, err := rand.Int(rand.Reader, max)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%0*d", numberOfDigits, n), nil
 }
 
 func TimestampToUnix(timestampMilliseconds int64) (unixTime time.Time) {
@@ -31,13 +40,21 @@ func GenerateCookie(cookieName string, cookieValue string, age int) *http.Cookie
 	}
 }
 
-func GetImage(domain string) (imageUrl string) {
+// imageClient forwards the trace context and records a span per request.
+var imageClient = &http.Client{Transport: otelhttp.NewTransport(http.DefaultTransport)}
+
+func GetImage(ctx context.Context, domain string) (imageUrl string) {
 	imageUrl = fmt.Sprintf("https://logo.clearbit.com/%v", domain)
-	response, err := http.Get(imageUrl)
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, imageUrl, nil)
+	if err != nil {
+		return ""
+	}
+	response, err := imageClient.Do(request)
 	if err != nil {
 		return ""
 	}
-	if response.Status != "200 OK" {
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
 		return ""
 	}
 	return imageUrl
@@ -0,0 +1,136 @@
+package vault
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"password-manager/entity"
+	"strings"
+)
+
+const defaultMasterKeyId = "default"
+
+var ErrNoMasterKey = errors.New("MASTER_KEY must be set")
+
+// KeyRing holds every master key that may still wrap stored data keys. New
+// data keys are always wrapped with the active key.
+type KeyRing struct {
+	activeId string
+	keys     map[string]cipher.AEAD
+}
+
+func NewKeyRing(keys map[string][]byte, activeId string) (*KeyRing, error) {
+	if _, ok := keys[activeId]; !ok {
+		return nil, fmt.Errorf("active master key %q is not in the key ring", activeId)
+	}
+
+	ring := &KeyRing{
+		activeId: activeId,
+		keys:     map[string]cipher.AEAD{},
+	}
+	for id, key := range keys {
+		aead, err := newAEAD(key)
+		if err != nil {
+			return nil, fmt.Errorf("master key %q: %w", id, err)
+		}
+		ring.keys[id] = aead
+	}
+
+	return ring, nil
+}
+
+// ParseKeyRing reads master keys as configured in MASTER_KEY. Keys are base64
+// encoded 256-bit values written as "id:key" and separated by commas or
+// newlines; a bare key gets the id "default". activeId selects the active key
+// and is required when several are set.
+func ParseKeyRing(encoded string, activeId string) (*KeyRing, error) {
+	keys := map[string][]byte{}
+	entries := strings.FieldsFunc(encoded, func(r rune) bool {
+		return r == ',' || r == '\n' || r == '\r'
+	})
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		id, value := defaultMasterKeyId, entry
+		if before, after, found := strings.Cut(entry, ":"); found {
+			id, value = strings.TrimSpace(before), strings.TrimSpace(after)
+		}
+		if _, exists := keys[id]; exists {
+			return nil, fmt.Errorf("master key %q is listed twice", id)
+		}
+
+		key, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return nil, fmt.Errorf("master key %q must be base64 encoded", id)
+		}
+		keys[id] = key
+
+		if len(entries) == 1 && activeId == "" {
+			activeId = id
+		}
+	}
+
+	if len(keys) == 0 {
+		return nil, ErrNoMasterKey
+	}
+	if activeId == "" {
+		return nil, errors.New("MASTER_KEY_ID must be set when several master keys are configured")
+	}
+
+	return NewKeyRing(keys, activeId)
+}
+
+func NewEphemeralKeyRing() (*KeyRing, error) {
+	key, err := GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+	return NewKeyRing(map[string][]byte{"ephemeral": key}, "ephemeral")
+}
+
+func GenerateKey() (key []byte, err error) {
+	key = make([]byte, keySize)
+	if _, err = rand.Read(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func (ring *KeyRing) ActiveId() string {
+	return ring.activeId
+}
+
+func (ring *KeyRing) wrap(dataKey *entity.DataKey, key []byte) error {
+	master := ring.keys[ring.activeId]
+
+	nonce := make([]byte, master.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+
+	dataKey.WrappedKey = master.Seal(nonce, nonce, key, wrapAdditionalData(*dataKey))
+	dataKey.MasterKeyId = ring.activeId
+	return nil
+}
+
+func (ring *KeyRing) unwrap(dataKey entity.DataKey) ([]byte, error) {
+	master, ok := ring.keys[dataKey.MasterKeyId]
+	if !ok {
+		return nil, fmt.Errorf("master key %q is not in the key ring", dataKey.MasterKeyId)
+	}
+	if len(dataKey.WrappedKey) < master.NonceSize() {
+		return nil, errors.New("wrapped key too short")
+	}
+
+	nonce, sealed := dataKey.WrappedKey[:master.NonceSize()], dataKey.WrappedKey[master.NonceSize():]
+	return master.Open(nil, nonce, sealed, wrapAdditionalData(dataKey))
+}
+
+func wrapAdditionalData(dataKey entity.DataKey) []byte {
+	return []byte(dataKey.UserId + "|" + dataKey.Id)
+}
@@ -0,0 +1,175 @@
+package vault
+
+import (
+	"context"
+	"crypto/cipher"
+	"password-manager/db"
+	"password-manager/entity"
+)
+
+type RotationOptions struct {
+	// ReEncrypt gives every user a fresh data key and re-encrypts their sites
+	// with it. Without it only data keys wrapped by a retired master key are
+	// re-wrapped, which leaves site documents untouched.
+	ReEncrypt bool
+}
+
+type RotationProgress struct {
+	UserId           string
+	UsersDone        int
+	UsersTotal       int
+	SitesReEncrypted int
+}
+
+type RotationFailure struct {
+	UserId string
+	KeyId  string
+	SiteId string
+	Err    error
+}
+
+// RotationReport counts only the writes this run made. Data keys and sites
+// that another instance got to first are counted as skipped.
+type RotationReport struct {
+	KeysRewrapped    int
+	KeysSkipped      int
+	UsersProcessed   int
+	SitesReEncrypted int
+	SitesSkipped     int
+	Failures         []RotationFailure
+}
+
+type Rotator interface {
+	Run(ctx context.Context, options RotationOptions, progress func(RotationProgress)) (report RotationReport, err error)
+}
+
+type rotator struct {
+	vault *vault
+	sites db.SiteStore
+}
+
+func NewRotator(ring *KeyRing, keys db.DataKeyStore, sites db.SiteStore) Rotator {
+	return &rotator{
+		vault: &vault{ring: ring, keys: keys},
+		sites: sites,
+	}
+}
+
+// Run works through the vault one record at a time so the service can keep
+// serving requests. Sites are only replaced if they still hold the ciphertext
+// that was read, so a concurrent edit is never overwritten; such sites are
+// counted as skipped because the edit already used the user's newest key.
+func (rotator *rotator) Run(ctx context.Context, options RotationOptions, progress func(RotationProgress)) (report RotationReport, err error) {
+	report.Failures = []RotationFailure{}
+
+	if err = rotator.rewrapKeys(ctx, &report); err != nil {
+		return report, err
+	}
+
+	if !options.ReEncrypt {
+		return report, nil
+	}
+
+	userIds, err := rotator.sites.ListSiteOwners(ctx)
+	if err != nil {
+		return report, err
+	}
+
+	for i, userId := range userIds {
+		reEncrypted := rotator.reEncryptUser(ctx, userId, &report)
+		report.UsersProcessed++
+		if progress != nil {
+			progress(RotationProgress{
+				UserId:           userId,
+				UsersDone:        i + 1,
+				UsersTotal:       len(userIds),
+				SitesReEncrypted: reEncrypted,
+			})
+		}
+	}
+
+	return report, nil
+}
+
+func (rotator *rotator) rewrapKeys(ctx context.Context, report *RotationReport) error {
+	ring := rotator.vault.ring
+	keys, err := rotator.vault.keys.ListDataKeysNotWrappedBy(ctx, ring.ActiveId())
+	if err != nil {
+		return err
+	}
+
+	for _, dataKey := range keys {
+		previousMasterKeyId := dataKey.MasterKeyId
+
+		key, err := ring.unwrap(dataKey)
+		if err == nil {
+			err = ring.wrap(&dataKey, key)
+		}
+		updated := false
+		if err == nil {
+			updated, err = rotator.vault.keys.RewrapDataKey(ctx, dataKey, previousMasterKeyId)
+		}
+		if err != nil {
+			report.Failures = append(report.Failures, RotationFailure{UserId: dataKey.UserId, KeyId: dataKey.Id, Err: err})
+			continue
+		}
+		if !updated {
+			report.KeysSkipped++
+			continue
+		}
+		report.KeysRewrapped++
+	}
+
+	return nil
+}
+
+func (rotator *rotator) reEncryptUser(ctx context.Context, userId string, report *RotationReport) (reEncrypted int) {
+	sites, err := rotator.sites.GetAllSites(ctx, userId)
+	if err != nil {
+		report.Failures = append(report.Failures, RotationFailure{UserId: userId, Err: err})
+		return 0
+	}
+	if len(sites) == 0 {
+		return 0
+	}
+
+	dataKey, aead, err := rotator.vault.createDataKey(ctx, userId)
+	if err != nil {
+		report.Failures = append(report.Failures, RotationFailure{UserId: userId, Err: err})
+		return 0
+	}
+
+	for _, site := range sites {
+		updated, err := rotator.reEncryptSite(ctx, userId, dataKey, aead, site)
+		if err != nil {
+			report.Failures = append(report.Failures, RotationFailure{UserId: userId, KeyId: site.KeyId, SiteId: site.Id, Err: err})
+			continue
+		}
+		if !updated {
+			report.SitesSkipped++
+			continue
+		}
+		report.SitesReEncrypted++
+		reEncrypted++
+	}
+
+	return reEncrypted
+}
+
+func (rotator *rotator) reEncryptSite(ctx context.Context, userId string, dataKey entity.DataKey, aead cipher.AEAD, site entity.Site) (updated bool, err error) {
+	if site.KeyId == dataKey.Id {
+		return false, nil
+	}
+
+	decryptedSite, err := rotator.vault.DecryptSite(ctx, userId, site)
+	if err != nil {
+		return false, err
+	}
+
+	encryptedSite, err := encryptSite(userId, dataKey.Id, aead, decryptedSite)
+	if err != nil {
+		return false, err
+	}
+
+	return rotator.sites.ReEncryptSite(ctx, site.Id, site, encryptedSite)
+}
@@ -0,0 +1,111 @@
+package vault
+
+import (
+	"context"
+	"password-manager/db"
+	"password-manager/entity"
+	"testing"
+)
+
+// staleKeys lists the data keys as they were before an earlier run, as seen
+// by an instance that started at the same time as that run.
+type staleKeys struct {
+	db.DataKeyStore
+	snapshot []entity.DataKey
+}
+
+func (store staleKeys) ListDataKeysNotWrappedBy(ctx context.Context, masterKeyId string) ([]entity.DataKey, error) {
+	return store.snapshot, nil
+}
+
+func newTestRing(t *testing.T, keys map[string][]byte, activeId string) *KeyRing {
+	ring, err := NewKeyRing(keys, activeId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ring
+}
+
+func TestRotation(t *testing.T) {
+	ctx := context.Background()
+	oldKey, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	newKey, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys, sites := db.NewMemoryDataKeyStore(), db.NewMemorySiteStore()
+	oldVault := &vault{ring: newTestRing(t, map[string][]byte{"old": oldKey}, "old"), keys: keys}
+	owners := map[string]int{testUserId: 2, otherUserId: 1}
+	for userId, count := range owners {
+		for i := 0; i < count; i++ {
+			site, err := oldVault.EncryptSite(ctx, userId, testSite())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err = sites.SaveSite(ctx, userId, site); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	ring := newTestRing(t, map[string][]byte{"old": oldKey, "new": newKey}, "new")
+	snapshot, err := keys.ListDataKeysNotWrappedBy(ctx, "new")
+	if err != nil || len(snapshot) != 2 {
+		t.Fatalf("ListDataKeysNotWrappedBy = %d keys, %v; want 2", len(snapshot), err)
+	}
+
+	report, err := NewRotator(ring, keys, sites).Run(ctx, RotationOptions{ReEncrypt: true}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := RotationReport{KeysRewrapped: 2, UsersProcessed: 2, SitesReEncrypted: 3, Failures: []RotationFailure{}}
+	if !sameReport(report, want) {
+		t.Fatalf("first run reported %+v, want %+v", report, want)
+	}
+
+	// Every site now decrypts without the old master key.
+	newVault := &vault{ring: newTestRing(t, map[string][]byte{"new": newKey}, "new"), keys: keys}
+	for userId, count := range owners {
+		stored, err := sites.GetAllSites(ctx, userId)
+		if err != nil || len(stored) != count {
+			t.Fatalf("GetAllSites = %d sites, %v; want %d", len(stored), err, count)
+		}
+		for _, site := range stored {
+			decrypted, err := newVault.DecryptSite(ctx, userId, site)
+			if err != nil {
+				t.Fatalf("DecryptSite after rotation: %v", err)
+			}
+			if decrypted.Password != testSite().Password {
+				t.Fatalf("decrypted password %q", decrypted.Password)
+			}
+		}
+	}
+
+	report, err = NewRotator(ring, keys, sites).Run(ctx, RotationOptions{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want = (RotationReport{Failures: []RotationFailure{}}); !sameReport(report, want) {
+		t.Fatalf("second run reported %+v, want %+v", report, want)
+	}
+
+	// An instance that listed the keys before the first run finished loses
+	// every compare-and-swap and must not count the keys as its own.
+	report, err = NewRotator(ring, staleKeys{DataKeyStore: keys, snapshot: snapshot}, sites).Run(ctx, RotationOptions{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want = (RotationReport{KeysSkipped: 2, Failures: []RotationFailure{}}); !sameReport(report, want) {
+		t.Fatalf("stale run reported %+v, want %+v", report, want)
+	}
+}
+
+func sameReport(got RotationReport, want RotationReport) bool {
+	return got.KeysRewrapped == want.KeysRewrapped && got.KeysSkipped == want.KeysSkipped &&
+		got.UsersProcessed == want.UsersProcessed && got.SitesReEncrypted == want.SitesReEncrypted &&
+		got.SitesSkipped == want.SitesSkipped && len(got.Failures) == len(want.Failures)
+}
@@ -0,0 +1,47 @@
+package vault
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"password-manager/util"
+)
+
+// EncryptSecret seals a single account secret, such as a TOTP seed, under the
+// user's active data key. The returned key id must be stored next to the
+// ciphertext and passed back to DecryptSecret.
+func (vault *vault) EncryptSecret(ctx context.Context, userId string, field string, value string) (string, string, error) {
+	dataKey, aead, err := vault.activeKey(ctx, userId)
+	if err != nil {
+		return "", "", err
+	}
+
+	ciphertext, err := encrypt(aead, value, additionalData(userId, field))
+	if err != nil {
+		return "", "", &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
+	}
+
+	return ciphertext, dataKey.Id, nil
+}
+
+func (vault *vault) DecryptSecret(ctx context.Context, userId string, field string, keyId string, ciphertext string) (string, error) {
+	dataKey, found, err := vault.keys.GetDataKey(ctx, userId, keyId)
+	if err != nil {
+		return "", err
+	}
+	if !found {
+		return "", &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: errors.New("data key " + keyId + " not found")}
+	}
+
+	aead, err := vault.open(dataKey)
+	if err != nil {
+		return "", err
+	}
+
+	value, err := decrypt(aead, ciphertext, additionalData(userId, field))
+	if err != nil {
+		return "", &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
+	}
+
+	return value, nil
+}
@@ -0,0 +1,215 @@
+package vault
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"password-manager/db"
+	"password-manager/entity"
+	"password-manager/util"
+	"strings"
+	"time"
+)
+
+const (
+	keySize         = 32
+	encryptedPrefix = "enc:"
+)
+
+type Vault interface {
+	EncryptSite(ctx context.Context, userId string, site entity.Site) (encryptedSite entity.Site, err error)
+	DecryptSite(ctx context.Context, userId string, site entity.Site) (decryptedSite entity.Site, err error)
+	EncryptSecret(ctx context.Context, userId string, field string, value string) (ciphertext string, keyId string, err error)
+	DecryptSecret(ctx context.Context, userId string, field string, keyId string, ciphertext string) (value string, err error)
+}
+
+type vault struct {
+	ring *KeyRing
+	keys db.DataKeyStore
+}
+
+func NewVault(ring *KeyRing, keys db.DataKeyStore) Vault {
+	return &vault{
+		ring: ring,
+		keys: keys,
+	}
+}
+
+func (vault *vault) EncryptSite(ctx context.Context, userId string, site entity.Site) (entity.Site, error) {
+	dataKey, aead, err := vault.activeKey(ctx, userId)
+	if err != nil {
+		return entity.Site{}, err
+	}
+
+	return encryptSite(userId, dataKey.Id, aead, site)
+}
+
+// DecryptSite leaves sites stored before encryption was introduced untouched,
+// so existing vaults keep working until they are re-saved or re-encrypted.
+// Only the key id tells the two apart: a plaintext password may well start
+// with the ciphertext prefix.
+func (vault *vault) DecryptSite(ctx context.Context, userId string, site entity.Site) (entity.Site, error) {
+	if site.KeyId == "" {
+		return site, nil
+	}
+
+	dataKey, found, err := vault.keys.GetDataKey(ctx, userId, site.KeyId)
+	if err != nil {
+		return entity.Site{}, err
+	}
+	if !found {
+		return entity.Site{}, &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: errors.New("data key " + site.KeyId + " not found")}
+	}
+
+	aead, err := vault.open(dataKey)
+	if err != nil {
+		return entity.Site{}, err
+	}
+
+	return decryptSite(userId, aead, site)
+}
+
+func (vault *vault) activeKey(ctx context.Context, userId string) (entity.DataKey, cipher.AEAD, error) {
+	dataKey, found, err := vault.keys.GetActiveDataKey(ctx, userId)
+	if err != nil {
+		return entity.DataKey{}, nil, err
+	}
+	if !found {
+		return vault.createDataKey(ctx, userId)
+	}
+
+	aead, err := vault.open(dataKey)
+	if err != nil {
+		return entity.DataKey{}, nil, err
+	}
+	return dataKey, aead, nil
+}
+
+func (vault *vault) open(dataKey entity.DataKey) (cipher.AEAD, error) {
+	key, err := vault.ring.unwrap(dataKey)
+	if err != nil {
+		return nil, &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
+	}
+
+	return newAEAD(key)
+}
+
+func (vault *vault) createDataKey(ctx context.Context, userId string) (entity.DataKey, cipher.AEAD, error) {
+	key, err := GenerateKey()
+	if err != nil {
+		return entity.DataKey{}, nil, &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
+	}
+
+	id := make([]byte, 12)
+	if _, err = rand.Read(id); err != nil {
+		return entity.DataKey{}, nil, &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
+	}
+
+	dataKey := entity.DataKey{
+		Id:        hex.EncodeToString(id),
+		UserId:    userId,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err = vault.ring.wrap(&dataKey, key); err != nil {
+		return entity.DataKey{}, nil, &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
+	}
+
+	if err = vault.keys.CreateDataKey(ctx, dataKey); err != nil {
+		return entity.DataKey{}, nil, err
+	}
+
+	aead, err := newAEAD(key)
+	if err != nil {
+		return entity.DataKey{}, nil, err
+	}
+
+	return dataKey, aead, nil
+}
+
+type siteField struct {
+	name  string
+	value *string
+}
+
+func sensitiveFields(site *entity.Site) []siteField {
+	return []siteField{
+		{name: "username", value: &site.Username},
+		{name: "password", value: &site.Password},
+		{name: "notes", value: &site.Notes},
+	}
+}
+
+func encryptSite(userId string, keyId string, aead cipher.AEAD, site entity.Site) (entity.Site, error) {
+	var err error
+	for _, field := range sensitiveFields(&site) {
+		if *field.value, err = encrypt(aead, *field.value, additionalData(userId, field.name)); err != nil {
+			return entity.Site{}, &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
+		}
+	}
+	site.KeyId = keyId
+
+	return site, nil
+}
+
+func decryptSite(userId string, aead cipher.AEAD, site entity.Site) (entity.Site, error) {
+	var err error
+	for _, field := range sensitiveFields(&site) {
+		if *field.value, err = decrypt(aead, *field.value, additionalData(userId, field.name)); err != nil {
+			return entity.Site{}, &util.CustomError{Message: "Internal Server Error", Status: http.StatusInternalServerError, Cause: err}
+		}
+	}
+	site.KeyId = ""
+
+	return site, nil
+}
+
+func additionalData(userId string, field string) []byte {
+	return []byte(userId + "|" + field)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != keySize {
+		return nil, errors.New("key must be 32 bytes")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func encrypt(aead cipher.AEAD, plaintext string, additionalData []byte) (string, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := aead.Seal(nonce, nonce, []byte(plaintext), additionalData)
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func decrypt(aead cipher.AEAD, value string, additionalData []byte) (string, error) {
+	if !strings.HasPrefix(value, encryptedPrefix) {
+		return "", errors.New("value is not encrypted")
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, encryptedPrefix))
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return "", errors.New("ciphertext too short")
+	}
+
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], additionalData)
+	if err != nil {
+		return "", err
+	}
+
+	return string(plaintext), nil
+}
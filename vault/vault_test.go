@@ -0,0 +1,125 @@
+package vault
+
+import (
+	"context"
+	"password-manager/db"
+	"password-manager/entity"
+	"strings"
+	"testing"
+)
+
+const (
+	testUserId  = "64b7f0c2a1b2c3d4e5f60718"
+	otherUserId = "64b7f0c2a1b2c3d4e5f60719"
+)
+
+func newTestVault(t *testing.T) (*vault, db.SiteStore) {
+	ring, err := NewEphemeralKeyRing()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &vault{ring: ring, keys: db.NewMemoryDataKeyStore()}, db.NewMemorySiteStore()
+}
+
+func testSite() entity.Site {
+	return entity.Site{URL: "https://example.com", Name: "Example", Sector: "Other", Username: "user", Password: "secret", Notes: "notes"}
+}
+
+func TestSiteRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	siteVault, sites := newTestVault(t)
+
+	encrypted, err := siteVault.EncryptSite(ctx, testUserId, testSite())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if encrypted.KeyId == "" {
+		t.Fatal("EncryptSite stored no key id")
+	}
+	for _, value := range []string{encrypted.Username, encrypted.Password, encrypted.Notes} {
+		if !strings.HasPrefix(value, encryptedPrefix) {
+			t.Fatalf("field stored as %q, want ciphertext", value)
+		}
+	}
+
+	if _, err = sites.SaveSite(ctx, testUserId, encrypted); err != nil {
+		t.Fatal(err)
+	}
+	stored, err := sites.GetSites(ctx, testUserId)
+	if err != nil || len(stored) != 1 {
+		t.Fatalf("GetSites = %v, %v; want one site", stored, err)
+	}
+
+	decrypted, err := siteVault.DecryptSite(ctx, testUserId, stored[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := testSite()
+	if decrypted.Username != want.Username || decrypted.Password != want.Password || decrypted.Notes != want.Notes || decrypted.KeyId != "" {
+		t.Fatalf("decrypted %+v, want %+v", decrypted, want)
+	}
+}
+
+func TestSiteBoundToUser(t *testing.T) {
+	ctx := context.Background()
+	siteVault, _ := newTestVault(t)
+
+	encrypted, err := siteVault.EncryptSite(ctx, testUserId, testSite())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Even with the right data key, another user's id does not authenticate.
+	dataKey, _, err := siteVault.keys.GetDataKey(ctx, testUserId, encrypted.KeyId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	aead, err := siteVault.open(dataKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = decryptSite(otherUserId, aead, encrypted); err == nil {
+		t.Fatal("decrypted a site with another user's additional data")
+	}
+
+	if _, err = siteVault.DecryptSite(ctx, otherUserId, encrypted); err == nil {
+		t.Fatal("another user decrypted the site")
+	}
+}
+
+func TestLegacyPlaintextSite(t *testing.T) {
+	ctx := context.Background()
+	siteVault, sites := newTestVault(t)
+
+	legacy := testSite()
+	legacy.Password = encryptedPrefix + "looks like ciphertext"
+	if _, err := sites.SaveSite(ctx, testUserId, legacy); err != nil {
+		t.Fatal(err)
+	}
+	stored, err := sites.GetSites(ctx, testUserId)
+	if err != nil || len(stored) != 1 {
+		t.Fatalf("GetSites = %v, %v; want one site", stored, err)
+	}
+
+	decrypted, err := siteVault.DecryptSite(ctx, testUserId, stored[0])
+	if err != nil {
+		t.Fatalf("DecryptSite on a plaintext site: %v", err)
+	}
+	if decrypted.Password != legacy.Password || decrypted.Username != legacy.Username {
+		t.Fatalf("plaintext site changed to %+v", decrypted)
+	}
+}
+
+func TestEncryptedSiteWithPlaintextField(t *testing.T) {
+	ctx := context.Background()
+	siteVault, _ := newTestVault(t)
+
+	encrypted, err := siteVault.EncryptSite(ctx, testUserId, testSite())
+	if err != nil {
+		t.Fatal(err)
+	}
+	encrypted.Password = "swapped in"
+	if _, err = siteVault.DecryptSite(ctx, testUserId, encrypted); err == nil {
+		t.Fatal("DecryptSite accepted a plaintext field on an encrypted site")
+	}
+}